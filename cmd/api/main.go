@@ -2,55 +2,162 @@ package main
 
 import (
 	"context"
-	"log"
+	"encoding/base64"
+	"fmt"
+	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"syscall"
 	"time"
 
 	"github.com/joho/godotenv"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/redis/go-redis/v9"
 	"github.com/satyamraj1643/janus/db"
 	"github.com/satyamraj1643/janus/handler"
 	"github.com/satyamraj1643/janus/internal/admission"
+	"github.com/satyamraj1643/janus/internal/audit"
+	"github.com/satyamraj1643/janus/internal/deadletter"
+	"github.com/satyamraj1643/janus/internal/lease"
+	"github.com/satyamraj1643/janus/internal/logging"
+	"github.com/satyamraj1643/janus/internal/metrics"
+	"github.com/satyamraj1643/janus/internal/outbox"
 	"github.com/satyamraj1643/janus/internal/store"
+	"github.com/satyamraj1643/janus/internal/tracing"
 	"github.com/satyamraj1643/janus/listener"
 	"github.com/satyamraj1643/janus/middleware"
+	"github.com/satyamraj1643/janus/permit"
+	"github.com/satyamraj1643/janus/queue"
+	"github.com/satyamraj1643/janus/spec"
 	"github.com/satyamraj1643/janus/worker"
 )
 
 func main() {
 
-	if err := godotenv.Load(); err != nil {
-		log.Println("No .env file found, using system env")
+	envErr := godotenv.Load()
+
+	// LOG_LEVEL and LOG_FORMAT, read after .env so it can set them
+	if err := logging.Setup(); err != nil {
+		logging.Fatal("setting up logging failed", "err", err)
+	}
+	if envErr != nil {
+		slog.Info("no .env file found, using system env")
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		runMigrate(os.Args[2:])
+		return
+	}
+
+	// AUDIT_LOG, AUDIT_SAMPLE_RATE and AUDIT_PAYLOAD_FIELDS shape the decision audit log
+	auditConfig, err := audit.ConfigFromEnv()
+	if err != nil {
+		logging.Fatal("setting up the audit log failed", "err", err)
+	}
+	audit.Configure(auditConfig)
+
+	// OTEL_EXPORTER_OTLP_ENDPOINT exports traces to an OTLP collector
+	shutdownTracing, err := tracing.Init(context.Background())
+	if err != nil {
+		logging.Fatal("setting up tracing failed", "err", err)
 	}
+	defer shutdownTracing(context.Background())
 
 	// Initialise admission controller
 	// Initialise admission controller
 	redisAddr := os.Getenv("REDIS_ADDR")
 	if redisAddr == "" {
-		log.Fatal("REDIS_ADDR not set")
+		logging.Fatal("REDIS_ADDR not set")
 	}
 
 	redisStore := store.NewRedisStore(redisAddr)
 
 	// Create a background context for initial ping
 	if err := redisStore.Ping(context.Background()); err != nil {
-		log.Fatalf("Failed to connect to Redis at %s: %v", redisAddr, err)
+		logging.Fatal("connecting to Redis failed", "addr", redisAddr, "err", err)
 	}
 
-	log.Println("Connected to Redis")
+	slog.Info("connected to Redis", "addr", redisAddr)
+
+	if _, _, err := redisStore.LoadScripts(context.Background()); err != nil {
+		logging.Fatal("loading Lua scripts into Redis failed", "err", err)
+	}
+
+	// QUEUE_BACKEND=redis keeps queued jobs and decisions in Redis streams across restarts
+	if os.Getenv("QUEUE_BACKEND") == "redis" {
+		if err := useStreamQueues(context.Background(), redisAddr); err != nil {
+			logging.Fatal("setting up Redis stream queues failed", "err", err)
+		}
+		slog.Info("using Redis stream queues")
+	} else if err := useResultOverflow(); err != nil {
+		logging.Fatal("setting up the result queue failed", "err", err)
+	}
 
 	ac := admission.NewAdmissionController(redisStore)
+	ac.Waiting = redisStore   // durable wait queue for the defer policy
+	ac.Ready = redisStore     // per-owner ready queue for execution.dispatch
+	ac.Scheduled = redisStore // holds jobs until their not_before
+	ac.Admitted = redisStore  // accepted jobs that were not dispatched, until cancelled
+
+	// PERMIT_SIGNING_KEY signs the permits of accepted jobs and of leases
+	if seed := os.Getenv("PERMIT_SIGNING_KEY"); seed != "" {
+		signer, err := newPermitSigner(seed)
+		if err != nil {
+			logging.Fatal("invalid PERMIT_SIGNING_KEY", "err", err)
+		}
+		ac.Permits = signer
+		slog.Info("signing execution permits")
+	}
+
+	deadLetters := deadletter.NewQueue(redisStore)
+	leases := lease.NewManager(redisStore, deadLetters)
+	leases.Permits = ac.Permits // signs a permit per lease, bound to its deadline
 
 	// Init DB
 
 	db.Init()
 	defer db.Pool.Close()
 
+	// MIGRATE_ON_START=true applies pending schema migrations, see `janus migrate`
+	if os.Getenv("MIGRATE_ON_START") == "true" {
+		if _, err := db.MigrateUp(context.Background()); err != nil {
+			logging.Fatal("migrating the database failed", "err", err)
+		}
+	}
+
+	// SHUTDOWN_TIMEOUT bounds the drain on SIGINT/SIGTERM, see shutdown
+	shutdownTimeout := defaultShutdownTimeout
+	if v := os.Getenv("SHUTDOWN_TIMEOUT"); v != "" {
+		if shutdownTimeout, err = time.ParseDuration(v); err != nil {
+			logging.Fatal("invalid SHUTDOWN_TIMEOUT", "err", err)
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
 	dbURL := os.Getenv("DB_URL")
-	listener.StartConfigListener(dbURL, redisStore)
+	listenerDone := listener.StartConfigListener(ctx, dbURL, redisStore)
+
+	// DB_WRITERS, DB_WRITER_BATCH_SIZE and DB_WRITER_FLUSH_INTERVAL size the DB writer
+	writerConfig, err := dbWriterConfig()
+	if err != nil {
+		logging.Fatal("invalid DB writer config", "err", err)
+	}
+	decisionOutbox := outbox.New(redisStore) // holds decisions while Postgres is down
+	writerConfig.Outbox = decisionOutbox
 
 	//worker.StartJanusService(2, ac) // Removed in favor of synchronous admission
-	worker.StartDBWriter(2) // 2 worker thread to save the processed job into DB (async with Janus singleton thread)
+	written := worker.StartDBWriter(writerConfig) // saves the processed jobs into DB (async with Janus singleton thread)
+	background := []<-chan struct{}{
+		worker.StartDeferredReleaser(ctx, ac, 100*time.Millisecond), // re-evaluates jobs held by the defer policy
+		worker.StartLeaseReaper(ctx, leases, time.Second),           // reclaims leases of workers that went quiet
+		worker.StartScheduler(ctx, ac, 250*time.Millisecond),        // admits scheduled jobs once not_before passes
+		worker.StartOutboxRelay(ctx, decisionOutbox, time.Second),   // saves held decisions once Postgres is back
+	}
 
 	dashboardHandler := &handler.JobHandler{AC: ac, FromDashboard: true}
 	systemHandler := &handler.JobHandler{AC: ac, FromDashboard: false}
@@ -65,6 +172,31 @@ func main() {
 		w.Write([]byte(`{"status":"ok"}`))
 	})
 
+	// Orchestrator probes (no auth required)
+	healthHandler := &handler.HealthHandler{Redis: redisStore}
+	mux.HandleFunc("GET /livez", healthHandler.Livez)
+	mux.HandleFunc("GET /readyz", healthHandler.Readyz)
+
+	// Prometheus metrics (no auth required)
+	metrics.WatchResultQueue(func() float64 {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		return float64(queue.ResultDepth(ctx))
+	})
+	metrics.WatchResultSpill(func() float64 {
+		return float64(queue.ResultSpilled())
+	})
+	metrics.WatchOutbox(func() float64 {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		n, err := decisionOutbox.Len(ctx)
+		if err != nil {
+			return -1
+		}
+		return float64(n)
+	})
+	mux.Handle("GET /metrics", promhttp.Handler())
+
 	// Route + middleware
 
 	mux.Handle(
@@ -108,25 +240,359 @@ func main() {
 		),
 	)
 
+	// Read-only decision lookups, allowed even while the service is paused
+	queryHandler := &handler.QueryHandler{}
+
+	mux.Handle(
+		"GET /jobs",
+		middleware.UserOnly(
+			http.HandlerFunc(queryHandler.ListJobs),
+		),
+	)
+
+	mux.Handle(
+		"GET /jobs/{id}",
+		middleware.UserOnly(
+			http.HandlerFunc(queryHandler.GetJob),
+		),
+	)
+
+	mux.Handle(
+		"GET /jobs/{id}/events",
+		middleware.UserOnly(
+			http.HandlerFunc(queryHandler.GetJobEvents),
+		),
+	)
+
+	// Cancelling gives quota back, so it stays open while the service is paused
+	mux.Handle(
+		"DELETE /jobs/{id}",
+		middleware.UserOnly(
+			http.HandlerFunc(systemHandler.CancelJob),
+		),
+	)
+
+	mux.Handle(
+		"GET /batches/{id}",
+		middleware.UserOnly(
+			http.HandlerFunc(queryHandler.GetBatch),
+		),
+	)
+
+	// Worker pull API for dispatched jobs. Leasing follows the service switch,
+	// in-flight leases can still be finished while the service is paused.
+	workerHandler := &handler.WorkerHandler{Leases: leases}
+
+	mux.Handle(
+		"POST /workers/lease",
+		middleware.ServiceRunningOnly(
+			http.HandlerFunc(workerHandler.Lease),
+		),
+	)
+
+	mux.Handle(
+		"POST /workers/leases/{id}/heartbeat",
+		middleware.UserOnly(
+			http.HandlerFunc(workerHandler.Heartbeat),
+		),
+	)
+
+	mux.Handle(
+		"POST /workers/leases/{id}/complete",
+		middleware.UserOnly(
+			http.HandlerFunc(workerHandler.Complete),
+		),
+	)
+
+	mux.Handle(
+		"POST /workers/leases/{id}/fail",
+		middleware.UserOnly(
+			http.HandlerFunc(workerHandler.Fail),
+		),
+	)
+
+	// Dead-letter queue. Redrive runs admission, so it follows the service switch.
+	dlqHandler := &handler.DeadLetterHandler{DLQ: deadLetters, AC: ac}
+
+	mux.Handle(
+		"GET /dlq",
+		middleware.UserOnly(
+			http.HandlerFunc(dlqHandler.List),
+		),
+	)
+
+	mux.Handle(
+		"GET /dlq/{id}",
+		middleware.UserOnly(
+			http.HandlerFunc(dlqHandler.Get),
+		),
+	)
+
+	mux.Handle(
+		"POST /dlq/{id}/redrive",
+		middleware.ServiceRunningOnly(
+			http.HandlerFunc(dlqHandler.Redrive),
+		),
+	)
+
+	mux.Handle(
+		"POST /dlq/redrive",
+		middleware.ServiceRunningOnly(
+			http.HandlerFunc(dlqHandler.RedriveBulk),
+		),
+	)
+
+	mux.Handle(
+		"DELETE /dlq/{id}",
+		middleware.UserOnly(
+			http.HandlerFunc(dlqHandler.Purge),
+		),
+	)
+
+	mux.Handle(
+		"DELETE /dlq",
+		middleware.UserOnly(
+			http.HandlerFunc(dlqHandler.PurgeBulk),
+		),
+	)
+
+	// Policy management. Activating changes what every instance enforces, so these
+	// routes also need POLICY_API_TOKEN and are off without it.
+	if token := os.Getenv("POLICY_API_TOKEN"); token != "" {
+		policyHandler := &handler.PolicyHandler{}
+
+		mux.Handle(
+			"POST /policies",
+			middleware.TokenOnly(token, middleware.UserOnly(
+				http.HandlerFunc(policyHandler.Create),
+			)),
+		)
+
+		mux.Handle(
+			"GET /policies",
+			middleware.TokenOnly(token, middleware.UserOnly(
+				http.HandlerFunc(policyHandler.List),
+			)),
+		)
+
+		mux.Handle(
+			"GET /policies/diff",
+			middleware.TokenOnly(token, middleware.UserOnly(
+				http.HandlerFunc(policyHandler.Diff),
+			)),
+		)
+
+		mux.Handle(
+			"GET /policies/{version}",
+			middleware.TokenOnly(token, middleware.UserOnly(
+				http.HandlerFunc(policyHandler.Get),
+			)),
+		)
+
+		mux.Handle(
+			"POST /policies/{version}/activate",
+			middleware.TokenOnly(token, middleware.UserOnly(
+				http.HandlerFunc(policyHandler.Activate),
+			)),
+		)
+
+		mux.Handle(
+			"POST /policies/rollback",
+			middleware.TokenOnly(token, middleware.UserOnly(
+				http.HandlerFunc(policyHandler.Rollback),
+			)),
+		)
+	} else {
+		slog.Info("policy API disabled, POLICY_API_TOKEN not set")
+	}
+
+	// Permit verification key, public by design
+	permitHandler := &handler.PermitHandler{Signer: ac.Permits}
+	mux.HandleFunc("GET /permits/key", permitHandler.PublicKey)
+
 	server := &http.Server{
 		Addr:         ":8080",
-		Handler:      mux,
+		Handler:      middleware.Audited(middleware.Traced(mux)),
 		ReadTimeout:  5 * time.Second,
 		WriteTimeout: 10 * time.Second,
 	}
 
-	log.Println("HTTP server running on :8080")
+	slog.Info("HTTP server listening", "addr", server.Addr)
 
 	// Start server
 
-	log.Println("Starting server...")
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serveErr:
+		logging.Fatal("server failed", "err", err)
+	case <-ctx.Done():
+	}
+	stop() // a second signal kills the process
+
+	shutdown(server, shutdownTimeout, background, listenerDone, written)
+
+	slog.Info("server stopped")
+}
+
+const defaultShutdownTimeout = 30 * time.Second
 
-	err := server.ListenAndServe()
+// shutdown stops taking requests and lets those in flight finish, stops the background
+// workers, then closes ResultQueue and waits for the DB writers to save what is left in it.
+// Everything shares one deadline, whatever is not done by then is abandoned.
+func shutdown(server *http.Server, timeout time.Duration, background []<-chan struct{}, listenerDone, written <-chan struct{}) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
 
-	log.Println("Server stopped") // runs only when server exits
+	slog.Info("shutting down", "timeout", timeout)
+
+	if err := server.Shutdown(ctx); err != nil {
+		slog.Error("HTTP server did not stop in time", "err", err)
+	}
+
+	// Handlers and background workers are the only ones recording decisions
+	for _, done := range background {
+		waitUntil(ctx, done)
+	}
+	queue.ResultQueue.Close()
+
+	if waitUntil(ctx, written) {
+		slog.Info("result queue drained")
+	} else {
+		slog.Error("shutdown timed out before every decision was saved", "left", queue.ResultDepth(context.Background()))
+	}
+
+	waitUntil(ctx, listenerDone)
+}
+
+// waitUntil waits for done, false if ctx ended first
+func waitUntil(ctx context.Context, done <-chan struct{}) bool {
+	select {
+	case <-done:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// newPermitSigner reads a base64 encoded 32 byte Ed25519 seed
+func newPermitSigner(encoded string) (*permit.Signer, error) {
+	seed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+	return permit.NewSigner(seed)
+}
+
+// dbWriterConfig reads DB_WRITERS (default 4), DB_WRITER_BATCH_SIZE (default 500)
+// and DB_WRITER_FLUSH_INTERVAL (default 50ms)
+func dbWriterConfig() (worker.WriterConfig, error) {
+	cfg := worker.WriterConfig{Writers: 4, BatchSize: 500, FlushInterval: 50 * time.Millisecond}
+
+	if v := os.Getenv("DB_WRITERS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return cfg, fmt.Errorf("DB_WRITERS must be a positive integer")
+		}
+		cfg.Writers = n
+	}
+	if v := os.Getenv("DB_WRITER_BATCH_SIZE"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return cfg, fmt.Errorf("DB_WRITER_BATCH_SIZE must be a positive integer")
+		}
+		cfg.BatchSize = n
+	}
+	if v := os.Getenv("DB_WRITER_FLUSH_INTERVAL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return cfg, fmt.Errorf("DB_WRITER_FLUSH_INTERVAL must be a positive duration")
+		}
+		cfg.FlushInterval = d
+	}
+
+	return cfg, nil
+}
+
+// useResultOverflow reads RESULT_QUEUE_OVERFLOW, what recording a decision does while the
+// in-memory ResultQueue is full: spill (the default) appends it to a segment file in
+// RESULT_SPILL_DIR (default $TMPDIR/janus-spill) for the DB writer to replay later,
+// block waits for room.
+func useResultOverflow() error {
+	switch overflow := os.Getenv("RESULT_QUEUE_OVERFLOW"); overflow {
+	case "block":
+		return nil
+	case "", "spill":
+	default:
+		return fmt.Errorf("RESULT_QUEUE_OVERFLOW must be spill or block, got %q", overflow)
+	}
+
+	dir := os.Getenv("RESULT_SPILL_DIR")
+	if dir == "" {
+		dir = filepath.Join(os.TempDir(), "janus-spill")
+	}
+
+	results, err := queue.NewSpillQueue(queue.SpillConfig{
+		Dir:         dir,
+		Size:        1024,
+		SegmentSize: 64 << 20,
+	}, queue.Codec[*spec.JobDecision]{Encode: spec.EncodeDecision, Decode: spec.DecodeDecision})
+	if err != nil {
+		return err
+	}
+
+	queue.ResultQueue = results
+	slog.Info("spilling decisions to disk when the result queue is full", "dir", dir)
+	return nil
+}
+
+// useStreamQueues replaces the in-memory JobQueue and ResultQueue with Redis streams.
+// Past QUEUE_MAX_LEN entries (default 1000000) acknowledged ones are trimmed.
+func useStreamQueues(ctx context.Context, redisAddr string) error {
+	maxLen := int64(1000000)
+	if v := os.Getenv("QUEUE_MAX_LEN"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n <= 0 {
+			return fmt.Errorf("QUEUE_MAX_LEN must be a positive integer")
+		}
+		maxLen = n
+	}
+
+	consumer, err := os.Hostname()
+	if err != nil {
+		return err
+	}
+
+	client := redis.NewClient(&redis.Options{Addr: redisAddr})
+
+	jobs, err := queue.NewStreamQueue(ctx, client, queue.StreamConfig{
+		Stream:    "janus:stream:jobs",
+		Group:     "janus-executors",
+		Consumer:  consumer,
+		MaxLen:    maxLen,
+		ClaimIdle: time.Minute,
+		Block:     5 * time.Second,
+	}, queue.Codec[spec.Job]{Encode: spec.EncodeJob, Decode: spec.DecodeJob})
+	if err != nil {
+		return err
+	}
 
-	if err != nil && err != http.ErrServerClosed {
-		log.Fatal(err)
+	results, err := queue.NewStreamQueue(ctx, client, queue.StreamConfig{
+		Stream:    "janus:stream:results",
+		Group:     "janus-db-writers",
+		Consumer:  consumer,
+		MaxLen:    maxLen,
+		ClaimIdle: time.Minute,
+		Block:     5 * time.Second,
+	}, queue.Codec[*spec.JobDecision]{Encode: spec.EncodeDecision, Decode: spec.DecodeDecision})
+	if err != nil {
+		return err
 	}
 
+	queue.JobQueue = jobs
+	queue.ResultQueue = results
+	return nil
 }
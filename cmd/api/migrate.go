@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/satyamraj1643/janus/db"
+	"github.com/satyamraj1643/janus/internal/logging"
+)
+
+const migrateUsage = "usage: janus migrate up | down [n|all] | status"
+
+// runMigrate serves `janus migrate`, against the database in DB_URL
+func runMigrate(args []string) {
+	if len(args) == 0 {
+		logging.Fatal(migrateUsage)
+	}
+
+	db.Init()
+	defer db.Pool.Close()
+
+	ctx := context.Background()
+
+	switch args[0] {
+	case "up":
+		applied, err := db.MigrateUp(ctx)
+		if err != nil {
+			logging.Fatal("migrating up failed", "err", err)
+		}
+		if len(applied) == 0 {
+			fmt.Println("schema is up to date")
+		}
+		for _, m := range applied {
+			fmt.Printf("applied %04d_%s\n", m.Version, m.Name)
+		}
+
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			if args[1] == "all" {
+				steps = -1
+			} else if n, err := strconv.Atoi(args[1]); err == nil && n > 0 {
+				steps = n
+			} else {
+				logging.Fatal(migrateUsage)
+			}
+		}
+
+		reverted, err := db.MigrateDown(ctx, steps)
+		if err != nil {
+			logging.Fatal("migrating down failed", "err", err)
+		}
+		if len(reverted) == 0 {
+			fmt.Println("no migration applied")
+		}
+		for _, m := range reverted {
+			fmt.Printf("reverted %04d_%s\n", m.Version, m.Name)
+		}
+
+	case "status":
+		states, err := db.MigrationStatus(ctx)
+		if err != nil {
+			logging.Fatal("reading migration status failed", "err", err)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+		for _, s := range states {
+			at := "pending"
+			if s.AppliedAt != nil {
+				at = s.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(w, "%04d\t%s\t%s\n", s.Version, s.Name, at)
+		}
+		w.Flush()
+
+	default:
+		logging.Fatal(migrateUsage)
+	}
+}
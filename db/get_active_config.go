@@ -3,7 +3,7 @@ package db
 import (
 	"context"
 	"encoding/json"
-	"log"
+	"log/slog"
 )
 
 func GetActiveJanusConfig(userID string) (json.RawMessage, string, bool, error) {
@@ -19,7 +19,7 @@ func GetActiveJanusConfig(userID string) (json.RawMessage, string, bool, error)
 	).Scan(&activeJanusConfig, &configID)
 
 	if err != nil {
-		log.Println("DB error:", err)
+		slog.Error("db query failed", "err", err)
 		return nil, "", false, err
 	}
 
@@ -0,0 +1,65 @@
+package db
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// BatchRecord is a batch with its per-status job counts
+type BatchRecord struct {
+	BatchID      string         `json:"batch_id"`
+	BatchName    string         `json:"batch_name"`
+	CreatedAt    time.Time      `json:"created_at"`
+	TotalJobs    int            `json:"total_jobs"`
+	AdmittedJobs int            `json:"admitted_jobs"`
+	StatusCounts map[string]int `json:"status_counts"`
+}
+
+// GetBatch returns the batch owned by userID, or nil if there is none
+func GetBatch(userID, batchID string) (*BatchRecord, error) {
+	ctx := context.Background()
+
+	var b BatchRecord
+	err := Pool.QueryRow(ctx,
+		`SELECT batch_id, COALESCE(batch_name, ''), created_at, COALESCE(total_jobs, 0), COALESCE(admitted_jobs, 0)
+		 FROM batch WHERE user_id = $1 AND batch_id = $2`,
+		userID, batchID,
+	).Scan(&b.BatchID, &b.BatchName, &b.CreatedAt, &b.TotalJobs, &b.AdmittedJobs)
+
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		slog.Error("db query failed", "err", err)
+		return nil, err
+	}
+
+	rows, err := Pool.Query(ctx,
+		`SELECT job_status, COUNT(*) FROM jobs WHERE user_id = $1 AND batch_id = $2 GROUP BY job_status`,
+		userID, batchID,
+	)
+	if err != nil {
+		slog.Error("db query failed", "err", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	b.StatusCounts = make(map[string]int)
+	for rows.Next() {
+		var status string
+		var count int
+		if err := rows.Scan(&status, &count); err != nil {
+			return nil, err
+		}
+		b.StatusCounts[status] = count
+	}
+	if err := rows.Err(); err != nil {
+		slog.Error("db query failed", "err", err)
+		return nil, err
+	}
+
+	return &b, nil
+}
@@ -2,7 +2,7 @@ package db
 
 import (
 	"context"
-	"log"
+	"log/slog"
 
 	"github.com/jackc/pgx/v5"
 )
@@ -24,14 +24,12 @@ func IsServiceRunning(userID string) (bool, error) {
 
 	if err != nil {
 		if err == pgx.ErrNoRows {
-			log.Println("No service_status row for user:", userID)
+			slog.Debug("no service_status row for user")
 			return false, nil // not running, but NOT an error
 		}
-		log.Println("DB error:", err)
+		slog.Error("reading service status failed", "err", err)
 		return false, err
 	}
 
-	log.Printf("Service status for user %s: %s", userID, status)
 	return status == "running", nil
 }
-
@@ -0,0 +1,179 @@
+package db
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	DefaultJobPageSize = 100
+	MaxJobPageSize     = 1000
+)
+
+// JobRecord is a decision as persisted by SaveJob
+type JobRecord struct {
+	JobID          string          `json:"job_id"`
+	BatchID        string          `json:"batch_id"`
+	TenantID       string          `json:"tenant_id,omitempty"`
+	Status         string          `json:"status"`
+	Reason         string          `json:"reason,omitempty"`
+	GlobalConfigID string          `json:"global_config_id,omitempty"`
+	Payload        json.RawMessage `json:"payload,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+}
+
+// JobFilter narrows ListJobs. Zero values are ignored.
+type JobFilter struct {
+	Status   string
+	TenantID string
+	BatchID  string
+	Reason   string
+	From     time.Time
+	To       time.Time
+	Cursor   string // opaque, from a previous page's NextCursor
+	Limit    int
+}
+
+// JobPage is one page of ListJobs, newest first
+type JobPage struct {
+	Jobs       []JobRecord `json:"jobs"`
+	NextCursor string      `json:"next_cursor,omitempty"`
+}
+
+const jobColumns = `job_id, batch_id, COALESCE(tenant_id, ''), job_status, COALESCE(reason, ''),
+	COALESCE(global_config_id::text, ''), job_payload, created_at`
+
+func scanJob(row pgx.Row) (JobRecord, error) {
+	var j JobRecord
+	err := row.Scan(&j.JobID, &j.BatchID, &j.TenantID, &j.Status, &j.Reason, &j.GlobalConfigID, &j.Payload, &j.CreatedAt)
+	return j, err
+}
+
+// GetJob returns the job owned by userID, or nil if there is none
+func GetJob(userID, jobID string) (*JobRecord, error) {
+	query := `SELECT ` + jobColumns + ` FROM jobs WHERE user_id = $1 AND job_id = $2`
+
+	job, err := scanJob(Pool.QueryRow(context.Background(), query, userID, jobID))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		slog.Error("db query failed", "err", err)
+		return nil, err
+	}
+
+	return &job, nil
+}
+
+// ListJobs returns the user's jobs matching the filter, paginated by (created_at, job_id)
+func ListJobs(userID string, f JobFilter) (*JobPage, error) {
+	limit := f.Limit
+	if limit <= 0 {
+		limit = DefaultJobPageSize
+	}
+	if limit > MaxJobPageSize {
+		limit = MaxJobPageSize
+	}
+
+	conds := []string{"user_id = $1"}
+	args := []any{userID}
+
+	add := func(cond string, val any) {
+		args = append(args, val)
+		conds = append(conds, fmt.Sprintf(cond, len(args)))
+	}
+
+	if f.Status != "" {
+		add("job_status = $%d", f.Status)
+	}
+	if f.TenantID != "" {
+		add("tenant_id = $%d", f.TenantID)
+	}
+	if f.BatchID != "" {
+		add("batch_id = $%d", f.BatchID)
+	}
+	if f.Reason != "" {
+		add("reason = $%d", f.Reason)
+	}
+	if !f.From.IsZero() {
+		add("created_at >= $%d", f.From)
+	}
+	if !f.To.IsZero() {
+		add("created_at < $%d", f.To)
+	}
+	if f.Cursor != "" {
+		ts, id, err := decodeJobCursor(f.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, ts, id)
+		conds = append(conds, fmt.Sprintf("(created_at, job_id) < ($%d, $%d)", len(args)-1, len(args)))
+	}
+
+	// Fetch one extra row to know whether another page exists
+	args = append(args, limit+1)
+	query := fmt.Sprintf(`SELECT %s FROM jobs WHERE %s ORDER BY created_at DESC, job_id DESC LIMIT $%d`,
+		jobColumns, strings.Join(conds, " AND "), len(args))
+
+	rows, err := Pool.Query(context.Background(), query, args...)
+	if err != nil {
+		slog.Error("db query failed", "err", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	page := &JobPage{Jobs: []JobRecord{}}
+	for rows.Next() {
+		job, err := scanJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		page.Jobs = append(page.Jobs, job)
+	}
+	if err := rows.Err(); err != nil {
+		slog.Error("db query failed", "err", err)
+		return nil, err
+	}
+
+	if len(page.Jobs) > limit {
+		page.Jobs = page.Jobs[:limit]
+		last := page.Jobs[limit-1]
+		page.NextCursor = encodeJobCursor(last.CreatedAt, last.JobID)
+	}
+
+	return page, nil
+}
+
+// ErrInvalidCursor is returned for a cursor that was not produced by ListJobs
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+func encodeJobCursor(ts time.Time, jobID string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(ts.UTC().Format(time.RFC3339Nano) + "|" + jobID))
+}
+
+func decodeJobCursor(cursor string) (time.Time, string, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, "", ErrInvalidCursor
+	}
+
+	tsPart, jobID, ok := strings.Cut(string(raw), "|")
+	if !ok {
+		return time.Time{}, "", ErrInvalidCursor
+	}
+
+	ts, err := time.Parse(time.RFC3339Nano, tsPart)
+	if err != nil {
+		return time.Time{}, "", ErrInvalidCursor
+	}
+
+	return ts, jobID, nil
+}
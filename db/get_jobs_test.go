@@ -0,0 +1,33 @@
+package db
+
+import (
+	"encoding/base64"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestJobCursorRoundTrip(t *testing.T) {
+	ts := time.Date(2026, 1, 5, 10, 0, 0, 123456789, time.FixedZone("CET", 3600))
+
+	gotTS, gotID, err := decodeJobCursor(encodeJobCursor(ts, "job|1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !gotTS.Equal(ts) || gotID != "job|1" {
+		t.Fatalf("decoded (%v, %q), want (%v, %q)", gotTS, gotID, ts, "job|1")
+	}
+}
+
+func TestListJobsRejectsForeignCursors(t *testing.T) {
+	for _, cursor := range []string{
+		"not base64!",
+		base64.RawURLEncoding.EncodeToString([]byte("no separator")),
+		base64.RawURLEncoding.EncodeToString([]byte("yesterday|job-1")),
+	} {
+		// Checked before the query runs, so no database is needed
+		if _, err := ListJobs("user-1", JobFilter{Cursor: cursor}); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("cursor %q: got %v, want ErrInvalidCursor", cursor, err)
+		}
+	}
+}
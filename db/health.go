@@ -0,0 +1,31 @@
+package db
+
+import (
+	"context"
+	"errors"
+)
+
+// PoolStats is a snapshot of the connection pool
+type PoolStats struct {
+	TotalConns    int32 `json:"total_conns"`
+	IdleConns     int32 `json:"idle_conns"`
+	AcquiredConns int32 `json:"acquired_conns"`
+	MaxConns      int32 `json:"max_conns"`
+}
+
+// Ping checks that the pool can reach Postgres and reports its state
+func Ping(ctx context.Context) (PoolStats, error) {
+	if Pool == nil {
+		return PoolStats{}, errors.New("not connected")
+	}
+
+	stat := Pool.Stat()
+	stats := PoolStats{
+		TotalConns:    stat.TotalConns(),
+		IdleConns:     stat.IdleConns(),
+		AcquiredConns: stat.AcquiredConns(),
+		MaxConns:      stat.MaxConns(),
+	}
+
+	return stats, Pool.Ping(ctx)
+}
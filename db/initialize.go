@@ -1,43 +1,53 @@
 package db
 
-
 import (
 	"context"
-	"log"
+	"log/slog"
 	"os"
+	"strconv"
 	"time"
+
 	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/satyamraj1643/janus/internal/logging"
 )
 
 var Pool *pgxpool.Pool
 
-func Init(){
+func Init() {
 
 	dsn := os.Getenv("DB_URL")
 
-	if dsn == ""{
-		log.Fatal("DB URL not set.")
+	if dsn == "" {
+		logging.Fatal("DB_URL not set")
 	}
 
 	config, err := pgxpool.ParseConfig(dsn)
 
 	if err != nil {
-		log.Fatal(err)
+		logging.Fatal("invalid DB_URL", "err", err)
 	}
 
+	// DB_MAX_CONNS, leave room for the DB writers next to the handlers
 	config.MaxConns = 10
+	if v := os.Getenv("DB_MAX_CONNS"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 32)
+		if err != nil || n <= 0 {
+			logging.Fatal("DB_MAX_CONNS must be a positive integer", "value", v)
+		}
+		config.MaxConns = int32(n)
+	}
 	config.MinConns = 2
 	config.MaxConnLifetime = time.Hour
 
 	Pool, err = pgxpool.NewWithConfig(context.Background(), config)
 	if err != nil {
-		log.Fatal(err)
+		logging.Fatal("connecting to Postgres failed", "err", err)
 	}
 
 	if err := Pool.Ping(context.Background()); err != nil {
-		log.Fatal(err)
+		logging.Fatal("connecting to Postgres failed", "err", err)
 	}
 
-	log.Println("Postgre Instance connected")
+	slog.Info("connected to Postgres")
 
-}
\ No newline at end of file
+}
@@ -0,0 +1,90 @@
+package db
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/satyamraj1643/janus/internal/lifecycle"
+	"github.com/satyamraj1643/janus/spec"
+)
+
+// JobEvent is one lifecycle transition of a job, as kept in job_events:
+//
+//	CREATE TABLE job_events (
+//	    event_id    BIGSERIAL PRIMARY KEY,
+//	    job_id      TEXT NOT NULL,
+//	    user_id     TEXT NOT NULL,
+//	    from_status TEXT,           -- NULL for the first decision of a job
+//	    to_status   TEXT NOT NULL,
+//	    reason      TEXT,
+//	    attempt     INT NOT NULL DEFAULT 0,
+//	    actor       TEXT NOT NULL,
+//	    occurred_at TIMESTAMPTZ NOT NULL,
+//	    FOREIGN KEY (user_id, job_id) REFERENCES jobs (user_id, job_id) ON DELETE CASCADE
+//	);
+//	CREATE INDEX job_events_job_idx ON job_events (user_id, job_id, event_id);
+type JobEvent struct {
+	FromStatus string    `json:"from_status,omitempty"`
+	ToStatus   string    `json:"to_status"`
+	Reason     string    `json:"reason,omitempty"`
+	Attempt    int       `json:"attempt"`
+	Actor      string    `json:"actor"`
+	OccurredAt time.Time `json:"occurred_at"`
+}
+
+// jobEventColumns are the job_events columns appendJobEvents fills, in order
+var jobEventColumns = []string{"job_id", "user_id", "from_status", "to_status", "reason", "attempt", "actor", "occurred_at"}
+
+// appendJobEvents adds a row for each state of path, the transitions from prev to the decision's status
+func appendJobEvents(rows [][]any, decision *spec.JobDecision, prev string, path []string) [][]any {
+	actor := decision.Actor
+	if actor == "" {
+		actor = lifecycle.ActorJanus
+	}
+
+	from := prev
+	for _, to := range path {
+		var fromStatus *string
+		if from != "" {
+			fromStatus = new(string)
+			*fromStatus = from
+		}
+
+		rows = append(rows, []any{
+			decision.JobID, decision.Job.OwnerID, fromStatus, to, decision.Reason, decision.Attempt, actor, decision.Timestamp,
+		})
+
+		from = to
+	}
+
+	return rows
+}
+
+// GetJobEvents returns the history of a job owned by userID, oldest first.
+// It is empty for unknown jobs.
+func GetJobEvents(userID, jobID string) ([]JobEvent, error) {
+	rows, err := Pool.Query(context.Background(),
+		`SELECT COALESCE(from_status, ''), to_status, COALESCE(reason, ''), attempt, actor, occurred_at
+		 FROM job_events
+		 WHERE user_id = $1 AND job_id = $2
+		 ORDER BY event_id`,
+		userID, jobID,
+	)
+	if err != nil {
+		slog.Error("db query failed", "err", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []JobEvent{}
+	for rows.Next() {
+		var e JobEvent
+		if err := rows.Scan(&e.FromStatus, &e.ToStatus, &e.Reason, &e.Attempt, &e.Actor, &e.OccurredAt); err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+
+	return events, rows.Err()
+}
@@ -0,0 +1,38 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/satyamraj1643/janus/internal/lifecycle"
+	"github.com/satyamraj1643/janus/spec"
+)
+
+func TestAppendJobEvents(t *testing.T) {
+	decision := &spec.JobDecision{
+		JobID:   "job-1",
+		Status:  lifecycle.Retrying,
+		Reason:  "timeout",
+		Attempt: 2,
+		Job:     spec.Job{OwnerID: "owner-a"},
+	}
+
+	rows := appendJobEvents(nil, decision, lifecycle.Running, []string{lifecycle.Failed, lifecycle.Retrying})
+	if len(rows) != 2 {
+		t.Fatalf("got %d rows, want one per state", len(rows))
+	}
+
+	for i, want := range [][2]string{{lifecycle.Running, lifecycle.Failed}, {lifecycle.Failed, lifecycle.Retrying}} {
+		from, _ := rows[i][2].(*string)
+		if from == nil || *from != want[0] || rows[i][3] != want[1] {
+			t.Errorf("row %d: %v -> %v, want %s -> %s", i, from, rows[i][3], want[0], want[1])
+		}
+		if rows[i][6] != lifecycle.ActorJanus {
+			t.Errorf("row %d: actor %v, want janus by default", i, rows[i][6])
+		}
+	}
+
+	first := appendJobEvents(nil, &spec.JobDecision{JobID: "job-2", Status: lifecycle.Accepted}, "", []string{lifecycle.Accepted})
+	if from := first[0][2].(*string); from != nil {
+		t.Errorf("first decision from %q, want NULL", *from)
+	}
+}
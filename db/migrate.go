@@ -0,0 +1,225 @@
+package db
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// migrationLockID serialises instances migrating the same database at startup
+const migrationLockID = 0x6a616e7573 // "janus"
+
+// Migration is one versioned schema change, applied in its own transaction
+type Migration struct {
+	Version int
+	Name    string
+	up      string
+	down    string
+}
+
+// MigrationState is a migration and when it was applied, nil if it was not
+type MigrationState struct {
+	Version   int        `json:"version"`
+	Name      string     `json:"name"`
+	AppliedAt *time.Time `json:"applied_at,omitempty"`
+}
+
+// Migrations returns the embedded migrations, oldest first
+func Migrations() ([]Migration, error) {
+	names, err := fs.Glob(migrationFiles, "migrations/*.up.sql")
+	if err != nil {
+		return nil, err
+	}
+
+	var migrations []Migration
+	for _, path := range names {
+		base := strings.TrimSuffix(strings.TrimPrefix(path, "migrations/"), ".up.sql")
+		version, name, ok := strings.Cut(base, "_")
+		n, err := strconv.Atoi(version)
+		if !ok || err != nil {
+			return nil, fmt.Errorf("migration %s: want <version>_<name>.up.sql", path)
+		}
+
+		up, err := migrationFiles.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		down, err := migrationFiles.ReadFile(strings.TrimSuffix(path, ".up.sql") + ".down.sql")
+		if err != nil {
+			return nil, fmt.Errorf("migration %s has no down script: %w", base, err)
+		}
+
+		migrations = append(migrations, Migration{Version: n, Name: name, up: string(up), down: string(down)})
+	}
+
+	slices.SortFunc(migrations, func(a, b Migration) int { return a.Version - b.Version })
+	return migrations, nil
+}
+
+// MigrateUp applies every migration not applied yet and returns those it applied
+func MigrateUp(ctx context.Context) ([]Migration, error) {
+	migrations, err := Migrations()
+	if err != nil {
+		return nil, err
+	}
+
+	var applied []Migration
+	err = withMigrationLock(ctx, func(conn *pgx.Conn) error {
+		done, err := appliedMigrations(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, m := range migrations {
+			if _, ok := done[m.Version]; ok {
+				continue
+			}
+
+			err := pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+				if _, err := tx.Exec(ctx, m.up); err != nil {
+					return err
+				}
+				_, err := tx.Exec(ctx, `INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`, m.Version, m.Name)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("applying migration %d_%s: %w", m.Version, m.Name, err)
+			}
+
+			slog.Info("applied migration", "version", m.Version, "name", m.Name)
+			applied = append(applied, m)
+		}
+		return nil
+	})
+
+	return applied, err
+}
+
+// MigrateDown reverts the last steps applied migrations, every one if steps < 0,
+// newest first, and returns those it reverted
+func MigrateDown(ctx context.Context, steps int) ([]Migration, error) {
+	migrations, err := Migrations()
+	if err != nil {
+		return nil, err
+	}
+
+	var reverted []Migration
+	err = withMigrationLock(ctx, func(conn *pgx.Conn) error {
+		done, err := appliedMigrations(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, m := range slices.Backward(migrations) {
+			if len(reverted) == steps {
+				break
+			}
+			if _, ok := done[m.Version]; !ok {
+				continue
+			}
+
+			err := pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+				if _, err := tx.Exec(ctx, m.down); err != nil {
+					return err
+				}
+				_, err := tx.Exec(ctx, `DELETE FROM schema_migrations WHERE version = $1`, m.Version)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("reverting migration %d_%s: %w", m.Version, m.Name, err)
+			}
+
+			slog.Info("reverted migration", "version", m.Version, "name", m.Name)
+			reverted = append(reverted, m)
+		}
+		return nil
+	})
+
+	return reverted, err
+}
+
+// MigrationStatus lists every embedded migration and whether it is applied
+func MigrationStatus(ctx context.Context) ([]MigrationState, error) {
+	migrations, err := Migrations()
+	if err != nil {
+		return nil, err
+	}
+
+	var states []MigrationState
+	err = withMigrationLock(ctx, func(conn *pgx.Conn) error {
+		done, err := appliedMigrations(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, m := range migrations {
+			state := MigrationState{Version: m.Version, Name: m.Name}
+			if at, ok := done[m.Version]; ok {
+				state.AppliedAt = &at
+			}
+			states = append(states, state)
+		}
+		return nil
+	})
+
+	return states, err
+}
+
+// withMigrationLock runs fn on one connection while holding the migration lock,
+// creating schema_migrations first if needed
+func withMigrationLock(ctx context.Context, fn func(conn *pgx.Conn) error) error {
+	conn, err := Pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	if _, err := conn.Exec(ctx, `SELECT pg_advisory_lock($1)`, migrationLockID); err != nil {
+		return err
+	}
+	defer conn.Exec(context.Background(), `SELECT pg_advisory_unlock($1)`, migrationLockID)
+
+	_, err = conn.Exec(ctx,
+		`CREATE TABLE IF NOT EXISTS schema_migrations (
+		     version    INT PRIMARY KEY,
+		     name       TEXT NOT NULL,
+		     applied_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		 )`,
+	)
+	if err != nil {
+		return err
+	}
+
+	return fn(conn.Conn())
+}
+
+// appliedMigrations returns when each applied migration was applied, by version
+func appliedMigrations(ctx context.Context, conn *pgx.Conn) (map[int]time.Time, error) {
+	rows, err := conn.Query(ctx, `SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	done := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var at time.Time
+		if err := rows.Scan(&version, &at); err != nil {
+			return nil, err
+		}
+		done[version] = at
+	}
+	return done, rows.Err()
+}
@@ -0,0 +1,54 @@
+package db
+
+import (
+	"io/fs"
+	"strings"
+	"testing"
+)
+
+func TestMigrationsAreOrderedAndPaired(t *testing.T) {
+	migrations, err := Migrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(migrations) == 0 {
+		t.Fatal("no migrations embedded")
+	}
+
+	for i, m := range migrations {
+		if m.Version != i+1 {
+			t.Errorf("migration %d has version %d, want versions to run 1, 2, ... without gaps", i, m.Version)
+		}
+		if m.Name == "" || strings.TrimSpace(m.up) == "" || strings.TrimSpace(m.down) == "" {
+			t.Errorf("migration %d %q has an empty name or script", m.Version, m.Name)
+		}
+	}
+
+	// Every down script belongs to an up script
+	downs, err := fs.Glob(migrationFiles, "migrations/*.down.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(downs) != len(migrations) {
+		t.Errorf("%d down scripts for %d migrations", len(downs), len(migrations))
+	}
+}
+
+// The first migration adopts databases that were set up by hand
+func TestSchemaMigrationAdoptsExistingTables(t *testing.T) {
+	migrations, err := Migrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, line := range strings.Split(migrations[0].up, "\n") {
+		line = strings.TrimSpace(line)
+		creates := strings.HasPrefix(line, "CREATE TABLE") || strings.HasPrefix(line, "CREATE INDEX") || strings.HasPrefix(line, "CREATE UNIQUE INDEX")
+		if creates && !strings.Contains(line, "IF NOT EXISTS") {
+			t.Errorf("not idempotent: %s", line)
+		}
+		if strings.HasPrefix(line, "ALTER TABLE") && strings.Contains(line, "ADD COLUMN") && !strings.Contains(line, "IF NOT EXISTS") {
+			t.Errorf("not idempotent: %s", line)
+		}
+	}
+}
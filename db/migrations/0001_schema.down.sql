@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS user_association;
+DROP TABLE IF EXISTS job_events;
+DROP TABLE IF EXISTS jobs;
+DROP TABLE IF EXISTS batch;
+DROP TABLE IF EXISTS service_status;
+DROP TABLE IF EXISTS global_job_config;
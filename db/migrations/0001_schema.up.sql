@@ -0,0 +1,70 @@
+-- Tables Janus reads and writes. IF NOT EXISTS adopts databases that were set up by hand,
+-- ADD COLUMN IF NOT EXISTS gives their tables the columns Janus added since.
+
+-- A user's policies, at most one active at a time
+CREATE TABLE IF NOT EXISTS global_job_config (
+    config_id  UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id    TEXT NOT NULL,
+    config     JSONB NOT NULL,
+    status     TEXT NOT NULL DEFAULT 'inactive' CHECK (status IN ('active', 'inactive')),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE UNIQUE INDEX IF NOT EXISTS global_job_config_active_idx
+    ON global_job_config (user_id) WHERE status = 'active';
+
+-- Whether Janus admits jobs for a user, anything but 'running' turns them away
+CREATE TABLE IF NOT EXISTS service_status (
+    user_id    TEXT PRIMARY KEY,
+    status     TEXT NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS batch (
+    batch_id      TEXT PRIMARY KEY,
+    user_id       TEXT NOT NULL,
+    batch_name    TEXT,
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    total_jobs    INT NOT NULL DEFAULT 0,
+    admitted_jobs INT NOT NULL DEFAULT 0
+);
+
+-- A job's latest decision, see job_events for its history
+CREATE TABLE IF NOT EXISTS jobs (
+    job_id           TEXT PRIMARY KEY,
+    user_id          TEXT NOT NULL,
+    batch_id         TEXT NOT NULL REFERENCES batch (batch_id),
+    job_status       TEXT NOT NULL,
+    job_payload      JSONB,
+    created_at       TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    reason           TEXT,
+    global_config_id UUID,
+    tenant_id        TEXT
+);
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS tenant_id TEXT;
+CREATE INDEX IF NOT EXISTS jobs_user_created_idx ON jobs (user_id, created_at DESC, job_id DESC);
+CREATE INDEX IF NOT EXISTS jobs_user_batch_idx ON jobs (user_id, batch_id);
+CREATE INDEX IF NOT EXISTS jobs_user_tenant_idx ON jobs (user_id, tenant_id, created_at DESC, job_id DESC);
+
+CREATE TABLE IF NOT EXISTS job_events (
+    event_id    BIGSERIAL PRIMARY KEY,
+    job_id      TEXT NOT NULL REFERENCES jobs (job_id) ON DELETE CASCADE,
+    user_id     TEXT NOT NULL,
+    from_status TEXT,
+    to_status   TEXT NOT NULL,
+    reason      TEXT,
+    attempt     INT NOT NULL DEFAULT 0,
+    actor       TEXT NOT NULL,
+    occurred_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX IF NOT EXISTS job_events_job_idx ON job_events (job_id, event_id);
+
+-- Per config job counters, config_id is unique so the DB writer can upsert on it
+CREATE TABLE IF NOT EXISTS user_association (
+    user_id        TEXT NOT NULL,
+    config_id      UUID NOT NULL UNIQUE,
+    total_jobs     INT NOT NULL DEFAULT 0,
+    succeeded_jobs INT NOT NULL DEFAULT 0,
+    failed_jobs    INT NOT NULL DEFAULT 0,
+    no_of_jobs     INT NOT NULL DEFAULT 0,
+    no_of_batches  INT NOT NULL DEFAULT 0
+);
@@ -0,0 +1,2 @@
+DROP TRIGGER IF EXISTS global_job_config_notify ON global_job_config;
+DROP FUNCTION IF EXISTS janus_notify_config_update();
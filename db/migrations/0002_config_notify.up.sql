@@ -0,0 +1,21 @@
+-- Tells every Janus instance, through StartConfigListener, that a user's configs changed.
+-- The payload is the user ID, listeners reload that user's active config.
+CREATE OR REPLACE FUNCTION janus_notify_config_update() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        PERFORM pg_notify('janus_config_update', OLD.user_id);
+        RETURN OLD;
+    END IF;
+
+    PERFORM pg_notify('janus_config_update', NEW.user_id);
+    IF TG_OP = 'UPDATE' AND OLD.user_id IS DISTINCT FROM NEW.user_id THEN
+        PERFORM pg_notify('janus_config_update', OLD.user_id);
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS global_job_config_notify ON global_job_config;
+CREATE TRIGGER global_job_config_notify
+    AFTER INSERT OR UPDATE OR DELETE ON global_job_config
+    FOR EACH ROW EXECUTE FUNCTION janus_notify_config_update();
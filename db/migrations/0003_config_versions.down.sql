@@ -0,0 +1,8 @@
+DROP TABLE IF EXISTS global_job_config_activations;
+DROP TRIGGER IF EXISTS global_job_config_version ON global_job_config;
+DROP FUNCTION IF EXISTS janus_assign_config_version();
+DROP INDEX IF EXISTS global_job_config_version_idx;
+ALTER TABLE global_job_config
+    DROP COLUMN IF EXISTS version,
+    DROP COLUMN IF EXISTS description,
+    DROP COLUMN IF EXISTS activated_at;
@@ -0,0 +1,53 @@
+-- Numbers each user's configs and records every activation, so configs can be
+-- diffed and rolled back. A config's content never changes once created.
+
+ALTER TABLE global_job_config
+    ADD COLUMN IF NOT EXISTS created_at   TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    ADD COLUMN IF NOT EXISTS version      INT,
+    ADD COLUMN IF NOT EXISTS description  TEXT,
+    ADD COLUMN IF NOT EXISTS activated_at TIMESTAMPTZ;
+
+UPDATE global_job_config c
+SET version = v.n
+FROM (
+    SELECT config_id, ROW_NUMBER() OVER (PARTITION BY user_id ORDER BY created_at, config_id) AS n
+    FROM global_job_config
+) v
+WHERE c.config_id = v.config_id AND c.version IS NULL;
+
+ALTER TABLE global_job_config ALTER COLUMN version SET NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS global_job_config_version_idx ON global_job_config (user_id, version);
+
+-- Configs inserted without a version, by Janus or anyone else, get the user's next one.
+-- The lock also serialises activations, see db.ActivatePolicyVersion.
+CREATE OR REPLACE FUNCTION janus_assign_config_version() RETURNS trigger AS $$
+BEGIN
+    PERFORM pg_advisory_xact_lock(hashtext('janus_config:' || NEW.user_id));
+    IF NEW.version IS NULL THEN
+        SELECT COALESCE(MAX(version), 0) + 1 INTO NEW.version
+        FROM global_job_config WHERE user_id = NEW.user_id;
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS global_job_config_version ON global_job_config;
+CREATE TRIGGER global_job_config_version
+    BEFORE INSERT ON global_job_config
+    FOR EACH ROW EXECUTE FUNCTION janus_assign_config_version();
+
+CREATE TABLE IF NOT EXISTS global_job_config_activations (
+    activation_id BIGSERIAL PRIMARY KEY,
+    user_id       TEXT NOT NULL,
+    config_id     UUID NOT NULL REFERENCES global_job_config (config_id),
+    version       INT NOT NULL,
+    activated_at  TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS global_job_config_activations_user_idx
+    ON global_job_config_activations (user_id, activation_id DESC);
+
+-- Configs active before history was kept
+INSERT INTO global_job_config_activations (user_id, config_id, version)
+SELECT user_id, config_id, version FROM global_job_config
+WHERE status = 'active'
+  AND NOT EXISTS (SELECT 1 FROM global_job_config_activations a WHERE a.user_id = global_job_config.user_id);
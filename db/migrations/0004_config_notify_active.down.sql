@@ -0,0 +1,15 @@
+-- Back to notifying on every change, as 0002 did
+CREATE OR REPLACE FUNCTION janus_notify_config_update() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        PERFORM pg_notify('janus_config_update', OLD.user_id);
+        RETURN OLD;
+    END IF;
+
+    PERFORM pg_notify('janus_config_update', NEW.user_id);
+    IF TG_OP = 'UPDATE' AND OLD.user_id IS DISTINCT FROM NEW.user_id THEN
+        PERFORM pg_notify('janus_config_update', OLD.user_id);
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
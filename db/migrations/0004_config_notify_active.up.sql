@@ -0,0 +1,34 @@
+-- Notifies only when a user's active config changes: one is inserted active, a row
+-- turns active or inactive, the active one's config changes, or it is deleted.
+-- Drafts inserted or edited while inactive no longer reload configs and reset quotas.
+CREATE OR REPLACE FUNCTION janus_notify_config_update() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        IF NEW.status = 'active' THEN
+            PERFORM pg_notify('janus_config_update', NEW.user_id);
+        END IF;
+        RETURN NEW;
+    END IF;
+
+    IF TG_OP = 'DELETE' THEN
+        IF OLD.status = 'active' THEN
+            PERFORM pg_notify('janus_config_update', OLD.user_id);
+        END IF;
+        RETURN OLD;
+    END IF;
+
+    IF OLD.status IS NOT DISTINCT FROM NEW.status
+        AND OLD.config IS NOT DISTINCT FROM NEW.config
+        AND OLD.user_id IS NOT DISTINCT FROM NEW.user_id THEN
+        RETURN NEW;
+    END IF;
+
+    IF NEW.status = 'active' THEN
+        PERFORM pg_notify('janus_config_update', NEW.user_id);
+    END IF;
+    IF OLD.status = 'active' AND (NEW.status IS DISTINCT FROM 'active' OR OLD.user_id IS DISTINCT FROM NEW.user_id) THEN
+        PERFORM pg_notify('janus_config_update', OLD.user_id);
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
@@ -0,0 +1,9 @@
+-- Fails while two users hold the same job ID
+DROP INDEX IF EXISTS job_events_job_idx;
+CREATE INDEX IF NOT EXISTS job_events_job_idx ON job_events (job_id, event_id);
+
+ALTER TABLE job_events DROP CONSTRAINT IF EXISTS job_events_job_fkey;
+ALTER TABLE jobs DROP CONSTRAINT IF EXISTS jobs_pkey;
+ALTER TABLE jobs ADD CONSTRAINT jobs_pkey PRIMARY KEY (job_id);
+ALTER TABLE job_events ADD CONSTRAINT job_events_job_id_fkey
+    FOREIGN KEY (job_id) REFERENCES jobs (job_id) ON DELETE CASCADE;
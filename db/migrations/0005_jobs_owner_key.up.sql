@@ -0,0 +1,11 @@
+-- Job IDs are unique per user, not globally: two users may submit the same ID.
+-- Jobs and their events are keyed by (user_id, job_id).
+
+ALTER TABLE job_events DROP CONSTRAINT IF EXISTS job_events_job_id_fkey;
+ALTER TABLE jobs DROP CONSTRAINT IF EXISTS jobs_pkey;
+ALTER TABLE jobs ADD CONSTRAINT jobs_pkey PRIMARY KEY (user_id, job_id);
+ALTER TABLE job_events ADD CONSTRAINT job_events_job_fkey
+    FOREIGN KEY (user_id, job_id) REFERENCES jobs (user_id, job_id) ON DELETE CASCADE;
+
+DROP INDEX IF EXISTS job_events_job_idx;
+CREATE INDEX IF NOT EXISTS job_events_job_idx ON job_events (user_id, job_id, event_id);
@@ -0,0 +1,201 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ErrNoPreviousPolicy is returned when rolling back a user that never activated another version
+var ErrNoPreviousPolicy = errors.New("no previous policy version")
+
+// PolicyVersion is one version of a user's config in global_job_config
+type PolicyVersion struct {
+	ConfigID    string          `json:"config_id"`
+	Version     int             `json:"version"`
+	Status      string          `json:"status"` // active | inactive
+	Description string          `json:"description,omitempty"`
+	Config      json.RawMessage `json:"config"`
+	CreatedAt   time.Time       `json:"created_at"`
+	ActivatedAt *time.Time      `json:"activated_at,omitempty"` // last time it was activated
+}
+
+const policyColumns = `config_id::text, version, status, COALESCE(description, ''), config, created_at, activated_at`
+
+func scanPolicy(row pgx.Row) (PolicyVersion, error) {
+	var p PolicyVersion
+	err := row.Scan(&p.ConfigID, &p.Version, &p.Status, &p.Description, &p.Config, &p.CreatedAt, &p.ActivatedAt)
+	return p, err
+}
+
+// CreatePolicyVersion stores config as the user's next version, inactive
+func CreatePolicyVersion(userID string, config json.RawMessage, description string) (*PolicyVersion, error) {
+	p, err := scanPolicy(Pool.QueryRow(context.Background(),
+		`INSERT INTO global_job_config (user_id, config, status, description)
+		 VALUES ($1, $2, 'inactive', NULLIF($3, ''))
+		 RETURNING `+policyColumns,
+		userID, config, description,
+	))
+	if err != nil {
+		slog.Error("creating policy version failed", "err", err)
+		return nil, err
+	}
+	return &p, nil
+}
+
+// ListPolicyVersions returns every version of the user's config, newest first
+func ListPolicyVersions(userID string) ([]PolicyVersion, error) {
+	rows, err := Pool.Query(context.Background(),
+		`SELECT `+policyColumns+` FROM global_job_config WHERE user_id = $1 ORDER BY version DESC`,
+		userID,
+	)
+	if err != nil {
+		slog.Error("db query failed", "err", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	versions := []PolicyVersion{}
+	for rows.Next() {
+		p, err := scanPolicy(rows)
+		if err != nil {
+			return nil, err
+		}
+		versions = append(versions, p)
+	}
+	return versions, rows.Err()
+}
+
+// GetPolicyVersion returns the version, or nil if the user has no such version
+func GetPolicyVersion(userID string, version int) (*PolicyVersion, error) {
+	p, err := scanPolicy(Pool.QueryRow(context.Background(),
+		`SELECT `+policyColumns+` FROM global_job_config WHERE user_id = $1 AND version = $2`,
+		userID, version,
+	))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		slog.Error("db query failed", "err", err)
+		return nil, err
+	}
+	return &p, nil
+}
+
+// ActivatePolicyVersion makes the version the user's active config and records the activation.
+// Janus instances pick it up through janus_config_update once the transaction commits.
+// Returns nil if the user has no such version.
+func ActivatePolicyVersion(userID string, version int) (*PolicyVersion, error) {
+	var activated *PolicyVersion
+
+	err := pgx.BeginFunc(context.Background(), Pool, func(tx pgx.Tx) error {
+		var err error
+		activated, err = activate(context.Background(), tx, userID, version)
+		return err
+	})
+	if err != nil {
+		slog.Error("activating policy version failed", "err", err)
+		return nil, err
+	}
+	return activated, nil
+}
+
+// RollbackPolicy reactivates the version that was active before the current one
+func RollbackPolicy(userID string) (*PolicyVersion, error) {
+	var activated *PolicyVersion
+
+	err := pgx.BeginFunc(context.Background(), Pool, func(tx pgx.Tx) error {
+		ctx := context.Background()
+		if err := lockPolicies(ctx, tx, userID); err != nil {
+			return err
+		}
+
+		// The latest activation of another version than the last one
+		var previous int
+		err := tx.QueryRow(ctx,
+			`SELECT version FROM global_job_config_activations
+			 WHERE user_id = $1
+			   AND version <> (SELECT version FROM global_job_config_activations
+			                   WHERE user_id = $1 ORDER BY activation_id DESC LIMIT 1)
+			 ORDER BY activation_id DESC
+			 LIMIT 1`,
+			userID,
+		).Scan(&previous)
+		if err == pgx.ErrNoRows {
+			return ErrNoPreviousPolicy
+		}
+		if err != nil {
+			return err
+		}
+
+		activated, err = activate(ctx, tx, userID, previous)
+		return err
+	})
+	if err != nil && err != ErrNoPreviousPolicy {
+		slog.Error("rolling back policy failed", "err", err)
+	}
+	return activated, err
+}
+
+// activate switches the user's active config to version within tx, a no-op if it already is
+func activate(ctx context.Context, tx pgx.Tx, userID string, version int) (*PolicyVersion, error) {
+	if err := lockPolicies(ctx, tx, userID); err != nil {
+		return nil, err
+	}
+
+	current, err := scanPolicy(tx.QueryRow(ctx,
+		`SELECT `+policyColumns+` FROM global_job_config WHERE user_id = $1 AND version = $2`,
+		userID, version,
+	))
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if current.Status == "active" {
+		return &current, nil
+	}
+
+	// One active config per user, the old one goes first
+	if _, err := tx.Exec(ctx,
+		`UPDATE global_job_config SET status = 'inactive' WHERE user_id = $1 AND status = 'active'`,
+		userID,
+	); err != nil {
+		return nil, err
+	}
+
+	activated, err := scanPolicy(tx.QueryRow(ctx,
+		`UPDATE global_job_config SET status = 'active', activated_at = NOW()
+		 WHERE config_id = $1
+		 RETURNING `+policyColumns,
+		current.ConfigID,
+	))
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.Exec(ctx,
+		`INSERT INTO global_job_config_activations (user_id, config_id, version) VALUES ($1, $2, $3)`,
+		userID, activated.ConfigID, activated.Version,
+	); err != nil {
+		return nil, err
+	}
+
+	// The trigger from the migrations notifies too, Postgres folds identical notifications
+	if _, err := tx.Exec(ctx, `SELECT pg_notify('janus_config_update', $1)`, userID); err != nil {
+		return nil, err
+	}
+
+	return &activated, nil
+}
+
+// lockPolicies serialises changes to the user's configs until tx ends, the same lock new versions take
+func lockPolicies(ctx context.Context, tx pgx.Tx, userID string) error {
+	_, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock(hashtext('janus_config:' || $1::text))`, userID)
+	return err
+}
@@ -3,100 +3,371 @@ package db
 import (
 	"context"
 	"encoding/json"
-	"log"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
 
+	"github.com/jackc/pgx/v5"
+	"github.com/satyamraj1643/janus/internal/lifecycle"
 	"github.com/satyamraj1643/janus/spec"
 )
 
-func SaveJob(decision *spec.JobDecision) error {
-	ctx := context.Background()
-	job := decision.Job
+// SaveJobs persists decisions, in order, in one transaction. A job is saved once per
+// state it moves through, see internal/lifecycle, and a decision that does not follow
+// the stored status (or the one before it in decisions) is refused with a
+// *lifecycle.TransitionError in its slot of the returned slice; the others are still saved.
+// duplicate_request rejections are skipped, they answer a submission that is still
+// being decided and say nothing about the stored job.
+// The returned error is set when nothing was saved.
+//
+// Each job is written once with its last status and its events are copied in bulk.
+// The batch and user_association counters are bumped once per row by what the
+// decisions changed.
+func SaveJobs(ctx context.Context, decisions []*spec.JobDecision) ([]error, error) {
+	errs := make([]error, len(decisions))
+	if len(decisions) == 0 {
+		return errs, nil
+	}
 
-	admittedInc := 0
-	if decision.Status == "accepted" {
-		admittedInc = 1
+	tx, err := Pool.Begin(ctx)
+	if err != nil {
+		return nil, err
 	}
+	defer tx.Rollback(ctx)
 
-	// 1. Upsert Batch
-	// Check if this is a new batch insertion to update user_association later
-	var isNewBatch bool
+	if err := saveJobs(ctx, tx, decisions, errs); err != nil {
+		return nil, err
+	}
 
-	// Using xmax = 0 to detect if it was an insert.
-	// Note: In some PG versions/configurations this might be tricky, but typically xmax=0 implies insertion.
-	// Alternative: we can't easily get 'is_new' from ON CONFLICT without xmax check or semantic difference.
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("committing: %w", err)
+	}
 
-	// We init total_jobs=1 because this current job is part of it.
-	err := Pool.QueryRow(ctx,
-		`INSERT INTO batch (batch_id, user_id, batch_name, created_at, total_jobs, admitted_jobs) 
-		 VALUES ($1, $2, $3, NOW(), 1, $4)
-		 ON CONFLICT (batch_id) DO UPDATE 
-		 SET total_jobs = batch.total_jobs + 1, 
-		     admitted_jobs = batch.admitted_jobs + $4
-		 RETURNING (xmax = 0)`,
-		decision.BatchID, job.OwnerID, decision.BatchName, admittedInc,
-	).Scan(&isNewBatch)
+	return errs, nil
+}
 
+// saveJobs runs SaveJobs in tx, setting errs for the decisions it refuses
+func saveJobs(ctx context.Context, tx pgx.Tx, decisions []*spec.JobDecision, errs []error) error {
+	// 1. Ensure the batches. Counters start at 0 and are bumped below once we
+	// know what the decisions changed.
+	newBatches, err := insertBatches(ctx, tx, decisions)
 	if err != nil {
-		log.Printf("Error upserting batch %s for job %s: %v", decision.BatchID, decision.JobID, err)
-		// We continue, but aware that batch stats might be desynced or FK failed.
-		// If FK failed (user not found), the next insert (jobs) will also likely fail if it relies on same user.
-	}
-
-	batchInc := 0
-	if isNewBatch {
-		batchInc = 1
-	}
-
-	// 2. Insert/Update Job
-	payloadBytes, _ := json.Marshal(job.Payload)
-
-	query := `
-		INSERT INTO jobs (job_id, user_id, batch_id, job_status, job_payload, created_at, reason, global_config_id)
-		VALUES ($1, $2, $3, $4, $5, NOW(), $6, $7)
-		ON CONFLICT (job_id) DO UPDATE 
-		SET job_status = $4, job_payload = $5, reason = $6
-	`
-
-	_, err = Pool.Exec(ctx, query,
-		decision.JobID,
-		job.OwnerID,
-		decision.BatchID, // TEXT type now
-		decision.Status,
-		payloadBytes,
-		decision.Reason,
-		job.GlobalConfigID,
-	)
+		return fmt.Errorf("upserting batches: %w", err)
+	}
 
+	// 2. Lock the stored statuses for the rest of the transaction,
+	// so concurrent saves of one job apply in turn
+	status, err := lockJobs(ctx, tx, decisions)
 	if err != nil {
-		log.Printf("Failed to save job %s: %v", decision.JobID, err)
-		return err
-	}
-
-	// 3. Update User Association Stats
-	if job.GlobalConfigID != "" {
-		succeededInc := 0
-		failedInc := 0
-		if decision.Status == "accepted" {
-			succeededInc = 1
-		} else {
-			failedInc = 1
+		return fmt.Errorf("reading jobs: %w", err)
+	}
+
+	// 3. Walk the decisions through the lifecycle
+	var (
+		rows    = make(map[jobKey]*spec.JobDecision) // job -> last saved decision
+		order   []jobKey                             // jobs in first saved order
+		events  [][]any
+		batches = make(map[string]*batchDelta)
+		configs = make(map[string]*configDelta)
+	)
+
+	for i, decision := range decisions {
+		if decision.Reason == "duplicate_request" {
+			continue
 		}
 
-		_, err = Pool.Exec(ctx,
-			`INSERT INTO user_association (user_id, config_id, total_jobs, succeeded_jobs, failed_jobs, no_of_jobs, no_of_batches)
-			 VALUES ($3, $4, 1, $1, $2, 1, $5)
-			 ON CONFLICT (config_id) DO UPDATE 
-			 SET total_jobs = COALESCE(user_association.total_jobs, 0) + 1, 
-			     succeeded_jobs = COALESCE(user_association.succeeded_jobs, 0) + $1, 
-				 failed_jobs = COALESCE(user_association.failed_jobs, 0) + $2,
-				 no_of_jobs = COALESCE(user_association.no_of_jobs, 0) + 1,
-				 no_of_batches = COALESCE(user_association.no_of_batches, 0) + $5`,
-			succeededInc, failedInc, job.OwnerID, job.GlobalConfigID, batchInc,
-		)
+		key := jobKey{decision.Job.OwnerID, decision.JobID}
+		prev, known := status[key]
+
+		path, err := lifecycle.Path(prev, decision.Status)
 		if err != nil {
-			log.Printf("Error updating user_association for user %s config %s: %v", job.OwnerID, job.GlobalConfigID, err)
+			errs[i] = err
+			continue
+		}
+		if len(path) == 0 {
+			continue
+		}
+
+		if _, ok := rows[key]; !ok {
+			order = append(order, key)
+		}
+		rows[key] = decision
+		status[key] = decision.Status
+		events = appendJobEvents(events, decision, prev, path)
+
+		newJob := !known // the first save of a job counts it
+		admitted := decision.Status == lifecycle.Accepted && prev != lifecycle.Accepted
+		failed := isFailedStatus(decision.Status) && !isFailedStatus(prev)
+
+		b := batches[decision.BatchID]
+		if b == nil {
+			b = &batchDelta{}
+			batches[decision.BatchID] = b
+		}
+		if newJob {
+			b.total++
+		}
+		if admitted {
+			b.admitted++
+		}
+
+		if configID := decision.Job.GlobalConfigID; configID != "" {
+			c := configs[configID]
+			if c == nil {
+				c = &configDelta{userID: decision.Job.OwnerID}
+				configs[configID] = c
+			}
+			if newJob {
+				c.total++
+			}
+			if admitted {
+				c.succeeded++
+			}
+			if failed {
+				c.failed++
+			}
+			if newBatches[decision.BatchID] && !c.batches[decision.BatchID] {
+				if c.batches == nil {
+					c.batches = make(map[string]bool)
+				}
+				c.batches[decision.BatchID] = true
+			}
+		}
+	}
+
+	if len(order) > 0 {
+		for chunk := range slices.Chunk(order, maxUpsertRows) {
+			if err := upsertJobs(ctx, tx, chunk, rows); err != nil {
+				return fmt.Errorf("saving jobs: %w", err)
+			}
+		}
+
+		if _, err := tx.CopyFrom(ctx, pgx.Identifier{"job_events"}, jobEventColumns, pgx.CopyFromRows(events)); err != nil {
+			return fmt.Errorf("recording job events: %w", err)
 		}
 	}
 
+	// 4. Counters last, the batch rows are shared by every writer
+	if err := updateBatchStats(ctx, tx, batches); err != nil {
+		return fmt.Errorf("updating batch stats: %w", err)
+	}
+	if err := updateUserAssociations(ctx, tx, configs); err != nil {
+		return fmt.Errorf("updating user_association: %w", err)
+	}
 	return nil
 }
+
+// jobKey identifies a stored job, job IDs are only unique per user
+type jobKey struct {
+	userID, jobID string
+}
+
+func (k jobKey) compare(o jobKey) int {
+	if c := strings.Compare(k.userID, o.userID); c != 0 {
+		return c
+	}
+	return strings.Compare(k.jobID, o.jobID)
+}
+
+// maxUpsertRows keeps a multi-row jobs upsert under Postgres' 65535 bind parameters
+const maxUpsertRows = 4096
+
+type batchDelta struct {
+	total, admitted int
+}
+
+type configDelta struct {
+	userID                   string
+	total, succeeded, failed int
+	batches                  map[string]bool // batches created by this save
+}
+
+// insertBatches creates the decisions' batches that do not exist yet and returns those it created
+func insertBatches(ctx context.Context, tx pgx.Tx, decisions []*spec.JobDecision) (map[string]bool, error) {
+	byID := make(map[string]*spec.JobDecision)
+	for _, d := range decisions {
+		if _, ok := byID[d.BatchID]; !ok {
+			byID[d.BatchID] = d
+		}
+	}
+
+	// Sorted, so concurrent saves take the rows in the same order
+	ids := slices.Sorted(maps.Keys(byID))
+
+	var values []string
+	var args []any
+	for _, id := range ids {
+		d := byID[id]
+		n := len(args)
+		values = append(values, fmt.Sprintf("($%d, $%d, $%d, NOW(), 0, 0)", n+1, n+2, n+3))
+		args = append(args, id, d.Job.OwnerID, d.BatchName)
+	}
+
+	rows, err := tx.Query(ctx,
+		`INSERT INTO batch (batch_id, user_id, batch_name, created_at, total_jobs, admitted_jobs)
+		 VALUES `+strings.Join(values, ", ")+`
+		 ON CONFLICT (batch_id) DO NOTHING
+		 RETURNING batch_id`,
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	inserted, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, err
+	}
+
+	created := make(map[string]bool, len(inserted))
+	for _, id := range inserted {
+		created[id] = true
+	}
+	return created, nil
+}
+
+// lockJobs returns the stored status of the decisions' jobs, locking their rows.
+// Jobs not saved yet are missing from the map.
+func lockJobs(ctx context.Context, tx pgx.Tx, decisions []*spec.JobDecision) (map[jobKey]string, error) {
+	keys := make([]jobKey, 0, len(decisions))
+	for _, d := range decisions {
+		keys = append(keys, jobKey{d.Job.OwnerID, d.JobID})
+	}
+	slices.SortFunc(keys, jobKey.compare)
+	keys = slices.Compact(keys)
+
+	userIDs := make([]string, len(keys))
+	jobIDs := make([]string, len(keys))
+	for i, k := range keys {
+		userIDs[i], jobIDs[i] = k.userID, k.jobID
+	}
+
+	rows, err := tx.Query(ctx,
+		`SELECT user_id, job_id, COALESCE(job_status, '') FROM jobs
+		 WHERE (user_id, job_id) IN (SELECT * FROM unnest($1::text[], $2::text[]))
+		 ORDER BY user_id, job_id FOR UPDATE`,
+		userIDs, jobIDs,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	status := make(map[jobKey]string, len(keys))
+	for rows.Next() {
+		var k jobKey
+		var s string
+		if err := rows.Scan(&k.userID, &k.jobID, &s); err != nil {
+			return nil, err
+		}
+		status[k] = s
+	}
+	return status, rows.Err()
+}
+
+// upsertJobs writes every job once, with its last decision
+func upsertJobs(ctx context.Context, tx pgx.Tx, order []jobKey, last map[jobKey]*spec.JobDecision) error {
+	var values []string
+	var args []any
+	for _, key := range order {
+		d := last[key]
+		payload, _ := json.Marshal(d.Job.Payload)
+
+		n := len(args)
+		values = append(values, fmt.Sprintf("($%d, $%d, $%d, $%d, $%d, NOW(), $%d, $%d, $%d)",
+			n+1, n+2, n+3, n+4, n+5, n+6, n+7, n+8))
+		args = append(args,
+			d.JobID,
+			d.Job.OwnerID,
+			d.BatchID, // TEXT type now
+			d.Status,
+			payload,
+			d.Reason,
+			nullIfEmpty(d.Job.GlobalConfigID),
+			d.Job.TenantID,
+		)
+	}
+
+	_, err := tx.Exec(ctx,
+		`INSERT INTO jobs (job_id, user_id, batch_id, job_status, job_payload, created_at, reason, global_config_id, tenant_id)
+		 VALUES `+strings.Join(values, ", ")+`
+		 ON CONFLICT (user_id, job_id) DO UPDATE
+		 SET job_status = EXCLUDED.job_status, job_payload = EXCLUDED.job_payload, reason = EXCLUDED.reason`,
+		args...,
+	)
+	return err
+}
+
+// updateBatchStats applies the summed counter changes, one row update per batch
+func updateBatchStats(ctx context.Context, tx pgx.Tx, deltas map[string]*batchDelta) error {
+	var ids []string
+	var totals, admitted []int
+	for _, id := range slices.Sorted(maps.Keys(deltas)) {
+		d := deltas[id]
+		if d.total == 0 && d.admitted == 0 {
+			continue
+		}
+		ids = append(ids, id)
+		totals = append(totals, d.total)
+		admitted = append(admitted, d.admitted)
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	_, err := tx.Exec(ctx,
+		`UPDATE batch
+		 SET total_jobs = COALESCE(batch.total_jobs, 0) + d.total,
+		     admitted_jobs = COALESCE(batch.admitted_jobs, 0) + d.admitted
+		 FROM unnest($1::text[], $2::int[], $3::int[]) AS d(batch_id, total, admitted)
+		 WHERE batch.batch_id = d.batch_id`,
+		ids, totals, admitted,
+	)
+	return err
+}
+
+// updateUserAssociations applies the summed counter changes, one row upsert per config
+func updateUserAssociations(ctx context.Context, tx pgx.Tx, deltas map[string]*configDelta) error {
+	var values []string
+	var args []any
+	for _, configID := range slices.Sorted(maps.Keys(deltas)) {
+		d := deltas[configID]
+		if d.total == 0 && d.succeeded == 0 && d.failed == 0 {
+			continue
+		}
+
+		n := len(args)
+		values = append(values, fmt.Sprintf("($%d, $%d, $%d, $%d, $%d, $%d, $%d)", n+1, n+2, n+3, n+4, n+5, n+3, n+6))
+		args = append(args, d.userID, configID, d.total, d.succeeded, d.failed, len(d.batches))
+	}
+	if len(values) == 0 {
+		return nil
+	}
+
+	_, err := tx.Exec(ctx,
+		`INSERT INTO user_association (user_id, config_id, total_jobs, succeeded_jobs, failed_jobs, no_of_jobs, no_of_batches)
+		 VALUES `+strings.Join(values, ", ")+`
+		 ON CONFLICT (config_id) DO UPDATE
+		 SET total_jobs = COALESCE(user_association.total_jobs, 0) + EXCLUDED.total_jobs,
+		     succeeded_jobs = COALESCE(user_association.succeeded_jobs, 0) + EXCLUDED.succeeded_jobs,
+		     failed_jobs = COALESCE(user_association.failed_jobs, 0) + EXCLUDED.failed_jobs,
+		     no_of_jobs = COALESCE(user_association.no_of_jobs, 0) + EXCLUDED.no_of_jobs,
+		     no_of_batches = COALESCE(user_association.no_of_batches, 0) + EXCLUDED.no_of_batches`,
+		args...,
+	)
+	return err
+}
+
+// nullIfEmpty stores "" as NULL, for columns where "" is not a valid value
+func nullIfEmpty(s string) any {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+// isFailedStatus reports whether a job in this status was turned away or gave up for good
+func isFailedStatus(status string) bool {
+	return status == "rejected" || status == "expired" || status == "dead"
+}
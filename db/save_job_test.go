@@ -0,0 +1,226 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/satyamraj1643/janus/spec"
+)
+
+// recordingTx keeps the statements run through Exec, every other method panics
+type recordingTx struct {
+	pgx.Tx
+	sql  []string
+	args [][]any
+}
+
+func (tx *recordingTx) Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	tx.sql = append(tx.sql, sql)
+	tx.args = append(tx.args, args)
+	return pgconn.CommandTag{}, nil
+}
+
+var placeholder = regexp.MustCompile(`\$(\d+)`)
+
+// checkPlaceholders fails unless the statement's highest placeholder is its last argument
+func checkPlaceholders(t *testing.T, sql string, args []any) {
+	t.Helper()
+	highest := 0
+	for _, m := range placeholder.FindAllStringSubmatch(sql, -1) {
+		n, _ := strconv.Atoi(m[1])
+		highest = max(highest, n)
+	}
+	if highest != len(args) {
+		t.Fatalf("statement uses $%d with %d args:\n%s", highest, len(args), sql)
+	}
+}
+
+func TestUpsertJobsWritesOneStatement(t *testing.T) {
+	tx := &recordingTx{}
+	last := make(map[jobKey]*spec.JobDecision)
+	var order []jobKey
+	for i := range 3 {
+		key := jobKey{"owner-a", fmt.Sprintf("job-%d", i)}
+		order = append(order, key)
+		last[key] = &spec.JobDecision{JobID: key.jobID, Status: "accepted", Job: spec.Job{OwnerID: key.userID}}
+	}
+
+	if err := upsertJobs(context.Background(), tx, order, last); err != nil {
+		t.Fatal(err)
+	}
+	if len(tx.sql) != 1 {
+		t.Fatalf("%d statements, want one for every job", len(tx.sql))
+	}
+	checkPlaceholders(t, tx.sql[0], tx.args[0])
+	if rows := strings.Count(tx.sql[0], "NOW()"); rows != 3 {
+		t.Fatalf("%d rows, want 3", rows)
+	}
+	// No config ID is stored as NULL
+	if tx.args[0][6] != nil {
+		t.Fatalf("global_config_id %v, want NULL", tx.args[0][6])
+	}
+}
+
+// jobsTx serves lockJobs from stored and keeps the copied events, on top of recordingTx
+type jobsTx struct {
+	recordingTx
+	stored map[jobKey]string
+	events [][]any
+}
+
+func (tx *jobsTx) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	rows := &fakeRows{}
+	if strings.Contains(sql, "FROM jobs") {
+		userIDs, jobIDs := args[0].([]string), args[1].([]string)
+		for i := range userIDs {
+			if s, ok := tx.stored[jobKey{userIDs[i], jobIDs[i]}]; ok {
+				rows.data = append(rows.data, []any{userIDs[i], jobIDs[i], s})
+			}
+		}
+	}
+	return rows, nil
+}
+
+func (tx *jobsTx) CopyFrom(ctx context.Context, table pgx.Identifier, columns []string, src pgx.CopyFromSource) (int64, error) {
+	for src.Next() {
+		values, err := src.Values()
+		if err != nil {
+			return 0, err
+		}
+		tx.events = append(tx.events, values)
+	}
+	return int64(len(tx.events)), nil
+}
+
+// fakeRows scans string columns from data
+type fakeRows struct {
+	pgx.Rows
+	data [][]any
+	next int
+}
+
+func (r *fakeRows) Next() bool {
+	r.next++
+	return r.next <= len(r.data)
+}
+
+func (r *fakeRows) Scan(dest ...any) error {
+	for i, d := range dest {
+		*d.(*string) = r.data[r.next-1][i].(string)
+	}
+	return nil
+}
+
+func (r *fakeRows) Close()     {}
+func (r *fakeRows) Err() error { return nil }
+
+func TestSaveJobsKeepsOwnersApart(t *testing.T) {
+	tx := &jobsTx{stored: map[jobKey]string{{"owner-a", "job-1"}: "accepted"}}
+	decisions := []*spec.JobDecision{
+		{JobID: "job-1", BatchID: "b", Status: "accepted", Job: spec.Job{OwnerID: "owner-b"}},
+		{JobID: "job-1", BatchID: "a", Status: "leased", Job: spec.Job{OwnerID: "owner-a"}},
+	}
+
+	errs := make([]error, len(decisions))
+	if err := saveJobs(context.Background(), tx, decisions, errs); err != nil {
+		t.Fatal(err)
+	}
+	for i, err := range errs {
+		if err != nil {
+			t.Fatalf("decision %d refused: %v", i, err)
+		}
+	}
+
+	// owner-b's job is new, owner-a's moves on from its own status
+	if len(tx.events) != 2 {
+		t.Fatalf("%d events, want 2", len(tx.events))
+	}
+	if from := tx.events[0][2].(*string); tx.events[0][1] != "owner-b" || from != nil {
+		t.Fatalf("owner-b event %v, want a first save", tx.events[0])
+	}
+	if from := tx.events[1][2].(*string); tx.events[1][1] != "owner-a" || from == nil || *from != "accepted" {
+		t.Fatalf("owner-a event %v, want accepted -> leased", tx.events[1])
+	}
+
+	upsert := tx.sql[0]
+	if !strings.Contains(upsert, "ON CONFLICT (user_id, job_id)") || strings.Count(upsert, "NOW()") != 2 {
+		t.Fatalf("upsert does not write one row per owner:\n%s", upsert)
+	}
+}
+
+func TestSaveJobsRecordsResubmissionsButNotDuplicates(t *testing.T) {
+	tx := &jobsTx{stored: map[jobKey]string{
+		{"owner-a", "job-1"}: "accepted",
+		{"owner-a", "job-2"}: "deferred",
+	}}
+	decisions := []*spec.JobDecision{
+		// job-1 submitted again after its idempotency window
+		{JobID: "job-1", BatchID: "a", Status: "accepted", Job: spec.Job{OwnerID: "owner-a"}},
+		// job-2 is still waiting, a duplicate answer must not touch it
+		{JobID: "job-2", BatchID: "a", Status: "rejected", Reason: "duplicate_request", Job: spec.Job{OwnerID: "owner-a"}},
+	}
+
+	errs := make([]error, len(decisions))
+	if err := saveJobs(context.Background(), tx, decisions, errs); err != nil {
+		t.Fatal(err)
+	}
+	for i, err := range errs {
+		if err != nil {
+			t.Fatalf("decision %d refused: %v", i, err)
+		}
+	}
+
+	if len(tx.events) != 1 || tx.events[0][0] != "job-1" || tx.events[0][3] != "accepted" {
+		t.Fatalf("events %v, want job-1 accepted again", tx.events)
+	}
+	if from := tx.events[0][2].(*string); from == nil || *from != "accepted" {
+		t.Fatalf("event %v, want it to follow the stored accepted", tx.events[0])
+	}
+	if rows := strings.Count(tx.sql[0], "NOW()"); rows != 1 {
+		t.Fatalf("%d jobs written, want only job-1", rows)
+	}
+}
+
+func TestCounterUpdatesSkipUnchangedRows(t *testing.T) {
+	ctx := context.Background()
+	tx := &recordingTx{}
+
+	err := updateBatchStats(ctx, tx, map[string]*batchDelta{
+		"b": {total: 1},
+		"a": {total: 2, admitted: 1},
+		"c": {},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids, _ := tx.args[0][0].([]string)
+	if len(tx.sql) != 1 || strings.Join(ids, ",") != "a,b" {
+		t.Fatalf("updated batches %v, want a and b in order", ids)
+	}
+
+	err = updateUserAssociations(ctx, tx, map[string]*configDelta{
+		"cfg-1": {userID: "owner-a", total: 2, failed: 1, batches: map[string]bool{"a": true}},
+		"cfg-2": {userID: "owner-a"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tx.sql) != 2 {
+		t.Fatalf("%d statements, want one per table", len(tx.sql))
+	}
+	checkPlaceholders(t, tx.sql[1], tx.args[1])
+	if args := tx.args[1]; len(args) != 6 || args[1] != "cfg-1" || args[5] != 1 {
+		t.Fatalf("args %v, want only cfg-1 with one new batch", args)
+	}
+
+	tx = &recordingTx{}
+	if err := updateBatchStats(ctx, tx, map[string]*batchDelta{"a": {}}); err != nil || len(tx.sql) != 0 {
+		t.Fatalf("ran %d statements for nothing to update, %v", len(tx.sql), err)
+	}
+}
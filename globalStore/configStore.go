@@ -3,6 +3,8 @@ package configStore
 import (
 	"encoding/json"
 	"sync"
+
+	"github.com/satyamraj1643/janus/internal/metrics"
 )
 
 type CachedConfig struct {
@@ -23,6 +25,11 @@ func Get(userID string) (CachedConfig, bool) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 	cfg, ok := s.config[userID]
+	if ok {
+		metrics.ConfigCacheHits.Inc()
+	} else {
+		metrics.ConfigCacheMisses.Inc()
+	}
 	return cfg, ok
 }
 
@@ -37,3 +44,10 @@ func Delete(userID string) {
 	defer s.mu.Unlock()
 	delete(s.config, userID)
 }
+
+// Clear drops every cached config, they are loaded again on next use
+func Clear() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.config = make(map[string]CachedConfig)
+}
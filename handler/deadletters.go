@@ -0,0 +1,190 @@
+package handler
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/satyamraj1643/janus/internal/admission"
+	"github.com/satyamraj1643/janus/internal/deadletter"
+	"github.com/satyamraj1643/janus/middleware"
+	"github.com/satyamraj1643/janus/queue"
+)
+
+// DeadLetterHandler lets owners inspect, redrive and purge jobs that will not run again
+type DeadLetterHandler struct {
+	DLQ *deadletter.Queue
+	AC  *admission.AdmissionController
+}
+
+// GET /dlq?cursor=&limit=
+func (h *DeadLetterHandler) List(w http.ResponseWriter, r *http.Request) {
+	slog.Debug("request", "method", r.Method, "path", r.URL.Path)
+
+	q := r.URL.Query()
+
+	limit := 0
+	if l := q.Get("limit"); l != "" {
+		var err error
+		if limit, err = strconv.Atoi(l); err != nil || limit <= 0 {
+			http.Error(w, "limit must be a positive integer", http.StatusBadRequest)
+			return
+		}
+	}
+
+	page, err := h.DLQ.List(r.Context(), middleware.GetUserID(r.Context()), q.Get("cursor"), limit)
+	if err == deadletter.ErrInvalidCursor {
+		http.Error(w, "invalid cursor", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		slog.Error("listing dead letters failed", "err", err)
+		http.Error(w, "internal service error", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, page)
+}
+
+// GET /dlq/{id}
+func (h *DeadLetterHandler) Get(w http.ResponseWriter, r *http.Request) {
+	slog.Debug("request", "method", r.Method, "path", r.URL.Path)
+
+	item, err := h.DLQ.Get(r.Context(), middleware.GetUserID(r.Context()), r.PathValue("id"))
+	if err != nil {
+		slog.Error("reading dead letter failed", "err", err)
+		http.Error(w, "internal service error", http.StatusInternalServerError)
+		return
+	}
+	if item == nil {
+		http.Error(w, "dead letter not found", http.StatusNotFound)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, item)
+}
+
+// POST /dlq/{id}/redrive
+func (h *DeadLetterHandler) Redrive(w http.ResponseWriter, r *http.Request) {
+	slog.Debug("request", "method", r.Method, "path", r.URL.Path)
+
+	h.redrive(w, r, []string{r.PathValue("id")})
+}
+
+// POST /dlq/redrive
+// Body {"job_ids": [...]} redrives those jobs, {"all": true} the most recent page of the queue.
+func (h *DeadLetterHandler) RedriveBulk(w http.ResponseWriter, r *http.Request) {
+	slog.Debug("request", "method", r.Method, "path", r.URL.Path)
+
+	req, ok := decodeBulkRequest(w, r)
+	if !ok {
+		return
+	}
+
+	jobIDs := req.JobIDs
+	if req.All {
+		page, err := h.DLQ.List(r.Context(), middleware.GetUserID(r.Context()), "", req.Limit)
+		if err != nil {
+			slog.Error("listing dead letters failed", "err", err)
+			http.Error(w, "internal service error", http.StatusInternalServerError)
+			return
+		}
+		for _, item := range page.Items {
+			jobIDs = append(jobIDs, item.JobID)
+		}
+	}
+
+	h.redrive(w, r, jobIDs)
+}
+
+func (h *DeadLetterHandler) redrive(w http.ResponseWriter, r *http.Request, jobIDs []string) {
+	activeConfig, configID, ownerID, _ := middleware.GetActiveContext(r.Context())
+
+	results, err := h.DLQ.Redrive(r.Context(), h.AC, ownerID, jobIDs, activeConfig, configID)
+	for _, res := range results {
+		if res.Decision != nil && !res.Decision.Replayed {
+			queue.Record(r.Context(), res.Decision)
+		}
+	}
+	if err != nil {
+		slog.Error("redrive failed", "err", err)
+		http.Error(w, "internal service error", http.StatusInternalServerError)
+		return
+	}
+
+	resp := DeadLetterRedriveResponse{Results: results}
+	for _, res := range results {
+		if res.Status == "accepted" || res.Status == "deferred" {
+			resp.Redriven++
+		}
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// DELETE /dlq/{id}
+func (h *DeadLetterHandler) Purge(w http.ResponseWriter, r *http.Request) {
+	slog.Debug("request", "method", r.Method, "path", r.URL.Path)
+
+	purged, err := h.DLQ.Purge(r.Context(), middleware.GetUserID(r.Context()), []string{r.PathValue("id")})
+	if err != nil {
+		slog.Error("purging dead letter failed", "err", err)
+		http.Error(w, "internal service error", http.StatusInternalServerError)
+		return
+	}
+	if purged == 0 {
+		http.Error(w, "dead letter not found", http.StatusNotFound)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, DeadLetterPurgeResponse{Purged: purged})
+}
+
+// DELETE /dlq
+// Body {"job_ids": [...]} purges those jobs, {"all": true} the whole queue.
+func (h *DeadLetterHandler) PurgeBulk(w http.ResponseWriter, r *http.Request) {
+	slog.Debug("request", "method", r.Method, "path", r.URL.Path)
+
+	req, ok := decodeBulkRequest(w, r)
+	if !ok {
+		return
+	}
+
+	purged, err := h.DLQ.Purge(r.Context(), middleware.GetUserID(r.Context()), req.JobIDs)
+	if err != nil {
+		slog.Error("purging dead letters failed", "err", err)
+		http.Error(w, "internal service error", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, DeadLetterPurgeResponse{Purged: purged})
+}
+
+// decodeBulkRequest reads a bulk request that names its jobs or asks for all of them.
+// A bulk operation never defaults to the whole queue, so an empty body is a 400.
+func decodeBulkRequest(w http.ResponseWriter, r *http.Request) (DeadLetterBulkRequest, bool) {
+	defer r.Body.Close()
+
+	var req DeadLetterBulkRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid json", http.StatusBadRequest)
+			return req, false
+		}
+	}
+
+	switch {
+	case len(req.JobIDs) == 0 && !req.All:
+		http.Error(w, `job_ids or "all": true is required`, http.StatusBadRequest)
+		return req, false
+	case len(req.JobIDs) > 0 && req.All:
+		http.Error(w, "job_ids and all cannot be combined", http.StatusBadRequest)
+		return req, false
+	case len(req.JobIDs) > deadletter.MaxPageSize:
+		http.Error(w, "job_ids cannot exceed 1000", http.StatusBadRequest)
+		return req, false
+	}
+
+	return req, true
+}
@@ -0,0 +1,39 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDecodeBulkRequest(t *testing.T) {
+	tooMany := `{"job_ids":["j"` + strings.Repeat(`,"j"`, 1000) + `]}`
+
+	for _, tc := range []struct {
+		body string
+		ok   bool
+	}{
+		{``, false},
+		{`{}`, false},
+		{`{"job_ids":[]}`, false},
+		{`{"job_ids":["job-1"],"all":true}`, false},
+		{`{"job_ids":`, false},
+		{tooMany, false},
+		{`{"job_ids":["job-1"]}`, true},
+		{`{"all":true}`, true},
+	} {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/dead-letters/redrive", strings.NewReader(tc.body))
+
+		_, ok := decodeBulkRequest(rec, req)
+		name := fmt.Sprintf("%.40s", tc.body)
+		if ok != tc.ok {
+			t.Errorf("%s: ok=%v, want %v", name, ok, tc.ok)
+		}
+		if !ok && rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: got %d, want 400", name, rec.Code)
+		}
+	}
+}
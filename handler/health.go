@@ -0,0 +1,127 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/satyamraj1643/janus/db"
+	"github.com/satyamraj1643/janus/internal/store"
+	"github.com/satyamraj1643/janus/listener"
+	"github.com/satyamraj1643/janus/queue"
+)
+
+const (
+	readinessCheckTimeout = 2 * time.Second
+
+	// Past this share of its capacity ResultQueue stalls handlers (in memory, without spilling)
+	// or trims decisions that were never saved (streams)
+	maxResultQueueSaturation = 0.9
+)
+
+// HealthHandler serves the orchestrator's probes
+type HealthHandler struct {
+	Redis *store.RedisStore
+}
+
+// GET /livez
+// The process is up and serving, dependencies are not checked.
+func (h *HealthHandler) Livez(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// GET /readyz
+// Ready when every dependency admission needs is, 503 with the failing checks otherwise.
+// The probe is unauthenticated, so it only says which checks fail; why is logged.
+func (h *HealthHandler) Readyz(w http.ResponseWriter, r *http.Request) {
+	checks := map[string]func(context.Context) (any, error){
+		"redis":           h.checkRedis,
+		"lua_scripts":     h.checkScripts,
+		"postgres":        checkPostgres,
+		"config_listener": checkListener,
+		"result_queue":    checkResultQueue,
+	}
+
+	resp := ReadinessResponse{Status: "ready", Checks: make(map[string]ReadinessCheck, len(checks))}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, check := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			ctx, cancel := context.WithTimeout(r.Context(), readinessCheckTimeout)
+			defer cancel()
+
+			start := time.Now()
+			detail, err := check(ctx)
+			result := ReadinessCheck{
+				OK:        err == nil,
+				LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+			}
+			if err != nil {
+				slog.Warn("readiness check failed", "check", name, "err", err, "detail", detail)
+			}
+
+			mu.Lock()
+			resp.Checks[name] = result
+			if err != nil {
+				resp.Status = "not_ready"
+			}
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	status := http.StatusOK
+	if resp.Status != "ready" {
+		status = http.StatusServiceUnavailable
+	}
+	writeJSON(w, status, resp)
+}
+
+func (h *HealthHandler) checkRedis(ctx context.Context) (any, error) {
+	return nil, h.Redis.Ping(ctx)
+}
+
+// checkScripts only reads which scripts Redis lost, they are loaded again on their next call
+func (h *HealthHandler) checkScripts(ctx context.Context) (any, error) {
+	missing, err := h.Redis.MissingScripts(ctx)
+	if len(missing) > 0 {
+		slog.Info("readiness: Lua scripts not cached, loaded on next use", "scripts", missing)
+	}
+	return missing, err
+}
+
+func checkPostgres(ctx context.Context) (any, error) {
+	return db.Ping(ctx)
+}
+
+func checkListener(ctx context.Context) (any, error) {
+	state := listener.CurrentState()
+	if !state.Connected {
+		return state, fmt.Errorf("not listening for config updates, cached configs may be stale")
+	}
+	return state, nil
+}
+
+func checkResultQueue(ctx context.Context) (any, error) {
+	depth := queue.ResultDepth(ctx)
+	capacity := queue.ResultCapacity()
+
+	detail := ResultQueueHealth{Depth: depth, Capacity: capacity, Spilled: max(queue.ResultSpilled(), 0)}
+	if depth < 0 {
+		return detail, fmt.Errorf("queue depth unavailable")
+	}
+	if capacity > 0 {
+		detail.Saturation = float64(depth) / float64(capacity)
+		if detail.Saturation >= maxResultQueueSaturation {
+			return detail, fmt.Errorf("result queue %.0f%% full", detail.Saturation*100)
+		}
+	}
+	return detail, nil
+}
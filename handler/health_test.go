@@ -0,0 +1,47 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/satyamraj1643/janus/internal/store"
+)
+
+func TestReadyzHidesFailureDetails(t *testing.T) {
+	h := &HealthHandler{Redis: store.NewRedisStore(miniredis.RunT(t).Addr())}
+
+	rec := httptest.NewRecorder()
+	h.Readyz(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+	// No Postgres, listener or result queue in this test
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("got %d, want 503", rec.Code)
+	}
+	if body := rec.Body.String(); strings.Contains(body, "not connected") || strings.Contains(body, "config updates") {
+		t.Fatalf("body leaks why checks failed: %s", body)
+	}
+
+	var resp ReadinessResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Status != "not_ready" || !resp.Checks["redis"].OK || resp.Checks["postgres"].OK {
+		t.Fatalf("got %+v", resp)
+	}
+	// Scripts Redis has not cached yet are loaded on use, they do not fail readiness
+	if !resp.Checks["lua_scripts"].OK {
+		t.Fatal("uncached scripts failed readiness")
+	}
+}
+
+func TestLivez(t *testing.T) {
+	rec := httptest.NewRecorder()
+	(&HealthHandler{}).Livez(rec, httptest.NewRequest(http.MethodGet, "/livez", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("got %d", rec.Code)
+	}
+}
@@ -1,15 +1,21 @@
 package handler
 
 import (
+	"context"
 	"encoding/json"
-	"log"
+	"errors"
+	"fmt"
+	"log/slog"
 	"net/http"
 
 	"github.com/google/uuid"
+	"github.com/satyamraj1643/janus/db"
 	"github.com/satyamraj1643/janus/internal/admission"
+	"github.com/satyamraj1643/janus/internal/tracing"
 	"github.com/satyamraj1643/janus/middleware"
 	"github.com/satyamraj1643/janus/queue"
 	"github.com/satyamraj1643/janus/spec"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 const (
@@ -17,13 +23,22 @@ const (
 	DashboardBatchID = "22222222-2222-2222-2222-222222222222" // fixed UUID for dashboard batch
 )
 
+// singleJobBatchID is the owner's batch for jobs submitted one at a time,
+// derived from SystemBatchID or DashboardBatchID so each owner has their own
+func singleJobBatchID(base, ownerID string) string {
+	return uuid.NewSHA1(uuid.MustParse(base), []byte(ownerID)).String()
+}
+
 type JobHandler struct {
 	AC            *admission.AdmissionController
 	FromDashboard bool
 }
 
 func (h *JobHandler) CreateJob(w http.ResponseWriter, r *http.Request) {
-	log.Println("PATH:", r.Method, r.URL.Path)
+	slog.Debug("request", "method", r.Method, "path", r.URL.Path)
+
+	ctx, span := tracing.Start(r.Context(), "JobHandler.CreateJob")
+	defer span.End()
 
 	if r.Method != http.MethodPost {
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
@@ -39,40 +54,52 @@ func (h *JobHandler) CreateJob(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Idempotency-Key header can stand in for job_id
+	job.IdempotencyKey = r.Header.Get("Idempotency-Key")
+	if job.ID == "" {
+		job.ID = job.IdempotencyKey
+	}
+
 	if job.ID == "" || job.TenantID == "" {
 		http.Error(w, "missing job_id or tenanat_id", http.StatusBadRequest)
 		return
 	}
 
+	// Attach user's active config to job
+	activeConfig, configID, ownerID, _ := middleware.GetActiveContext(r.Context())
+	job.Config = activeConfig
+	job.GlobalConfigID = configID
+	job.OwnerID = ownerID
+
 	if h.FromDashboard {
 		job.Source = spec.JobSourceDashboard
 		job.BatchName = "dashboard_batch"
-		job.BatchID = DashboardBatchID
+		job.BatchID = singleJobBatchID(DashboardBatchID, ownerID)
 	} else {
 		job.Source = spec.JobSourceSystem
 		job.BatchName = "system_batch"
-		job.BatchID = SystemBatchID
+		job.BatchID = singleJobBatchID(SystemBatchID, ownerID)
 	}
 
-	// Attach user's active config to job
-	activeConfig, configID, ownerID, _ := middleware.GetActiveContext(r.Context())
-	job.Config = activeConfig
-	job.GlobalConfigID = configID
-	job.OwnerID = ownerID
-
-	log.Printf("Validating job synchronously.")
+	span.SetAttributes(attribute.String("janus.job_id", job.ID), attribute.String("janus.owner_id", ownerID))
 
-	decision, err := h.AC.Check(r.Context(), job)
-	if err != nil {
+	decision, err := h.AC.Check(ctx, job)
+	if err != nil && (decision == nil || decision.Reason == "store_error") {
 		http.Error(w, "internal service error", http.StatusInternalServerError)
 		return
 	}
 
-	// Send to DB writer
-	queue.ResultQueue <- decision
+	if decision.Replayed {
+		// Already persisted by the original submission
+		w.Header().Set("Idempotent-Replayed", "true")
+	} else {
+		// Send to DB writer
+		queue.Record(ctx, decision)
+	}
+	span.SetAttributes(attribute.String("janus.status", decision.Status))
 
 	w.Header().Set("Content-Type", "application/json")
-	if decision.Status == "accepted" {
+	if decision.Status == "accepted" || decision.Status == "deferred" || decision.Status == "scheduled" {
 		w.WriteHeader(http.StatusAccepted)
 	} else {
 		w.WriteHeader(http.StatusForbidden) // Or 429 based on reason
@@ -81,8 +108,12 @@ func (h *JobHandler) CreateJob(w http.ResponseWriter, r *http.Request) {
 }
 
 // Accepts partial batch, some admitted and some not.
+// The mode decides which jobs win when quota cannot cover the whole batch.
 func (h *JobHandler) CreateJobBatch(w http.ResponseWriter, r *http.Request) {
-	log.Println("PATH:", r.Method, r.URL.Path)
+	slog.Debug("request", "method", r.Method, "path", r.URL.Path)
+
+	ctx, span := tracing.Start(r.Context(), "JobHandler.CreateJobBatch")
+	defer span.End()
 
 	if r.Method != http.MethodPost {
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
@@ -122,12 +153,49 @@ func (h *JobHandler) CreateJobBatch(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	admitted := 0
-	var decisions []*spec.JobDecision
+	var checkBatch func(context.Context, []spec.Job) ([]*spec.JobDecision, error)
+	switch req.Mode {
+	case "", BatchModePartial:
+		checkBatch = h.AC.CheckBatchPartial
+	case BatchModePriority:
+		checkBatch = h.AC.CheckBatchPriority
+	case BatchModePrefix:
+		checkBatch = h.AC.CheckBatchPrefix
+	default:
+		http.Error(w, "mode must be partial, priority or prefix", http.StatusBadRequest)
+		return
+	}
+
+	// Attach user's active config to jobs
+	activeConfig, configID, ownerID, _ := middleware.GetActiveContext(r.Context())
+
+	results := make([]JobResult, len(req.Jobs))
+	var validJobs []spec.Job
+	var validIndices []int
+
+	prefixEnded := false
+	for i, job := range req.Jobs {
+		if prefixEnded {
+			results[i] = JobResult{
+				Index:     i,
+				JobID:     job.ID,
+				Status:    "rejected",
+				Reason:    "batch_prefix_ended",
+				Retryable: true,
+			}
+			continue
+		}
 
-	for _, job := range req.Jobs {
+		// Invalid jobs are rejected individually, the rest of the batch still runs
 		if job.ID == "" || job.TenantID == "" {
-			break
+			results[i] = JobResult{
+				Index:  i,
+				JobID:  job.ID,
+				Status: "rejected",
+				Reason: "invalid_job",
+			}
+			prefixEnded = req.Mode == BatchModePrefix
+			continue
 		}
 
 		if h.FromDashboard {
@@ -138,33 +206,45 @@ func (h *JobHandler) CreateJobBatch(w http.ResponseWriter, r *http.Request) {
 
 		job.BatchName = batchName
 		job.BatchID = batchID
-
-		// Attach user's active config to job
-		activeConfig, configID, ownerID, _ := middleware.GetActiveContext(r.Context())
 		job.Config = activeConfig
 		job.GlobalConfigID = configID
 		job.OwnerID = ownerID
 
-		decision, err := h.AC.Check(r.Context(), job)
-		if err != nil {
-			// If internal error, we count as failure/break or log
-			break
-		}
+		validJobs = append(validJobs, job)
+		validIndices = append(validIndices, i)
+	}
 
-		queue.ResultQueue <- decision
-		decisions = append(decisions, decision)
+	span.SetAttributes(attribute.String("janus.batch_id", batchID), attribute.Int("janus.batch_size", len(req.Jobs)))
 
-		if decision.Status == "accepted" {
+	decisions, err := checkBatch(ctx, validJobs)
+	if err != nil {
+		http.Error(w, "internal error during batch check", http.StatusInternalServerError)
+		return
+	}
+
+	admitted, deferred, scheduled := 0, 0, 0
+	for n, decision := range decisions {
+		if !decision.Replayed {
+			queue.Record(ctx, decision)
+		}
+		results[validIndices[n]] = newJobResult(validIndices[n], decision)
+
+		switch decision.Status {
+		case "accepted":
 			admitted++
+		case "deferred":
+			deferred++
+		case "scheduled":
+			scheduled++
 		}
 	}
 
-	rejected := len(req.Jobs) - admitted
+	rejected := len(req.Jobs) - admitted - deferred - scheduled
 
 	status := "full"
-	if admitted == 0 {
+	if admitted == 0 && deferred == 0 && scheduled == 0 {
 		status = "rejected"
-	} else if rejected > 0 {
+	} else if rejected > 0 || deferred > 0 || scheduled > 0 {
 		status = "partial"
 	}
 
@@ -172,22 +252,22 @@ func (h *JobHandler) CreateJobBatch(w http.ResponseWriter, r *http.Request) {
 		BatchName: batchName,
 		Status:    status,
 		Admitted:  admitted,
+		Deferred:  deferred,
+		Scheduled: scheduled,
 		Rejected:  rejected,
+		Results:   results,
 	}
 
-	// Optional: we can return decisions details if needed, but keeping existing response format for now + decisions?
-	// The user asked for "status of each job", but the original response was aggregate.
-	// Let's stick to aggregate for this endpoint as it's partial, or maybe add detail?
-	// User said "what is the status of each job after janus run".
-	// Let's just return the aggregate for now to pass build, then refine.
-
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusAccepted)
 	json.NewEncoder(w).Encode(resp)
 }
 
 func (h *JobHandler) CreateJobBatchAtomic(w http.ResponseWriter, r *http.Request) {
-	log.Println("PATH:", r.Method, r.URL.Path)
+	slog.Debug("request", "method", r.Method, "path", r.URL.Path)
+
+	ctx, span := tracing.Start(r.Context(), "JobHandler.CreateJobBatchAtomic")
+	defer span.End()
 
 	if r.Method != http.MethodPost {
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
@@ -241,15 +321,19 @@ func (h *JobHandler) CreateJobBatchAtomic(w http.ResponseWriter, r *http.Request
 		req.Jobs[i].OwnerID = ownerID
 	}
 
-	decisions, err := h.AC.CheckBatchAtomic(r.Context(), req.Jobs)
+	span.SetAttributes(attribute.String("janus.batch_id", batchID), attribute.Int("janus.batch_size", len(req.Jobs)))
+
+	decisions, err := h.AC.CheckBatchAtomic(ctx, req.Jobs)
 	if err != nil {
 		http.Error(w, "internal error during atomic check", http.StatusInternalServerError)
 		return
 	}
 
-	// Queue decisions for DB
+	// Queue decisions for DB, replays were recorded the first time
 	for _, d := range decisions {
-		queue.ResultQueue <- d
+		if !d.Replayed {
+			queue.Record(ctx, d)
+		}
 	}
 
 	// Return results
@@ -267,3 +351,56 @@ func (h *JobHandler) CreateJobBatchAtomic(w http.ResponseWriter, r *http.Request
 	w.WriteHeader(status)
 	json.NewEncoder(w).Encode(decisions)
 }
+
+// DELETE /jobs/{id}
+// Cancels a scheduled, deferred or dispatched job that has not finished.
+func (h *JobHandler) CancelJob(w http.ResponseWriter, r *http.Request) {
+	slog.Debug("request", "method", r.Method, "path", r.URL.Path)
+
+	ctx, span := tracing.Start(r.Context(), "JobHandler.CancelJob")
+	defer span.End()
+
+	ownerID := middleware.GetUserID(r.Context())
+	jobID := r.PathValue("id")
+
+	decision, err := h.AC.Cancel(ctx, ownerID, jobID)
+	if errors.Is(err, admission.ErrNotCancellable) {
+		record, err := db.GetJob(ownerID, jobID)
+		if err != nil {
+			http.Error(w, "internal service error", http.StatusInternalServerError)
+			return
+		}
+		if record == nil {
+			http.Error(w, "job not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, fmt.Sprintf("job is %s and cannot be cancelled", record.Status), http.StatusConflict)
+		return
+	}
+	if errors.Is(err, admission.ErrCancelConflict) {
+		w.Header().Set("Retry-After", "1")
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		slog.Error("cancelling job failed", "job_id", jobID, "err", err)
+		http.Error(w, "internal service error", http.StatusInternalServerError)
+		return
+	}
+
+	queue.Record(ctx, decision)
+
+	writeJSON(w, http.StatusOK, decision)
+}
+
+func newJobResult(index int, d *spec.JobDecision) JobResult {
+	return JobResult{
+		Index:        index,
+		JobID:        d.JobID,
+		Status:       d.Status,
+		Reason:       d.Reason,
+		Retryable:    d.Status == "rejected" && admission.Retryable(d.Reason),
+		RetryAfterMs: d.RetryAfterMs,
+		Replayed:     d.Replayed,
+	}
+}
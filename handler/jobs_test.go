@@ -0,0 +1,84 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/satyamraj1643/janus/internal/admission"
+	"github.com/satyamraj1643/janus/internal/store"
+	"github.com/satyamraj1643/janus/spec"
+)
+
+func TestNewJobResult(t *testing.T) {
+	tests := []struct {
+		status, reason string
+		retryable      bool
+	}{
+		{"accepted", "", false},
+		{"deferred", "", false},
+		{"rejected", "rate_limit_exceeded", true},
+		{"rejected", "batch_quota_exceeded", true},
+		{"rejected", "batch_prefix_ended", true},
+		{"rejected", "store_error", true},
+		{"rejected", "duplicate_request", false},
+		{"rejected", "invalid_config", false},
+		{"rejected", "priority_too_low", false},
+	}
+
+	for _, tt := range tests {
+		d := &spec.JobDecision{JobID: "job-1", Status: tt.status, Reason: tt.reason, RetryAfterMs: 250}
+		res := newJobResult(3, d)
+		if res.Index != 3 || res.JobID != "job-1" || res.Status != tt.status || res.Reason != tt.reason {
+			t.Errorf("%s/%s: got %+v", tt.status, tt.reason, res)
+		}
+		if res.Retryable != tt.retryable {
+			t.Errorf("%s/%s: retryable %v, want %v", tt.status, tt.reason, res.Retryable, tt.retryable)
+		}
+	}
+}
+
+func TestPartialBatchReportsEveryJob(t *testing.T) {
+	h := &JobHandler{AC: admission.NewAdmissionController(store.NewRedisStore(miniredis.RunT(t).Addr()))}
+
+	body := `{"batch_name":"b","jobs":[
+		{"job_id":"job-1","tenant_id":"t"},
+		{"job_id":"","tenant_id":"t"},
+		{"job_id":"job-3","tenant_id":""},
+		{"job_id":"job-4","tenant_id":"t"}
+	]}`
+	rec := httptest.NewRecorder()
+	h.CreateJobBatch(rec, httptest.NewRequest(http.MethodPost, "/jobs/batch", strings.NewReader(body)))
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("got %d: %s", rec.Code, rec.Body)
+	}
+
+	var resp JobBatchResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Results) != 4 || resp.Rejected != 4 {
+		t.Fatalf("got %+v, want 4 rejected results", resp)
+	}
+
+	// No active config in the request context, so the valid jobs fail on it
+	wantReasons := []string{"invalid_config", "invalid_job", "invalid_job", "invalid_config"}
+	for i, res := range resp.Results {
+		if res.Index != i || res.Reason != wantReasons[i] {
+			t.Errorf("result %d: got %+v, want reason %s", i, res, wantReasons[i])
+		}
+	}
+}
+
+func TestSingleJobBatchIsPerOwner(t *testing.T) {
+	a := singleJobBatchID(SystemBatchID, "owner-a")
+	if a != singleJobBatchID(SystemBatchID, "owner-a") {
+		t.Fatal("batch ID changes between submissions")
+	}
+	if a == singleJobBatchID(SystemBatchID, "owner-b") || a == singleJobBatchID(DashboardBatchID, "owner-a") {
+		t.Fatal("owners or sources share a batch")
+	}
+}
@@ -0,0 +1,29 @@
+package handler
+
+import (
+	"encoding/base64"
+	"log/slog"
+	"net/http"
+
+	"github.com/satyamraj1643/janus/permit"
+)
+
+// PermitHandler publishes the key workers and dependency proxies verify permits with
+type PermitHandler struct {
+	Signer *permit.Signer
+}
+
+// GET /permits/key
+func (h *PermitHandler) PublicKey(w http.ResponseWriter, r *http.Request) {
+	slog.Debug("request", "method", r.Method, "path", r.URL.Path)
+
+	if h.Signer == nil {
+		http.Error(w, "permits are not enabled", http.StatusNotFound)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, PermitKeyResponse{
+		Algorithm: "Ed25519",
+		PublicKey: base64.StdEncoding.EncodeToString(h.Signer.PublicKey()),
+	})
+}
@@ -0,0 +1,178 @@
+package handler
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/satyamraj1643/janus/db"
+	"github.com/satyamraj1643/janus/internal/policy"
+	"github.com/satyamraj1643/janus/middleware"
+)
+
+// PolicyHandler manages the versions of the X-User-ID owner's config.
+// Versions are never edited, a change is a new version that is then activated.
+type PolicyHandler struct{}
+
+// POST /policies
+// Body {"config": {...}, "description": "..."} stores the next version, inactive.
+func (h *PolicyHandler) Create(w http.ResponseWriter, r *http.Request) {
+	slog.Debug("request", "method", r.Method, "path", r.URL.Path)
+
+	defer r.Body.Close()
+
+	var req CreatePolicyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	// The same checks admission relies on, a version that fails them could never be enforced
+	pol, err := policy.ParseConfig(req.Config)
+	if err == nil {
+		err = pol.Validate()
+	}
+	if err != nil {
+		http.Error(w, "invalid config: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	version, err := db.CreatePolicyVersion(middleware.GetUserID(r.Context()), req.Config, req.Description)
+	if err != nil {
+		http.Error(w, "internal service error", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, version)
+}
+
+// GET /policies
+func (h *PolicyHandler) List(w http.ResponseWriter, r *http.Request) {
+	slog.Debug("request", "method", r.Method, "path", r.URL.Path)
+
+	versions, err := db.ListPolicyVersions(middleware.GetUserID(r.Context()))
+	if err != nil {
+		http.Error(w, "internal service error", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, PolicyListResponse{Versions: versions})
+}
+
+// GET /policies/{version}
+func (h *PolicyHandler) Get(w http.ResponseWriter, r *http.Request) {
+	slog.Debug("request", "method", r.Method, "path", r.URL.Path)
+
+	version, ok := h.version(w, r.PathValue("version"), "version")
+	if !ok {
+		return
+	}
+
+	p, err := db.GetPolicyVersion(middleware.GetUserID(r.Context()), version)
+	if err != nil {
+		http.Error(w, "internal service error", http.StatusInternalServerError)
+		return
+	}
+	if p == nil {
+		http.Error(w, "policy version not found", http.StatusNotFound)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, p)
+}
+
+// GET /policies/diff?from=&to=
+func (h *PolicyHandler) Diff(w http.ResponseWriter, r *http.Request) {
+	slog.Debug("request", "method", r.Method, "path", r.URL.Path)
+
+	userID := middleware.GetUserID(r.Context())
+	q := r.URL.Query()
+
+	from, ok := h.version(w, q.Get("from"), "from")
+	if !ok {
+		return
+	}
+	to, ok := h.version(w, q.Get("to"), "to")
+	if !ok {
+		return
+	}
+
+	var configs [2]json.RawMessage
+	for i, v := range []int{from, to} {
+		p, err := db.GetPolicyVersion(userID, v)
+		if err != nil {
+			http.Error(w, "internal service error", http.StatusInternalServerError)
+			return
+		}
+		if p == nil {
+			http.Error(w, "policy version "+strconv.Itoa(v)+" not found", http.StatusNotFound)
+			return
+		}
+		configs[i] = p.Config
+	}
+
+	changes, err := policy.Diff(configs[0], configs[1])
+	if err != nil {
+		slog.Error("diffing policy versions failed", "err", err)
+		http.Error(w, "internal service error", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, PolicyDiffResponse{From: from, To: to, Changes: changes})
+}
+
+// POST /policies/{version}/activate
+// Janus instances apply the new config as soon as they are notified.
+func (h *PolicyHandler) Activate(w http.ResponseWriter, r *http.Request) {
+	slog.Debug("request", "method", r.Method, "path", r.URL.Path)
+
+	version, ok := h.version(w, r.PathValue("version"), "version")
+	if !ok {
+		return
+	}
+
+	userID := middleware.GetUserID(r.Context())
+	activated, err := db.ActivatePolicyVersion(userID, version)
+	if err != nil {
+		http.Error(w, "internal service error", http.StatusInternalServerError)
+		return
+	}
+	if activated == nil {
+		http.Error(w, "policy version not found", http.StatusNotFound)
+		return
+	}
+
+	slog.Info("policy version activated", "user_id", userID, "version", activated.Version)
+	writeJSON(w, http.StatusOK, activated)
+}
+
+// POST /policies/rollback
+// Reactivates the version that was active before the current one.
+func (h *PolicyHandler) Rollback(w http.ResponseWriter, r *http.Request) {
+	slog.Debug("request", "method", r.Method, "path", r.URL.Path)
+
+	userID := middleware.GetUserID(r.Context())
+	activated, err := db.RollbackPolicy(userID)
+	if err == db.ErrNoPreviousPolicy || (err == nil && activated == nil) {
+		http.Error(w, "no previous policy version to roll back to", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "internal service error", http.StatusInternalServerError)
+		return
+	}
+
+	slog.Info("policy rolled back", "user_id", userID, "version", activated.Version)
+	writeJSON(w, http.StatusOK, activated)
+}
+
+// version parses a version number, answering 400 if it is not one
+func (h *PolicyHandler) version(w http.ResponseWriter, v, name string) (int, bool) {
+	n, err := strconv.Atoi(v)
+	if err != nil || n <= 0 {
+		http.Error(w, name+" must be a positive integer", http.StatusBadRequest)
+		return 0, false
+	}
+	return n, true
+}
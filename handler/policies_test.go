@@ -0,0 +1,45 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCreatePolicyRejectsInvalidConfigs(t *testing.T) {
+	h := &PolicyHandler{}
+
+	for _, body := range []string{
+		`{"config":`,
+		`{"description": "no config"}`,
+		`{"config": {"global_execution_limit": {"max_jobs": 0, "window_ms": 1000}, "default_job_policy": {"idempotency_window_ms": 1000}}}`,
+		`{"config": {"version": 2, "global_execution_limit": {"max_jobs": 1, "window_ms": 1000}, "default_job_policy": {"idempotency_window_ms": 1000}}}`,
+	} {
+		rec := httptest.NewRecorder()
+		h.Create(rec, httptest.NewRequest(http.MethodPost, "/policies", strings.NewReader(body)))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: got %d, want 400", body, rec.Code)
+		}
+	}
+}
+
+func TestPolicyVersionsMustBePositive(t *testing.T) {
+	h := &PolicyHandler{}
+
+	for _, query := range []string{"", "from=1", "from=0&to=2", "from=1&to=two"} {
+		rec := httptest.NewRecorder()
+		h.Diff(rec, httptest.NewRequest(http.MethodGet, "/policies/diff?"+query, nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("diff %q: got %d, want 400", query, rec.Code)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/policies/x/activate", nil)
+	req.SetPathValue("version", "-1")
+	h.Activate(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("activate -1: got %d, want 400", rec.Code)
+	}
+}
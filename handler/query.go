@@ -0,0 +1,153 @@
+package handler
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/satyamraj1643/janus/db"
+	"github.com/satyamraj1643/janus/middleware"
+)
+
+// QueryHandler serves read-only views of persisted decisions, scoped to the X-User-ID owner
+type QueryHandler struct{}
+
+// GET /jobs/{id}
+func (h *QueryHandler) GetJob(w http.ResponseWriter, r *http.Request) {
+	slog.Debug("request", "method", r.Method, "path", r.URL.Path)
+
+	job, err := db.GetJob(middleware.GetUserID(r.Context()), r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "internal service error", http.StatusInternalServerError)
+		return
+	}
+	if job == nil {
+		http.Error(w, "job not found", http.StatusNotFound)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, job)
+}
+
+// GET /jobs/{id}/events
+func (h *QueryHandler) GetJobEvents(w http.ResponseWriter, r *http.Request) {
+	slog.Debug("request", "method", r.Method, "path", r.URL.Path)
+
+	userID := middleware.GetUserID(r.Context())
+	jobID := r.PathValue("id")
+
+	events, err := db.GetJobEvents(userID, jobID)
+	if err != nil {
+		http.Error(w, "internal service error", http.StatusInternalServerError)
+		return
+	}
+	if len(events) == 0 {
+		job, err := db.GetJob(userID, jobID)
+		if err != nil {
+			http.Error(w, "internal service error", http.StatusInternalServerError)
+			return
+		}
+		if job == nil {
+			http.Error(w, "job not found", http.StatusNotFound)
+			return
+		}
+	}
+
+	writeJSON(w, http.StatusOK, JobEventsResponse{JobID: jobID, Events: events})
+}
+
+// GET /jobs?status=&tenant_id=&batch_id=&reason=&from=&to=&cursor=&limit=
+func (h *QueryHandler) ListJobs(w http.ResponseWriter, r *http.Request) {
+	slog.Debug("request", "method", r.Method, "path", r.URL.Path)
+
+	q := r.URL.Query()
+	filter := db.JobFilter{
+		Status:   q.Get("status"),
+		TenantID: q.Get("tenant_id"),
+		BatchID:  q.Get("batch_id"),
+		Reason:   q.Get("reason"),
+		Cursor:   q.Get("cursor"),
+	}
+
+	var err error
+	if filter.From, err = parseTimeParam(q.Get("from")); err != nil {
+		http.Error(w, "from must be RFC3339", http.StatusBadRequest)
+		return
+	}
+	if filter.To, err = parseTimeParam(q.Get("to")); err != nil {
+		http.Error(w, "to must be RFC3339", http.StatusBadRequest)
+		return
+	}
+	if l := q.Get("limit"); l != "" {
+		if filter.Limit, err = strconv.Atoi(l); err != nil || filter.Limit <= 0 {
+			http.Error(w, "limit must be a positive integer", http.StatusBadRequest)
+			return
+		}
+	}
+
+	page, err := db.ListJobs(middleware.GetUserID(r.Context()), filter)
+	if err == db.ErrInvalidCursor {
+		http.Error(w, "invalid cursor", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, "internal service error", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, page)
+}
+
+// GET /batches/{id}?cursor=&limit=
+func (h *QueryHandler) GetBatch(w http.ResponseWriter, r *http.Request) {
+	slog.Debug("request", "method", r.Method, "path", r.URL.Path)
+
+	userID := middleware.GetUserID(r.Context())
+	batchID := r.PathValue("id")
+
+	batch, err := db.GetBatch(userID, batchID)
+	if err != nil {
+		http.Error(w, "internal service error", http.StatusInternalServerError)
+		return
+	}
+	if batch == nil {
+		http.Error(w, "batch not found", http.StatusNotFound)
+		return
+	}
+
+	// The fixed system/dashboard batches grow without bound, so jobs are paged
+	filter := db.JobFilter{BatchID: batchID, Cursor: r.URL.Query().Get("cursor")}
+	if l := r.URL.Query().Get("limit"); l != "" {
+		if filter.Limit, err = strconv.Atoi(l); err != nil || filter.Limit <= 0 {
+			http.Error(w, "limit must be a positive integer", http.StatusBadRequest)
+			return
+		}
+	}
+
+	page, err := db.ListJobs(userID, filter)
+	if err == db.ErrInvalidCursor {
+		http.Error(w, "invalid cursor", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, "internal service error", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, BatchStatusResponse{BatchRecord: batch, JobPage: page})
+}
+
+func parseTimeParam(v string) (time.Time, error) {
+	if v == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, v)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
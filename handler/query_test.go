@@ -0,0 +1,25 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestListJobsValidatesQuery(t *testing.T) {
+	h := &QueryHandler{}
+
+	for _, query := range []string{
+		"from=yesterday",
+		"to=2026-01-05",
+		"limit=0",
+		"limit=ten",
+		"cursor=not-a-cursor!",
+	} {
+		rec := httptest.NewRecorder()
+		h.ListJobs(rec, httptest.NewRequest(http.MethodGet, "/jobs?"+query, nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: got %d, want 400", query, rec.Code)
+		}
+	}
+}
@@ -1,17 +1,143 @@
 package handler
 
-import "github.com/satyamraj1643/janus/spec"
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/satyamraj1643/janus/db"
+	"github.com/satyamraj1643/janus/internal/deadletter"
+	"github.com/satyamraj1643/janus/internal/lease"
+	"github.com/satyamraj1643/janus/internal/policy"
+	"github.com/satyamraj1643/janus/spec"
+)
 
 type JobBatchRequest struct {
-	BatchName string `json:"batch_name"`
+	BatchName string     `json:"batch_name"`
+	Mode      string     `json:"mode"` // partial (default) | priority | prefix
 	Jobs      []spec.Job `json:"jobs"`
 }
 
+const (
+	BatchModePartial  = "partial"  // admit every job that fits, in request order
+	BatchModePriority = "priority" // admit highest priority first until quota runs out
+	BatchModePrefix   = "prefix"   // admit the longest in-order prefix that fits
+)
+
 
 type JobBatchResponse struct {
-	BatchName string `json:"batch_name"`
-	Status    string `json:"status"`   // full | partial | rejected
-	Admitted  int    `json:"admitted"`
-	Rejected  int    `json:"rejected"`
+	BatchName string      `json:"batch_name"`
+	Status    string      `json:"status"` // full | partial | rejected
+	Admitted  int         `json:"admitted"`
+	Deferred  int         `json:"deferred"`
+	Scheduled int         `json:"scheduled"`
+	Rejected  int         `json:"rejected"`
+	Results   []JobResult `json:"results"`
+}
+
+// JobResult is the decision for one job of a batch, in request order
+type JobResult struct {
+	Index        int    `json:"index"`
+	JobID        string `json:"job_id"`
+	Status       string `json:"status"` // accepted | deferred | scheduled | rejected | expired
+	Reason       string `json:"reason,omitempty"`
+	Retryable    bool   `json:"retryable"`
+	RetryAfterMs int64  `json:"retry_after_ms,omitempty"`
+	Replayed     bool   `json:"replayed,omitempty"`
+}
+
+type BatchStatusResponse struct {
+	*db.BatchRecord
+	*db.JobPage
+}
+
+// LeaseRequest asks for up to Max jobs, optionally only one tenant's or dependency's
+type LeaseRequest struct {
+	Max        int    `json:"max"`
+	TenantID   string `json:"tenant_id,omitempty"`
+	Dependency string `json:"dependency,omitempty"`
+}
+
+type LeaseResponse struct {
+	Leases []lease.Grant `json:"leases"`
+}
+
+type HeartbeatRequest struct {
+	ExtendMs int64 `json:"extend_ms,omitempty"` // capped at the lease timeout
+}
+
+type HeartbeatResponse struct {
+	LeaseID     string     `json:"lease_id"`
+	Deadline    time.Time  `json:"deadline"`
+	MaxDeadline *time.Time `json:"max_deadline,omitempty"`
+	Permit      string     `json:"permit,omitempty"` // replaces the lease's earlier permit
+}
+
+type FailRequest struct {
+	Reason string `json:"reason,omitempty"`
+}
+
+type LeaseResultResponse struct {
+	LeaseID string `json:"lease_id"`
+	JobID   string `json:"job_id,omitempty"`
+	Status  string `json:"status"` // succeeded | dead | retrying
+}
+
+// DeadLetterBulkRequest selects dead letters by ID, or with All every one
+// (for redrive, up to Limit of the most recent)
+type DeadLetterBulkRequest struct {
+	JobIDs []string `json:"job_ids,omitempty"`
+	All    bool     `json:"all,omitempty"`
+	Limit  int      `json:"limit,omitempty"`
 }
 
+type DeadLetterRedriveResponse struct {
+	Redriven int                        `json:"redriven"`
+	Results  []deadletter.RedriveResult `json:"results"`
+}
+
+type DeadLetterPurgeResponse struct {
+	Purged int `json:"purged"`
+}
+
+type JobEventsResponse struct {
+	JobID  string        `json:"job_id"`
+	Events []db.JobEvent `json:"events"`
+}
+
+type PermitKeyResponse struct {
+	Algorithm string `json:"algorithm"`
+	PublicKey string `json:"public_key"` // base64, see permit.ParsePublicKey
+}
+
+type ReadinessResponse struct {
+	Status string                    `json:"status"` // ready | not_ready
+	Checks map[string]ReadinessCheck `json:"checks"`
+}
+
+// ReadinessCheck is public, failure details are only logged
+type ReadinessCheck struct {
+	OK        bool    `json:"ok"`
+	LatencyMs float64 `json:"latency_ms"`
+}
+
+type CreatePolicyRequest struct {
+	Config      json.RawMessage `json:"config"`
+	Description string          `json:"description,omitempty"`
+}
+
+type PolicyListResponse struct {
+	Versions []db.PolicyVersion `json:"versions"` // newest first
+}
+
+type PolicyDiffResponse struct {
+	From    int             `json:"from"`
+	To      int             `json:"to"`
+	Changes []policy.Change `json:"changes"`
+}
+
+type ResultQueueHealth struct {
+	Depth      int64   `json:"depth"`
+	Capacity   int64   `json:"capacity"` // -1 when unbounded
+	Saturation float64 `json:"saturation"`
+	Spilled    int64   `json:"spilled,omitempty"` // on disk, part of Depth
+}
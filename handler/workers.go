@@ -0,0 +1,169 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/satyamraj1643/janus/internal/lease"
+	"github.com/satyamraj1643/janus/internal/policy"
+	"github.com/satyamraj1643/janus/internal/store"
+	"github.com/satyamraj1643/janus/middleware"
+	"github.com/satyamraj1643/janus/queue"
+)
+
+// WorkerHandler lets workers pull admitted jobs and report how they ran
+type WorkerHandler struct {
+	Leases *lease.Manager
+}
+
+// POST /workers/lease
+func (h *WorkerHandler) Lease(w http.ResponseWriter, r *http.Request) {
+	slog.Debug("request", "method", r.Method, "path", r.URL.Path)
+
+	defer r.Body.Close()
+
+	// The body is optional, an empty one leases a single job
+	var req LeaseRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid json", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if req.Max <= 0 {
+		req.Max = 1
+	}
+	if req.Max > lease.MaxLeasesPerRequest {
+		http.Error(w, "max cannot exceed 100", http.StatusBadRequest)
+		return
+	}
+
+	activeConfig, _, ownerID, _ := middleware.GetActiveContext(r.Context())
+	activePolicy, err := policy.ParseConfig(activeConfig)
+	if err != nil {
+		http.Error(w, "invalid active config", http.StatusInternalServerError)
+		return
+	}
+
+	grants, err := h.Leases.Lease(r.Context(), ownerID,
+		store.LeaseFilter{TenantID: req.TenantID, Dependency: req.Dependency},
+		req.Max, activePolicy.DefaultJobPolicy.Execution.LeaseTimeout(), activePolicy.DefaultJobPolicy.Execution.MaxLease(),
+	)
+	if err != nil {
+		slog.Error("lease failed", "err", err)
+		http.Error(w, "internal service error", http.StatusInternalServerError)
+		return
+	}
+
+	if grants == nil {
+		grants = []lease.Grant{}
+	}
+	for _, g := range grants {
+		queue.Record(r.Context(), g.Decision())
+	}
+
+	writeJSON(w, http.StatusOK, LeaseResponse{Leases: grants})
+}
+
+// POST /workers/leases/{id}/heartbeat
+// Body {"extend_ms": n} asks for less than the lease timeout, an empty body extends by the full timeout.
+func (h *WorkerHandler) Heartbeat(w http.ResponseWriter, r *http.Request) {
+	slog.Debug("request", "method", r.Method, "path", r.URL.Path)
+
+	defer r.Body.Close()
+
+	var req HeartbeatRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid json", http.StatusBadRequest)
+			return
+		}
+	}
+	if req.ExtendMs < 0 {
+		http.Error(w, "extend_ms cannot be negative", http.StatusBadRequest)
+		return
+	}
+
+	leaseID := r.PathValue("id")
+
+	beat, err := h.Leases.Heartbeat(r.Context(), middleware.GetUserID(r.Context()), leaseID,
+		time.Duration(req.ExtendMs)*time.Millisecond,
+	)
+	if err != nil {
+		writeLeaseError(w, leaseID, err)
+		return
+	}
+	if beat.Running != nil {
+		queue.Record(r.Context(), beat.Running)
+	}
+
+	resp := HeartbeatResponse{LeaseID: leaseID, Deadline: beat.Deadline, Permit: beat.Permit}
+	if !beat.MaxDeadline.IsZero() {
+		resp.MaxDeadline = &beat.MaxDeadline
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// POST /workers/leases/{id}/complete
+func (h *WorkerHandler) Complete(w http.ResponseWriter, r *http.Request) {
+	slog.Debug("request", "method", r.Method, "path", r.URL.Path)
+
+	leaseID := r.PathValue("id")
+
+	decision, err := h.Leases.Complete(r.Context(), middleware.GetUserID(r.Context()), leaseID)
+	if err != nil {
+		writeLeaseError(w, leaseID, err)
+		return
+	}
+
+	queue.Record(r.Context(), decision)
+
+	writeJSON(w, http.StatusOK, LeaseResultResponse{LeaseID: leaseID, JobID: decision.JobID, Status: decision.Status})
+}
+
+// POST /workers/leases/{id}/fail
+func (h *WorkerHandler) Fail(w http.ResponseWriter, r *http.Request) {
+	slog.Debug("request", "method", r.Method, "path", r.URL.Path)
+
+	defer r.Body.Close()
+
+	// The body is optional
+	var req FailRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid json", http.StatusBadRequest)
+			return
+		}
+	}
+	if req.Reason == "" {
+		req.Reason = "worker_failed"
+	}
+
+	leaseID := r.PathValue("id")
+
+	decision, err := h.Leases.Fail(r.Context(), middleware.GetUserID(r.Context()), leaseID, req.Reason)
+	if err != nil {
+		writeLeaseError(w, leaseID, err)
+		return
+	}
+
+	queue.Record(r.Context(), decision)
+
+	writeJSON(w, http.StatusOK, LeaseResultResponse{LeaseID: leaseID, JobID: decision.JobID, Status: decision.Status})
+}
+
+// writeLeaseError maps lease errors to responses, a lease that is gone was reclaimed or already finished
+func writeLeaseError(w http.ResponseWriter, leaseID string, err error) {
+	if errors.Is(err, store.ErrLeaseNotFound) {
+		http.Error(w, "lease not found or expired", http.StatusNotFound)
+		return
+	}
+
+	slog.Error("lease operation failed", "lease_id", leaseID, "err", err)
+	http.Error(w, "internal service error", http.StatusInternalServerError)
+}
@@ -0,0 +1,135 @@
+package admission
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/satyamraj1643/janus/permit"
+	"github.com/satyamraj1643/janus/spec"
+)
+
+func statuses(decisions []*spec.JobDecision) []string {
+	out := make([]string, len(decisions))
+	for i, d := range decisions {
+		out[i] = d.Status + "/" + d.Reason
+	}
+	return out
+}
+
+func TestCheckBatchPriorityAdmitsHighestFirst(t *testing.T) {
+	ac, _, _ := newTestController(t)
+	cfg := testConfig(t, 2, nil)
+
+	jobs := []spec.Job{
+		testJob("owner-a", "low", cfg),
+		testJob("owner-a", "high", cfg),
+		testJob("owner-a", "mid", cfg),
+	}
+	jobs[0].Priority, jobs[1].Priority, jobs[2].Priority = 1, 9, 5
+
+	decisions, err := ac.CheckBatchPriority(context.Background(), jobs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"rejected/rate_limit_exceeded", "accepted/", "accepted/"}
+	for i, got := range statuses(decisions) {
+		if got != want[i] || decisions[i].JobID != jobs[i].ID {
+			t.Fatalf("got %v, want %v in request order", statuses(decisions), want)
+		}
+	}
+}
+
+func TestCheckBatchPrefixStopsAtFirstRejection(t *testing.T) {
+	ac, _, _ := newTestController(t)
+	cfg := testConfig(t, 2, nil)
+
+	jobs := []spec.Job{
+		testJob("owner-a", "job-1", cfg),
+		testJob("owner-a", "job-2", cfg),
+		testJob("owner-a", "job-3", cfg),
+		testJob("owner-a", "job-4", cfg),
+	}
+
+	decisions, err := ac.CheckBatchPrefix(context.Background(), jobs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"accepted/", "accepted/", "rejected/rate_limit_exceeded", "rejected/batch_prefix_ended"}
+	for i, got := range statuses(decisions) {
+		if got != want[i] {
+			t.Fatalf("got %v, want %v", statuses(decisions), want)
+		}
+	}
+}
+
+func TestCheckBatchPrefixEndsAtInvalidJob(t *testing.T) {
+	ac, _, _ := newTestController(t)
+	cfg := testConfig(t, 10, nil)
+
+	jobs := []spec.Job{
+		testJob("owner-a", "job-1", cfg),
+		testJob("owner-a", "job-2", nil),
+		testJob("owner-a", "job-3", cfg),
+	}
+
+	decisions, err := ac.CheckBatchPrefix(context.Background(), jobs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"accepted/", "rejected/invalid_config", "rejected/batch_prefix_ended"}
+	for i, got := range statuses(decisions) {
+		if got != want[i] {
+			t.Fatalf("got %v, want %v", statuses(decisions), want)
+		}
+	}
+}
+
+func TestBatchReplayMatchesSingleJobReplay(t *testing.T) {
+	ctx := context.Background()
+	ac, s, _ := newTestController(t)
+	ac.Ready = s
+
+	signer, err := permit.NewSigner(bytes.Repeat([]byte{1}, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ac.Permits = signer
+
+	permitted := testConfig(t, 10, nil)
+	dispatched := testConfig(t, 10, func(cfg map[string]any) {
+		cfg["default_job_policy"].(map[string]any)["execution"] = map[string]any{"dispatch": true}
+	})
+	jobs := []spec.Job{testJob("owner-a", "permitted", permitted), testJob("owner-a", "dispatched", dispatched)}
+
+	first, err := ac.CheckBatchPartial(ctx, jobs)
+	if err != nil || first[0].Permit == "" || !first[1].Dispatched {
+		t.Fatalf("batch got %+v, %v, want a permit and a dispatched job", first, err)
+	}
+
+	for _, check := range []func() ([]*spec.JobDecision, error){
+		func() ([]*spec.JobDecision, error) { return ac.CheckBatchPartial(ctx, jobs) },
+		func() ([]*spec.JobDecision, error) {
+			a, err := ac.Check(ctx, jobs[0])
+			if err != nil {
+				return nil, err
+			}
+			b, err := ac.Check(ctx, jobs[1])
+			return []*spec.JobDecision{a, b}, err
+		},
+	} {
+		again, err := check()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !again[0].Replayed || again[0].Permit != first[0].Permit {
+			t.Fatalf("replay %+v, want the original permit", again[0])
+		}
+		if !again[1].Replayed || !again[1].Dispatched {
+			t.Fatalf("replay %+v, want it dispatched", again[1])
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package admission
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/satyamraj1643/janus/internal/lifecycle"
+	"github.com/satyamraj1643/janus/internal/policy"
+	"github.com/satyamraj1643/janus/internal/store"
+	"github.com/satyamraj1643/janus/spec"
+)
+
+// ErrNotCancellable is returned for jobs Janus no longer holds, either finished
+// or accepted without dispatch longer ago than the permit TTL
+var ErrNotCancellable = errors.New("job cannot be cancelled")
+
+// ErrCancelConflict is returned while the job is being released from the schedule
+// or wait queue; cancelling again shortly after finds it in its next state
+var ErrCancelConflict = errors.New("job is being released, try again")
+
+// cancelErr reports a withdrawal that lost to a release as ErrCancelConflict
+func cancelErr(err error) error {
+	if errors.Is(err, store.ErrClaimed) {
+		return ErrCancelConflict
+	}
+	return err
+}
+
+// Cancel withdraws a scheduled, deferred, dispatched or recently accepted job of the owner.
+// A dispatched job that never ran, or an accepted one that was not dispatched, gives its
+// tokens back to the global, tenant and dependency buckets; a leased one loses its lease
+// so the worker's next heartbeat fails.
+// The idempotency key is cleared so the job can be submitted again.
+// Returns ErrNotCancellable if the job is not held by any of those queues, and
+// ErrCancelConflict if it is being released from one.
+func (ac *AdmissionController) Cancel(ctx context.Context, ownerID, jobID string) (*spec.JobDecision, error) {
+	if ac.Scheduled != nil {
+		scheduled, err := ac.Scheduled.GetScheduled(ctx, ownerID, jobID)
+		if err != nil {
+			return nil, err
+		}
+		if scheduled != nil {
+			var entry scheduledEntry
+			if err := json.Unmarshal(scheduled.Entry, &entry); err != nil {
+				return nil, fmt.Errorf("corrupt scheduled entry for job %s: %w", jobID, err)
+			}
+			job, err := spec.DecodeJob(entry.Job)
+			if err != nil {
+				return nil, fmt.Errorf("corrupt scheduled job %s: %w", jobID, err)
+			}
+			if job.OwnerID == ownerID {
+				removed, err := ac.Scheduled.WithdrawScheduled(ctx, ownerID, jobID)
+				if err != nil {
+					return nil, cancelErr(err)
+				}
+				// Otherwise released meanwhile, it may be deferred or dispatched now
+				if removed {
+					return ac.cancelled(ctx, job, 0), nil
+				}
+			}
+		}
+	}
+
+	if ac.Waiting != nil {
+		waiting, err := ac.Waiting.GetWaiting(ctx, ownerID, jobID)
+		if err != nil {
+			return nil, err
+		}
+		if waiting != nil {
+			var entry deferredEntry
+			if err := json.Unmarshal(waiting.Entry, &entry); err != nil {
+				return nil, fmt.Errorf("corrupt deferred entry for job %s: %w", jobID, err)
+			}
+			job, err := spec.DecodeJob(entry.Job)
+			if err != nil {
+				return nil, fmt.Errorf("corrupt deferred job %s: %w", jobID, err)
+			}
+			if job.OwnerID == ownerID {
+				removed, err := ac.Waiting.WithdrawWaiting(ctx, *waiting)
+				if err != nil {
+					return nil, cancelErr(err)
+				}
+				// Otherwise released meanwhile, it may be dispatched now
+				if removed {
+					return ac.cancelled(ctx, job, 0), nil
+				}
+			}
+		}
+	}
+
+	if ac.Ready != nil {
+		removed, err := ac.Ready.RemoveReady(ctx, ownerID, jobID)
+		if err != nil {
+			return nil, err
+		}
+		if removed != nil {
+			job, err := spec.DecodeJob(removed.Job)
+			if err != nil {
+				return nil, fmt.Errorf("corrupt ready job %s: %w", jobID, err)
+			}
+			_ = ac.Ready.ClearAttempts(ctx, ownerID, jobID)
+
+			// Tokens are only given back for work that never reached a worker
+			if removed.Attempt == 0 {
+				if err := ac.refund(ctx, job); err != nil {
+					return nil, err
+				}
+			}
+			return ac.cancelled(ctx, job, removed.Attempt), nil
+		}
+	}
+
+	if ac.Admitted != nil {
+		raw, err := ac.Admitted.TakeAdmitted(ctx, ownerID, jobID)
+		if err != nil {
+			return nil, err
+		}
+		if raw != nil {
+			job, err := spec.DecodeJob(raw)
+			if err != nil {
+				return nil, fmt.Errorf("corrupt admitted job %s: %w", jobID, err)
+			}
+			if err := ac.refund(ctx, job); err != nil {
+				return nil, err
+			}
+			return ac.cancelled(ctx, job, 0), nil
+		}
+	}
+
+	return nil, ErrNotCancellable
+}
+
+// refund returns what admitting the job took from its buckets, under its policy snapshot
+func (ac *AdmissionController) refund(ctx context.Context, job spec.Job) error {
+	jobPolicy, err := policy.ParseConfig(job.Config)
+	if err != nil {
+		return err
+	}
+	tempAC := ac.withPolicy(jobPolicy)
+
+	var reqs []store.RateLimitReq
+	reqs = append(reqs, tempAC.getGlobalLimitParameters(job))
+	reqs = append(reqs, tempAC.getTenanatQuotaParams(job))
+	reqs = append(reqs, tempAC.getDependencyParams(job)...)
+
+	return ac.Store.RefundTokens(ctx, reqs)
+}
+
+func (ac *AdmissionController) cancelled(ctx context.Context, job spec.Job, attempt int) *spec.JobDecision {
+	_ = ac.Store.ClearIdempotency(ctx, idempotencyKey(job))
+
+	return &spec.JobDecision{
+		JobID:     job.ID,
+		BatchID:   job.BatchID,
+		BatchName: job.BatchName,
+		Status:    lifecycle.Cancelled,
+		Timestamp: time.Now(),
+		Attempt:   attempt,
+		Actor:     lifecycle.ActorOwner,
+		Job:       job,
+	}
+}
@@ -0,0 +1,66 @@
+package admission
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/satyamraj1643/janus/internal/lifecycle"
+)
+
+func TestCancelAcceptedRefundsTokens(t *testing.T) {
+	ctx := context.Background()
+	ac, s, _ := newTestController(t)
+	ac.Admitted = s
+	cfg := testConfig(t, 1, nil)
+
+	if d, err := ac.Check(ctx, testJob("owner-a", "job-1", cfg)); err != nil || d.Status != "accepted" {
+		t.Fatalf("got %+v, %v", d, err)
+	}
+
+	if _, err := ac.Cancel(ctx, "owner-b", "job-1"); !errors.Is(err, ErrNotCancellable) {
+		t.Fatalf("another owner got %v, want ErrNotCancellable", err)
+	}
+
+	d, err := ac.Cancel(ctx, "owner-a", "job-1")
+	if err != nil || d.Status != lifecycle.Cancelled || d.Actor != lifecycle.ActorOwner {
+		t.Fatalf("got %+v, %v, want cancelled by the owner", d, err)
+	}
+	if _, err := ac.Cancel(ctx, "owner-a", "job-1"); !errors.Is(err, ErrNotCancellable) {
+		t.Fatalf("cancelling twice got %v, want ErrNotCancellable", err)
+	}
+
+	// The quota of one job is free again and the job ID can be reused
+	if d, err := ac.Check(ctx, testJob("owner-a", "job-1", cfg)); err != nil || d.Status != "accepted" || d.Replayed {
+		t.Fatalf("resubmitted: %+v, %v, want accepted afresh", d, err)
+	}
+}
+
+func TestCancelScheduled(t *testing.T) {
+	ctx := context.Background()
+	ac, s, _ := newTestController(t)
+	ac.Scheduled = s
+	cfg := testConfig(t, 10, nil)
+
+	for _, id := range []string{"job-1", "job-2"} {
+		job := testJob("owner-a", id, cfg)
+		runAt := time.Now().Add(time.Hour)
+		job.NotBefore = &runAt
+		if d, err := ac.Check(ctx, job); err != nil || d.Status != "scheduled" {
+			t.Fatalf("got %+v, %v", d, err)
+		}
+	}
+
+	if d, err := ac.Cancel(ctx, "owner-a", "job-1"); err != nil || d.Status != lifecycle.Cancelled {
+		t.Fatalf("got %+v, %v", d, err)
+	}
+
+	// Being released by the scheduler
+	if _, err := s.ClaimDue(ctx, time.Now().Add(2*time.Hour), 10, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ac.Cancel(ctx, "owner-a", "job-2"); !errors.Is(err, ErrCancelConflict) {
+		t.Fatalf("claimed job got %v, want ErrCancelConflict", err)
+	}
+}
@@ -2,17 +2,29 @@ package admission
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sort"
 	"time"
 
+	"github.com/satyamraj1643/janus/internal/metrics"
 	"github.com/satyamraj1643/janus/internal/policy"
 	"github.com/satyamraj1643/janus/internal/store"
+	"github.com/satyamraj1643/janus/internal/tracing"
+	"github.com/satyamraj1643/janus/permit"
 	"github.com/satyamraj1643/janus/spec"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 type AdmissionController struct {
-	Policy *policy.Policy
-	Store  store.StateStore
+	Policy  *policy.Policy
+	Store   store.StateStore
+	Waiting store.WaitQueue  // optional, enables the defer policy
+	Ready   store.ReadyQueue // optional, enables execution.dispatch
+
+	Scheduled store.ScheduleQueue // optional, enables not_before
+	Permits   *permit.Signer      // optional, signs a permit for accepted jobs that are not dispatched
+	Admitted  store.AdmittedStore // optional, lets accepted jobs that were not dispatched be cancelled
 }
 
 /*
@@ -28,6 +40,13 @@ func NewAdmissionController(
 	}
 }
 
+// withPolicy returns a lightweight copy of the controller bound to a job's policy
+func (ac *AdmissionController) withPolicy(p *policy.Policy) *AdmissionController {
+	tempAC := *ac
+	tempAC.Policy = p
+	return &tempAC
+}
+
 func (ac *AdmissionController) Reject(
 	job spec.Job,
 	reason string,
@@ -59,10 +78,44 @@ func (ac *AdmissionController) Accept(
 	}
 }
 
+// Rule names the admission rule that produced reason, empty for reasons no rule gives
+func Rule(reason string) string {
+	switch reason {
+	case "priority_too_low":
+		return "priority"
+	case "invalid_schedule", "deadline_exceeded":
+		return "schedule"
+	case "duplicate_request":
+		return "idempotency"
+	case "rate_limit_exceeded", "batch_quota_exceeded", "batch_prefix_ended":
+		return "rate_limit"
+	case "max_wait_exceeded":
+		return "defer"
+	case "invalid_config":
+		return "config"
+	}
+	return ""
+}
+
+// Retryable reports whether resubmitting a job rejected for reason can succeed
+// without changing the job or the policy. A duplicate_request is not: the first
+// submission owns the decision, resubmitting only replays it or loops
+func Retryable(reason string) bool {
+	switch reason {
+	case "rate_limit_exceeded", "batch_quota_exceeded", "batch_prefix_ended", "store_error":
+		return true
+	}
+	return false
+}
+
 func (ac *AdmissionController) Check(
 	ctx context.Context,
 	job spec.Job,
 ) (*spec.JobDecision, error) {
+	defer metrics.Since(metrics.CheckDuration.WithLabelValues("check"), time.Now())
+
+	ctx, span := tracing.Start(ctx, "AdmissionController.Check", attribute.String("janus.job_id", job.ID))
+	defer span.End()
 
 	// Parse per-job config from DB
 	jobPolicy, err := policy.ParseConfig(job.Config)
@@ -71,46 +124,109 @@ func (ac *AdmissionController) Check(
 	}
 
 	// Create a temporary controller with the job's policy
-	tempAC := &AdmissionController{
-		Policy: jobPolicy,
-		Store:  ac.Store,
-	}
+	tempAC := ac.withPolicy(jobPolicy)
 
 	// 0. Priority check
-	if err := tempAC.checkPriority(ctx, job); err != nil {
+	if err := rule(ctx, "priority", func(ctx context.Context) error {
+		return tempAC.checkPriority(ctx, job)
+	}); err != nil {
 		return ac.Reject(job, "priority_too_low", err)
 	}
 
+	now := time.Now()
+	var scheduleReason string
+	if err := rule(ctx, "schedule", func(context.Context) (err error) {
+		scheduleReason, err = checkSchedule(job, now)
+		return err
+	}); err != nil {
+		return ac.rejectSchedule(job, scheduleReason, err), nil
+	}
+
 	// 1. Idempotency check
-	if err := tempAC.checkIdempotency(ctx, job); err != nil {
+	if err := rule(ctx, "idempotency", func(ctx context.Context) error {
+		return tempAC.checkIdempotency(ctx, job)
+	}); err != nil {
+		// Producers retry on lost responses, give them the original answer
+		if prior := ac.replayDecision(ctx, job); prior != nil {
+			return prior, nil
+		}
 		return ac.Reject(job, "duplicate_request", err)
 	}
 
+	// Admission runs once not_before has passed
+	if isScheduled(job, now) {
+		return ac.scheduleJob(ctx, job), nil
+	}
+
 	// 2. Prepare rate-limit requests
 	var reqs []store.RateLimitReq
-	reqs = append(reqs, tempAC.getGlobalLimitParameters())
+	reqs = append(reqs, tempAC.getGlobalLimitParameters(job))
 	reqs = append(reqs, tempAC.getTenanatQuotaParams(job))
 	reqs = append(reqs, tempAC.getDependencyParams(job)...)
 
 	// 3. Atomic verification
-	allowed, err := ac.Store.AllowRequestAtomic(ctx, reqs)
-	if err != nil {
-		_ = ac.Store.ClearIdempotency(ctx, job.ID)
+	var allowed bool
+	err = rule(ctx, "rate_limit", func(ctx context.Context) (err error) {
+		allowed, err = ac.Store.AllowRequestAtomic(ctx, reqs)
+		if err == nil && !allowed {
+			return errQuotaExceeded
+		}
+		return err
+	})
+	if err != nil && !errors.Is(err, errQuotaExceeded) {
+		_ = ac.Store.ClearIdempotency(ctx, idempotencyKey(job))
 		return ac.Reject(job, "store_error", err)
 	}
 
 	if !allowed {
-		_ = ac.Store.ClearIdempotency(ctx, job.ID)
-		return ac.Reject(job, "rate_limit_exceeded", fmt.Errorf("quota exceeded"))
+		// Only rate limits stood in the way, hold the job if the policy allows it
+		if deferred := tempAC.deferJob(ctx, job, true); deferred != nil {
+			return deferred, nil
+		}
+
+		_ = ac.Store.ClearIdempotency(ctx, idempotencyKey(job))
+		decision, err := ac.Reject(job, "rate_limit_exceeded", errQuotaExceeded)
+		decision.RetryAfterMs = retryAfter(reqs).Milliseconds()
+		return decision, err
 	}
 
-	return ac.Accept(job), nil
+	decision := tempAC.dispatch(ctx, job, ac.Accept(job))
+	if decision.Status != "accepted" {
+		return decision, fmt.Errorf("job %s admitted but not dispatched", job.ID)
+	}
+
+	// Best effort: without the record a retry degrades to duplicate_request
+	_ = ac.rememberDecision(ctx, job, decision)
+
+	return decision, nil
+}
+
+// Redrive admits a job again that was admitted before but never completed.
+// Its idempotency key is released first, otherwise Check would replay the old decision.
+func (ac *AdmissionController) Redrive(
+	ctx context.Context,
+	job spec.Job,
+) (*spec.JobDecision, error) {
+	defer metrics.Since(metrics.CheckDuration.WithLabelValues("redrive"), time.Now())
+
+	ctx, span := tracing.Start(ctx, "AdmissionController.Redrive", attribute.String("janus.job_id", job.ID))
+	defer span.End()
+
+	if err := ac.Store.ClearIdempotency(ctx, idempotencyKey(job)); err != nil {
+		return ac.Reject(job, "store_error", err)
+	}
+	return ac.Check(ctx, job)
 }
 
 func (ac *AdmissionController) CheckBatchAtomic(
 	ctx context.Context,
 	jobs []spec.Job,
 ) ([]*spec.JobDecision, error) {
+	defer metrics.Since(metrics.CheckDuration.WithLabelValues("batch_atomic"), time.Now())
+
+	ctx, span := tracing.Start(ctx, "AdmissionController.CheckBatchAtomic", attribute.Int("janus.batch_size", len(jobs)))
+	defer span.End()
+
 	if len(jobs) == 0 {
 		return nil, nil
 	}
@@ -121,6 +237,7 @@ func (ac *AdmissionController) CheckBatchAtomic(
 	decisions := make([]*spec.JobDecision, len(jobs))
 	var allReqs []store.RateLimitReq
 	var validJobs []spec.Job
+	var validACs []*AdmissionController
 	var validIndices []int
 
 	// 1. Pre-validation loop
@@ -132,7 +249,7 @@ func (ac *AdmissionController) CheckBatchAtomic(
 			continue
 		}
 
-		tempAC := &AdmissionController{Policy: jobPolicy, Store: ac.Store} // lightweight
+		tempAC := ac.withPolicy(jobPolicy) // lightweight
 
 		if err := tempAC.checkPriority(ctx, job); err != nil {
 			d, _ := ac.Reject(job, "priority_too_low", err)
@@ -140,18 +257,36 @@ func (ac *AdmissionController) CheckBatchAtomic(
 			continue
 		}
 
+		if reason, err := checkSchedule(job, time.Now()); err != nil {
+			decisions[i] = ac.rejectSchedule(job, reason, err)
+			continue
+		}
+
+		// A held job could not be taken back if the rest of the batch fails
+		if isScheduled(job, time.Now()) {
+			d, _ := ac.Reject(job, "invalid_schedule", fmt.Errorf("atomic batches cannot contain scheduled jobs"))
+			decisions[i] = d
+			continue
+		}
+
 		if err := tempAC.checkIdempotency(ctx, job); err != nil {
+			// Already decided, the original answer takes no part in this batch
+			if prior := ac.replayDecision(ctx, job); prior != nil {
+				decisions[i] = prior
+				continue
+			}
 			d, _ := ac.Reject(job, "duplicate_request", err)
 			decisions[i] = d
 			continue
 		}
 
 		// Collect limits provided
-		allReqs = append(allReqs, tempAC.getGlobalLimitParameters())
+		allReqs = append(allReqs, tempAC.getGlobalLimitParameters(job))
 		allReqs = append(allReqs, tempAC.getTenanatQuotaParams(job))
 		allReqs = append(allReqs, tempAC.getDependencyParams(job)...)
 
 		validJobs = append(validJobs, job)
+		validACs = append(validACs, tempAC)
 		validIndices = append(validIndices, i)
 	}
 
@@ -166,7 +301,7 @@ func (ac *AdmissionController) CheckBatchAtomic(
 	if err != nil {
 		// System error - reject all remaining
 		for _, idx := range validIndices {
-			_ = ac.Store.ClearIdempotency(ctx, jobs[idx].ID)
+			_ = ac.Store.ClearIdempotency(ctx, idempotencyKey(jobs[idx]))
 			d, _ := ac.Reject(jobs[idx], "store_error", err)
 			decisions[idx] = d
 		}
@@ -175,18 +310,243 @@ func (ac *AdmissionController) CheckBatchAtomic(
 
 	if !allowed {
 		// Atomic failure - reject all remaining
+		retryAfterMs := retryAfter(allReqs).Milliseconds()
 		for _, idx := range validIndices {
-			_ = ac.Store.ClearIdempotency(ctx, jobs[idx].ID)
+			_ = ac.Store.ClearIdempotency(ctx, idempotencyKey(jobs[idx]))
 			d, _ := ac.Reject(jobs[idx], "batch_quota_exceeded", fmt.Errorf("atomic batch rejected"))
+			d.RetryAfterMs = retryAfterMs
 			decisions[idx] = d
 		}
 		return decisions, nil
 	}
 
 	// 3. Accept all valid
-	for _, idx := range validIndices {
-		decisions[idx] = ac.Accept(jobs[idx])
+	// A job that fails to dispatch is rejected on its own, its quota is already spent
+	for n, idx := range validIndices {
+		decisions[idx] = validACs[n].dispatch(ctx, jobs[idx], ac.Accept(jobs[idx]))
+		if decisions[idx].Status == "accepted" {
+			_ = ac.rememberDecision(ctx, jobs[idx], decisions[idx])
+		}
+	}
+
+	return decisions, nil
+}
+
+// CheckBatchPartial evaluates jobs in order with a single store round trip.
+// Every job that fits is admitted; the others are rejected without affecting the rest.
+func (ac *AdmissionController) CheckBatchPartial(
+	ctx context.Context,
+	jobs []spec.Job,
+) ([]*spec.JobDecision, error) {
+	defer metrics.Since(metrics.CheckDuration.WithLabelValues("batch_partial"), time.Now())
+
+	ctx, span := tracing.Start(ctx, "AdmissionController.CheckBatchPartial", attribute.Int("janus.batch_size", len(jobs)))
+	defer span.End()
+
+	return ac.checkBatchOrdered(ctx, jobs, false)
+}
+
+// CheckBatchPrefix admits the longest in-order prefix of jobs that fits.
+// The first job that does not fit, and every job after it, is rejected.
+func (ac *AdmissionController) CheckBatchPrefix(
+	ctx context.Context,
+	jobs []spec.Job,
+) ([]*spec.JobDecision, error) {
+	defer metrics.Since(metrics.CheckDuration.WithLabelValues("batch_prefix"), time.Now())
+
+	ctx, span := tracing.Start(ctx, "AdmissionController.CheckBatchPrefix", attribute.Int("janus.batch_size", len(jobs)))
+	defer span.End()
+
+	return ac.checkBatchOrdered(ctx, jobs, true)
+}
+
+// CheckBatchPriority evaluates jobs from highest to lowest Priority, admitting each that
+// fits, so the most important jobs win when quota is tight. Ties keep request order.
+// Decisions are returned in request order.
+func (ac *AdmissionController) CheckBatchPriority(
+	ctx context.Context,
+	jobs []spec.Job,
+) ([]*spec.JobDecision, error) {
+	defer metrics.Since(metrics.CheckDuration.WithLabelValues("batch_priority"), time.Now())
+
+	ctx, span := tracing.Start(ctx, "AdmissionController.CheckBatchPriority", attribute.Int("janus.batch_size", len(jobs)))
+	defer span.End()
+
+	order := make([]int, len(jobs))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return jobs[order[a]].Priority > jobs[order[b]].Priority
+	})
+
+	sorted := make([]spec.Job, len(jobs))
+	for n, idx := range order {
+		sorted[n] = jobs[idx]
+	}
+
+	sortedDecisions, err := ac.checkBatchOrdered(ctx, sorted, false)
+	if err != nil {
+		return nil, err
+	}
+
+	decisions := make([]*spec.JobDecision, len(jobs))
+	for n, idx := range order {
+		decisions[idx] = sortedDecisions[n]
+	}
+
+	return decisions, nil
+}
+
+func (ac *AdmissionController) checkBatchOrdered(
+	ctx context.Context,
+	jobs []spec.Job,
+	stopOnReject bool,
+) ([]*spec.JobDecision, error) {
+	if len(jobs) == 0 {
+		return nil, nil
+	}
+
+	decisions := make([]*spec.JobDecision, len(jobs))
+	var batchReqs []store.BatchAdmissionReq
+	var batchACs []*AdmissionController
+	var validIndices []int
+
+	// 1. Pre-validation loop (no store access)
+	for i, job := range jobs {
+		jobPolicy, err := policy.ParseConfig(job.Config)
+		if err != nil {
+			d, _ := ac.Reject(job, "invalid_config", err)
+			decisions[i] = d
+			if stopOnReject {
+				ac.rejectPrefixTail(jobs, decisions, i+1)
+				break
+			}
+			continue
+		}
+
+		tempAC := ac.withPolicy(jobPolicy)
+
+		if err := tempAC.checkPriority(ctx, job); err != nil {
+			d, _ := ac.Reject(job, "priority_too_low", err)
+			decisions[i] = d
+			if stopOnReject {
+				ac.rejectPrefixTail(jobs, decisions, i+1)
+				break
+			}
+			continue
+		}
+
+		now := time.Now()
+		if reason, err := checkSchedule(job, now); err != nil {
+			decisions[i] = ac.rejectSchedule(job, reason, err)
+			if stopOnReject {
+				ac.rejectPrefixTail(jobs, decisions, i+1)
+				break
+			}
+			continue
+		}
+
+		// Scheduled jobs take no quota now, so they neither join nor end the prefix
+		if isScheduled(job, now) {
+			if err := tempAC.checkIdempotency(ctx, job); err != nil {
+				if prior := ac.replayDecision(ctx, job); prior != nil {
+					decisions[i] = prior
+					continue
+				}
+				d, _ := ac.Reject(job, "duplicate_request", err)
+				decisions[i] = d
+				continue
+			}
+			decisions[i] = tempAC.scheduleJob(ctx, job)
+			continue
+		}
+
+		var reqs []store.RateLimitReq
+		reqs = append(reqs, tempAC.getGlobalLimitParameters(job))
+		reqs = append(reqs, tempAC.getTenanatQuotaParams(job))
+		reqs = append(reqs, tempAC.getDependencyParams(job)...)
+
+		// Decide the accepted form up front so the store can record it atomically
+		decisions[i] = ac.Accept(job)
+		record, err := encodeRecord(decisions[i])
+		if err != nil {
+			return nil, err
+		}
+
+		batchReqs = append(batchReqs, store.BatchAdmissionReq{
+			IdempotencyKey:    idempotencyKey(job),
+			IdempotencyWindow: time.Duration(jobPolicy.DefaultJobPolicy.IdempotencyWindowMs) * time.Millisecond,
+			Record:            record,
+			Reqs:              reqs,
+		})
+		batchACs = append(batchACs, tempAC)
+		validIndices = append(validIndices, i)
+	}
+
+	if len(validIndices) == 0 {
+		return decisions, nil
+	}
+
+	// 2. Single round trip
+	allowBatch := ac.Store.AllowBatchPartial
+	if stopOnReject {
+		allowBatch = ac.Store.AllowBatchPrefix
+	}
+
+	results, err := allowBatch(ctx, batchReqs)
+	if err != nil {
+		for _, idx := range validIndices {
+			d, _ := ac.Reject(jobs[idx], "store_error", err)
+			decisions[idx] = d
+		}
+		return decisions, nil
+	}
+
+	// 3. Map results back to request order
+	for n, idx := range validIndices {
+		res := results[n]
+
+		switch {
+		case res.Admitted:
+			// The store recorded the accepted decision, the permit and dispatch are added
+			// once known, as Check does
+			decisions[idx] = batchACs[n].dispatch(ctx, jobs[idx], decisions[idx])
+			if decisions[idx].Status == "accepted" {
+				_ = ac.rememberDecision(ctx, jobs[idx], decisions[idx])
+			}
+		case res.Skipped:
+			d, _ := ac.Reject(jobs[idx], "batch_prefix_ended", fmt.Errorf("an earlier job in the batch was rejected"))
+			decisions[idx] = d
+		case res.Duplicate:
+			if prior := decodeRecord(res.Prior, jobs[idx]); prior != nil {
+				decisions[idx] = prior
+				continue
+			}
+			d, _ := ac.Reject(jobs[idx], "duplicate_request", fmt.Errorf("job %s already submitted", clientKey(jobs[idx])))
+			decisions[idx] = d
+		default:
+			// A prefix must stay in order, so only partial batches defer
+			if !stopOnReject {
+				if deferred := batchACs[n].deferJob(ctx, jobs[idx], false); deferred != nil {
+					decisions[idx] = deferred
+					continue
+				}
+			}
+
+			d, _ := ac.Reject(jobs[idx], "rate_limit_exceeded", errQuotaExceeded)
+			d.RetryAfterMs = retryAfter(batchReqs[n].Reqs).Milliseconds()
+			decisions[idx] = d
+		}
 	}
 
 	return decisions, nil
 }
+
+// rejectPrefixTail rejects jobs[from:] once a prefix-mode batch has ended
+func (ac *AdmissionController) rejectPrefixTail(jobs []spec.Job, decisions []*spec.JobDecision, from int) {
+	for i := from; i < len(jobs); i++ {
+		d, _ := ac.Reject(jobs[i], "batch_prefix_ended", fmt.Errorf("an earlier job in the batch was rejected"))
+		decisions[i] = d
+	}
+}
@@ -0,0 +1,212 @@
+package admission
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/satyamraj1643/janus/internal/policy"
+	"github.com/satyamraj1643/janus/internal/store"
+	"github.com/satyamraj1643/janus/spec"
+)
+
+// newTestController returns a controller on an in-memory Redis that lives as long as the test
+func newTestController(t *testing.T) (*AdmissionController, *store.RedisStore, *miniredis.Miniredis) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	s := store.NewRedisStore(mr.Addr())
+	return NewAdmissionController(s), s, mr
+}
+
+// testConfig is a config admitting maxJobs jobs per minute, changed by edit before it is encoded
+func testConfig(t *testing.T, maxJobs int, edit func(cfg map[string]any)) json.RawMessage {
+	t.Helper()
+	cfg := map[string]any{
+		"global_execution_limit": map[string]any{
+			"max_jobs":                  maxJobs,
+			"window_ms":                 60000,
+			"max_concurrent_per_tenant": maxJobs,
+		},
+		"default_job_policy": map[string]any{
+			"idempotency_window_ms": 60000,
+		},
+	}
+	if edit != nil {
+		edit(cfg)
+	}
+
+	raw, err := json.Marshal(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return raw
+}
+
+// quotaRequests are the token buckets admitting the job takes from
+func quotaRequests(t *testing.T, job spec.Job) []store.RateLimitReq {
+	t.Helper()
+	p, err := policy.ParseConfig(job.Config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ac := (&AdmissionController{}).withPolicy(p)
+	reqs := []store.RateLimitReq{ac.getGlobalLimitParameters(job), ac.getTenanatQuotaParams(job)}
+	return append(reqs, ac.getDependencyParams(job)...)
+}
+
+func testJob(owner, id string, cfg json.RawMessage) spec.Job {
+	return spec.Job{ID: id, TenantID: "tenant-a", OwnerID: owner, BatchID: "batch-1", Config: cfg}
+}
+
+func TestCheckReplaysDuplicates(t *testing.T) {
+	ctx := context.Background()
+	ac, _, _ := newTestController(t)
+	cfg := testConfig(t, 10, nil)
+
+	first, err := ac.Check(ctx, testJob("owner-a", "job-1", cfg))
+	if err != nil || first.Status != "accepted" {
+		t.Fatalf("first submission: %+v, %v", first, err)
+	}
+
+	again, err := ac.Check(ctx, testJob("owner-a", "job-1", cfg))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !again.Replayed || again.Status != "accepted" || !again.Timestamp.Equal(first.Timestamp) {
+		t.Fatalf("duplicate got %+v, want the first decision replayed", again)
+	}
+}
+
+func TestIdempotencyIsScopedToOwner(t *testing.T) {
+	ctx := context.Background()
+	ac, _, _ := newTestController(t)
+	cfg := testConfig(t, 10, nil)
+
+	if _, err := ac.Check(ctx, testJob("owner-a", "job-1", cfg)); err != nil {
+		t.Fatal(err)
+	}
+
+	other, err := ac.Check(ctx, testJob("owner-b", "job-1", cfg))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other.Replayed || other.Status != "accepted" {
+		t.Fatalf("another owner's job with the same ID got %+v, want its own decision", other)
+	}
+}
+
+func TestIdempotencyKeysOfOwnersWithTheSamePrefixDiffer(t *testing.T) {
+	ctx := context.Background()
+	ac, _, _ := newTestController(t)
+	cfg := testConfig(t, 10, nil)
+
+	if _, err := ac.Check(ctx, testJob("a", "b:c", cfg)); err != nil {
+		t.Fatal(err)
+	}
+
+	other, err := ac.Check(ctx, testJob("a:b", "c", cfg))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other.Replayed || other.JobID != "c" {
+		t.Fatalf("owner a:b got %+v, want its own decision", other)
+	}
+}
+
+func TestIdempotencyKeyOverridesJobID(t *testing.T) {
+	ctx := context.Background()
+	ac, _, _ := newTestController(t)
+	cfg := testConfig(t, 10, nil)
+
+	job := testJob("owner-a", "job-1", cfg)
+	job.IdempotencyKey = "key-1"
+	if _, err := ac.Check(ctx, job); err != nil {
+		t.Fatal(err)
+	}
+
+	renamed := testJob("owner-a", "job-2", cfg)
+	renamed.IdempotencyKey = "key-1"
+	decision, err := ac.Check(ctx, renamed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !decision.Replayed || decision.JobID != "job-1" {
+		t.Fatalf("same Idempotency-Key got %+v, want job-1's decision", decision)
+	}
+
+	if decision, _ := ac.Check(ctx, testJob("owner-a", "job-1", cfg)); decision.Replayed {
+		t.Fatal("job_id was deduplicated although the first job used an Idempotency-Key")
+	}
+}
+
+func TestQuotaIsScopedToOwner(t *testing.T) {
+	ctx := context.Background()
+	ac, s, _ := newTestController(t)
+	cfg := testConfig(t, 1, nil)
+
+	if d, err := ac.Check(ctx, testJob("owner-a", "job-1", cfg)); err != nil || d.Status != "accepted" {
+		t.Fatalf("first job: %+v, %v", d, err)
+	}
+	if d, _ := ac.Check(ctx, testJob("owner-a", "job-2", cfg)); d.Status != "rejected" {
+		t.Fatalf("second job of the owner got %s, want its quota used up", d.Status)
+	}
+
+	// Same tenant ID, another owner's buckets
+	if d, err := ac.Check(ctx, testJob("owner-b", "job-3", cfg)); err != nil || d.Status != "accepted" {
+		t.Fatalf("another owner got %+v, %v, want its own quota", d, err)
+	}
+
+	// A new active config starts the owner's buckets afresh
+	if err := s.ResetQuotas(ctx, "owner-a"); err != nil {
+		t.Fatal(err)
+	}
+	if d, err := ac.Check(ctx, testJob("owner-a", "job-4", cfg)); err != nil || d.Status != "accepted" {
+		t.Fatalf("after a reset got %+v, %v", d, err)
+	}
+}
+
+func TestResetQuotasKeepsOwnersWithTheSamePrefix(t *testing.T) {
+	ctx := context.Background()
+	ac, s, _ := newTestController(t)
+	cfg := testConfig(t, 1, nil)
+
+	for _, owner := range []string{"a", "a:b"} {
+		if d, err := ac.Check(ctx, testJob(owner, "job-1", cfg)); err != nil || d.Status != "accepted" {
+			t.Fatalf("owner %s: %+v, %v", owner, d, err)
+		}
+	}
+
+	if err := s.ResetQuotas(ctx, "a"); err != nil {
+		t.Fatal(err)
+	}
+	if d, err := ac.Check(ctx, testJob("a", "job-2", cfg)); err != nil || d.Status != "accepted" {
+		t.Fatalf("after a reset got %+v, %v", d, err)
+	}
+	if d, _ := ac.Check(ctx, testJob("a:b", "job-2", cfg)); d.Status != "rejected" {
+		t.Fatalf("owner a:b got %s, want its quota still used up", d.Status)
+	}
+}
+
+func TestCheckBatchAtomicReplaysDuplicates(t *testing.T) {
+	ctx := context.Background()
+	ac, _, _ := newTestController(t)
+	cfg := testConfig(t, 10, nil)
+
+	first, err := ac.Check(ctx, testJob("owner-a", "job-1", cfg))
+	if err != nil || first.Status != "accepted" {
+		t.Fatalf("first submission: %+v, %v", first, err)
+	}
+
+	decisions, err := ac.CheckBatchAtomic(ctx, []spec.Job{testJob("owner-a", "job-1", cfg), testJob("owner-a", "job-2", cfg)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := decisions[0]; !d.Replayed || d.Status != "accepted" || !d.Timestamp.Equal(first.Timestamp) {
+		t.Fatalf("duplicate got %+v, want the first decision replayed", d)
+	}
+	if d := decisions[1]; d.Replayed || d.Status != "accepted" {
+		t.Fatalf("new job got %+v, want it admitted", d)
+	}
+}
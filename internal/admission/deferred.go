@@ -0,0 +1,197 @@
+package admission
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/satyamraj1643/janus/internal/policy"
+	"github.com/satyamraj1643/janus/internal/store"
+	"github.com/satyamraj1643/janus/spec"
+)
+
+// deferredEntry is what the wait queue keeps for a deferred job
+type deferredEntry struct {
+	Job        json.RawMessage `json:"job"` // spec.EncodeJob
+	DeferredAt time.Time       `json:"deferred_at"`
+	ExpiresAt  time.Time       `json:"expires_at"`
+	Attempts   int             `json:"attempts"`
+}
+
+// deferJob parks a job that was rejected only by rate limits, until the defer
+// policy's max wait or the job's deadline, whichever comes first.
+// marked tells whether the idempotency key is already held for this job.
+// Returns nil if neither allows waiting or the job could not be queued,
+// in which case the caller should reject as usual.
+func (ac *AdmissionController) deferJob(
+	ctx context.Context,
+	job spec.Job,
+	marked bool,
+) *spec.JobDecision {
+	if ac.Waiting == nil {
+		return nil
+	}
+
+	now := time.Now()
+
+	// The defer policy and the job's deadline both bound the wait, whichever ends first
+	var expiresAt time.Time
+	weight := 1
+	agingMs := int64(policy.DefaultDeferAgingMs)
+
+	deferPolicy := ac.Policy.DefaultJobPolicy.Defer
+	if deferPolicy != nil && deferPolicy.MaxWaitMs > 0 {
+		expiresAt = now.Add(time.Duration(min(deferPolicy.MaxWaitMs, policy.MaxDeferWaitMs)) * time.Millisecond)
+		weight = deferPolicy.Weight(job.TenantID)
+		if deferPolicy.AgingMs > 0 {
+			agingMs = deferPolicy.AgingMs
+		}
+	}
+	if job.Deadline != nil && (expiresAt.IsZero() || job.Deadline.Before(expiresAt)) {
+		expiresAt = *job.Deadline
+	}
+	if !expiresAt.After(now) {
+		return nil
+	}
+
+	// Hold the idempotency key while waiting so retries replay the deferral
+	if !marked {
+		if err := ac.checkIdempotency(ctx, job); err != nil {
+			return nil
+		}
+	}
+
+	encoded, err := spec.EncodeJob(job)
+	if err == nil {
+		var entry []byte
+		entry, err = json.Marshal(deferredEntry{
+			Job:        encoded,
+			DeferredAt: now,
+			ExpiresAt:  expiresAt,
+		})
+		if err == nil {
+			err = ac.Waiting.Defer(ctx, store.WaitingJob{
+				JobID:     job.ID,
+				OwnerID:   job.OwnerID,
+				TenantKey: WaitingTenantKey(job),
+				Entry:     entry,
+				// Aging: each priority point counts as having waited agingMs longer,
+				// so low priority work still reaches the head eventually
+				Rank:      float64(now.UnixMilli() - int64(job.Priority)*agingMs),
+				Weight:    weight,
+				ExpiresAt: expiresAt,
+			})
+		}
+	}
+
+	if err != nil {
+		if !marked {
+			_ = ac.Store.ClearIdempotency(ctx, idempotencyKey(job))
+		}
+		return nil
+	}
+
+	decision := &spec.JobDecision{
+		JobID:     job.ID,
+		BatchID:   job.BatchID,
+		BatchName: job.BatchName,
+		Status:    "deferred",
+		Reason:    "rate_limit_exceeded",
+		Timestamp: time.Now(),
+		Job:       job,
+	}
+	_ = ac.rememberDecision(ctx, job, decision)
+
+	return decision
+}
+
+// WaitingTenantKey scopes the tenant to its owner, tenant IDs are only unique per owner
+func WaitingTenantKey(job spec.Job) string {
+	return store.OwnerKey(job.OwnerID) + ":" + job.TenantID
+}
+
+// ReevaluateDeferred runs the rate limits again for a job claimed from the wait queue.
+// It returns the accepted or expired decision once the job leaves the queue,
+// or nil if the job is still waiting for capacity or was cancelled meanwhile.
+func (ac *AdmissionController) ReevaluateDeferred(
+	ctx context.Context,
+	waiting store.WaitingJob,
+) (*spec.JobDecision, error) {
+	var entry deferredEntry
+	if err := json.Unmarshal(waiting.Entry, &entry); err != nil {
+		_, _ = ac.Waiting.Release(ctx, waiting)
+		return nil, fmt.Errorf("corrupt deferred entry for job %s: %w", waiting.JobID, err)
+	}
+
+	job, err := spec.DecodeJob(entry.Job)
+	if err != nil {
+		_, _ = ac.Waiting.Release(ctx, waiting)
+		return nil, fmt.Errorf("corrupt deferred job %s: %w", waiting.JobID, err)
+	}
+
+	// The policy snapshot taken at submission still governs the job
+	jobPolicy, err := policy.ParseConfig(job.Config)
+	if err != nil {
+		_, _ = ac.Waiting.Release(ctx, waiting)
+		_ = ac.Store.ClearIdempotency(ctx, idempotencyKey(job))
+		return ac.Reject(job, "invalid_config", err)
+	}
+
+	if !time.Now().Before(entry.ExpiresAt) {
+		removed, err := ac.Waiting.Release(ctx, waiting)
+		if err != nil || !removed {
+			// Cancelled or released elsewhere since it was claimed
+			return nil, err
+		}
+		_ = ac.Store.ClearIdempotency(ctx, idempotencyKey(job))
+
+		if job.Deadline != nil && !time.Now().Before(*job.Deadline) {
+			return ac.Expire(job, "deadline_exceeded"), nil
+		}
+		return ac.Expire(job, "max_wait_exceeded"), nil
+	}
+
+	tempAC := ac.withPolicy(jobPolicy)
+
+	var reqs []store.RateLimitReq
+	reqs = append(reqs, tempAC.getGlobalLimitParameters(job))
+	reqs = append(reqs, tempAC.getTenanatQuotaParams(job))
+	reqs = append(reqs, tempAC.getDependencyParams(job)...)
+
+	allowed, err := ac.Store.AllowRequestAtomic(ctx, reqs)
+	if err != nil {
+		// Left claimed, the queue makes it visible again after the claim expires
+		return nil, err
+	}
+
+	if allowed {
+		removed, err := ac.Waiting.Release(ctx, waiting)
+		if err != nil {
+			// Still queued, give the tokens back so the next evaluation does not pay twice
+			return nil, errors.Join(err, ac.Store.RefundTokens(ctx, reqs))
+		}
+		if !removed {
+			// Cancelled or released elsewhere since it was claimed, the tokens are not ours
+			return nil, ac.Store.RefundTokens(ctx, reqs)
+		}
+
+		decision := tempAC.dispatch(ctx, job, ac.Accept(job))
+		if decision.Status == "accepted" {
+			_ = ac.rememberDecision(ctx, job, decision)
+		}
+		return decision, nil
+	}
+
+	// Still no capacity, stays at the head of its tenant queue
+	entry.Attempts++
+
+	raw, err := json.Marshal(entry)
+	if err != nil {
+		return nil, err
+	}
+
+	waiting.Entry = raw
+	return nil, ac.Waiting.Requeue(ctx, waiting)
+}
@@ -0,0 +1,96 @@
+package admission
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/satyamraj1643/janus/internal/store"
+	"github.com/satyamraj1643/janus/spec"
+)
+
+// deferOne accepts a job to use up a quota of one job per minute and returns a second one, deferred
+func deferOne(t *testing.T, ac *AdmissionController) spec.Job {
+	t.Helper()
+	ctx := context.Background()
+	cfg := testConfig(t, 1, func(cfg map[string]any) {
+		cfg["default_job_policy"].(map[string]any)["defer"] = map[string]any{"max_wait_ms": 60000}
+	})
+
+	if d, err := ac.Check(ctx, testJob("owner-a", "job-1", cfg)); err != nil || d.Status != "accepted" {
+		t.Fatalf("first job: %+v, %v", d, err)
+	}
+
+	job := testJob("owner-a", "job-2", cfg)
+	d, err := ac.Check(ctx, job)
+	if err != nil || d.Status != "deferred" || d.Reason != "rate_limit_exceeded" {
+		t.Fatalf("second job: %+v, %v, want deferred", d, err)
+	}
+	return job
+}
+
+func claim(t *testing.T, q store.WaitQueue, job spec.Job) store.WaitingJob {
+	t.Helper()
+	claimed, err := q.ClaimNext(context.Background(), WaitingTenantKey(job), time.Minute)
+	if err != nil || claimed == nil || claimed.JobID != job.ID {
+		t.Fatalf("claimed %+v, %v, want %s", claimed, err, job.ID)
+	}
+	return *claimed
+}
+
+func TestDeferredJobIsAdmittedOnceCapacityFrees(t *testing.T) {
+	ctx := context.Background()
+	ac, s, _ := newTestController(t)
+	ac.Waiting = s
+
+	job := deferOne(t, ac)
+
+	decision, err := ac.ReevaluateDeferred(ctx, claim(t, s, job))
+	if err != nil || decision != nil {
+		t.Fatalf("without capacity got %+v, %v, want it left waiting", decision, err)
+	}
+
+	// Requeued without its claim, so it can be claimed again right away
+	waiting := claim(t, s, job)
+
+	if err := s.RefundTokens(ctx, quotaRequests(t, job)); err != nil {
+		t.Fatal(err)
+	}
+
+	decision, err = ac.ReevaluateDeferred(ctx, waiting)
+	if err != nil || decision == nil || decision.Status != "accepted" {
+		t.Fatalf("with capacity got %+v, %v, want accepted", decision, err)
+	}
+	if left, _ := s.GetWaiting(ctx, job.OwnerID, job.ID); left != nil {
+		t.Fatal("admitted job is still waiting")
+	}
+}
+
+func TestDeferredJobReleasedElsewhereRefundsTokens(t *testing.T) {
+	ctx := context.Background()
+	ac, s, _ := newTestController(t)
+	ac.Waiting = s
+
+	job := deferOne(t, ac)
+	waiting := claim(t, s, job)
+
+	reqs := quotaRequests(t, job)
+	if err := s.RefundTokens(ctx, reqs); err != nil {
+		t.Fatal(err)
+	}
+
+	// Another instance handled it after this one claimed it
+	if removed, err := s.Release(ctx, waiting); err != nil || !removed {
+		t.Fatalf("release: %v, %v", removed, err)
+	}
+
+	decision, err := ac.ReevaluateDeferred(ctx, waiting)
+	if err != nil || decision != nil {
+		t.Fatalf("got %+v, %v, want nothing decided", decision, err)
+	}
+
+	// The token it took was given back
+	if allowed, err := s.AllowRequestAtomic(ctx, reqs); err != nil || !allowed {
+		t.Fatalf("tokens not refunded: %v, %v", allowed, err)
+	}
+}
@@ -0,0 +1,103 @@
+package admission
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/satyamraj1643/janus/internal/store"
+	"github.com/satyamraj1643/janus/internal/tracing"
+	"github.com/satyamraj1643/janus/permit"
+	"github.com/satyamraj1643/janus/spec"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// dispatch queues the accepted job for workers to lease when the policy asks for it,
+// otherwise signs its permit. If either fails the job is rejected with store_error and
+// its tokens are given back, so the producer retries instead of believing the job will run.
+// Workers get a permit per lease instead, see lease.Manager.
+// A job that is not dispatched is remembered for the permit TTL so it can be cancelled.
+func (ac *AdmissionController) dispatch(
+	ctx context.Context,
+	job spec.Job,
+	decision *spec.JobDecision,
+) *spec.JobDecision {
+	ctx, span := tracing.Start(ctx, "dispatch", attribute.String("janus.job_id", job.ID))
+	defer span.End()
+
+	if ac.Ready == nil || !ac.Policy.DefaultJobPolicy.Execution.Dispatch {
+		if err := ac.signPermit(&job, decision); err != nil {
+			return ac.rejectUndispatched(ctx, job, err)
+		}
+		ac.rememberAdmitted(ctx, job)
+		return decision
+	}
+
+	encoded, err := spec.EncodeJob(job)
+	if err == nil {
+		err = ac.Ready.Enqueue(ctx, store.ReadyJob{
+			JobID:        job.ID,
+			OwnerID:      job.OwnerID,
+			TenantID:     job.TenantID,
+			Dependencies: slices.Sorted(maps.Keys(job.Dependencies)),
+			MaxAttempts:  ac.Policy.DefaultJobPolicy.Retry.Attempts(),
+			Job:          encoded,
+			AvailableAt:  time.Now(),
+		})
+	}
+
+	if err != nil {
+		return ac.rejectUndispatched(ctx, job, err)
+	}
+
+	decision.Dispatched = true
+	return decision
+}
+
+// rejectUndispatched undoes the admission of a job dispatch could not hand out
+func (ac *AdmissionController) rejectUndispatched(ctx context.Context, job spec.Job, err error) *spec.JobDecision {
+	_ = ac.refund(ctx, job)
+	_ = ac.Store.ClearIdempotency(ctx, idempotencyKey(job))
+	rejected, _ := ac.Reject(job, "store_error", err)
+	return rejected
+}
+
+// rememberAdmitted keeps an accepted job that was not dispatched for Cancel.
+// Best effort, a job that could not be kept is only no longer cancellable.
+func (ac *AdmissionController) rememberAdmitted(ctx context.Context, job spec.Job) {
+	if ac.Admitted == nil {
+		return
+	}
+
+	encoded, err := spec.EncodeJob(job)
+	if err != nil {
+		return
+	}
+	_ = ac.Admitted.RememberAdmitted(ctx, job.OwnerID, job.ID, encoded, ac.Policy.DefaultJobPolicy.Execution.PermitTTL())
+}
+
+// signPermit attaches a permit for the job's admitted dependencies to the job and decision
+func (ac *AdmissionController) signPermit(job *spec.Job, decision *spec.JobDecision) error {
+	if ac.Permits == nil {
+		return nil
+	}
+
+	now := time.Now()
+	token, err := ac.Permits.Sign(permit.Claims{
+		JobID:        job.ID,
+		OwnerID:      job.OwnerID,
+		TenantID:     job.TenantID,
+		Dependencies: job.Dependencies,
+		IssuedAt:     now.Unix(),
+		ExpiresAt:    now.Add(ac.Policy.DefaultJobPolicy.Execution.PermitTTL()).Unix(),
+	})
+	if err != nil {
+		return err
+	}
+
+	job.Permit = token
+	decision.Job.Permit = token
+	decision.Permit = token
+	return nil
+}
@@ -0,0 +1,38 @@
+package admission
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/satyamraj1643/janus/internal/store"
+)
+
+// failingReady refuses every job
+type failingReady struct {
+	store.ReadyQueue
+}
+
+func (failingReady) Enqueue(context.Context, store.ReadyJob) error {
+	return errors.New("ready queue unavailable")
+}
+
+func TestFailedDispatchRefundsTokens(t *testing.T) {
+	ctx := context.Background()
+	ac, _, _ := newTestController(t)
+	ac.Ready = failingReady{}
+	cfg := testConfig(t, 1, func(cfg map[string]any) {
+		cfg["default_job_policy"].(map[string]any)["execution"] = map[string]any{"dispatch": true}
+	})
+
+	d, _ := ac.Check(ctx, testJob("owner-a", "job-1", cfg))
+	if d.Status != "rejected" || d.Reason != "store_error" {
+		t.Fatalf("got %+v, want rejected with store_error", d)
+	}
+
+	// The quota of one job was given back and the job can be retried
+	ac.Ready = nil
+	if d, err := ac.Check(ctx, testJob("owner-a", "job-1", cfg)); err != nil || d.Status != "accepted" || d.Replayed {
+		t.Fatalf("retried: %+v, %v, want accepted", d, err)
+	}
+}
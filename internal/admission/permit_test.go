@@ -0,0 +1,37 @@
+package admission
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/satyamraj1643/janus/permit"
+)
+
+func TestAcceptedJobCarriesPermit(t *testing.T) {
+	ctx := context.Background()
+	ac, _, _ := newTestController(t)
+
+	signer, err := permit.NewSigner(bytes.Repeat([]byte{1}, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ac.Permits = signer
+
+	cfg := testConfig(t, 10, func(cfg map[string]any) {
+		cfg["default_job_policy"].(map[string]any)["execution"] = map[string]any{"permit_ttl_ms": 60000}
+	})
+	d, err := ac.Check(ctx, testJob("owner-a", "job-1", cfg))
+	if err != nil || d.Status != "accepted" || d.Permit == "" || d.Job.Permit != d.Permit {
+		t.Fatalf("got %+v, %v, want a permit on the decision and its job", d, err)
+	}
+
+	claims, err := permit.Verify(signer.PublicKey(), d.Permit, time.Now())
+	if err != nil || claims.JobID != "job-1" || claims.OwnerID != "owner-a" || claims.LeaseID != "" {
+		t.Fatalf("claims %+v, %v", claims, err)
+	}
+	if ttl := time.Unix(claims.ExpiresAt, 0).Sub(time.Unix(claims.IssuedAt, 0)); ttl != time.Minute {
+		t.Fatalf("permit valid for %v, want the policy's permit_ttl_ms", ttl)
+	}
+}
@@ -2,30 +2,132 @@ package admission
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"time"
 
 	"github.com/satyamraj1643/janus/internal/store"
+	"github.com/satyamraj1643/janus/internal/tracing"
 	"github.com/satyamraj1643/janus/spec"
+	"go.opentelemetry.io/otel/attribute"
 )
 
+var errQuotaExceeded = errors.New("quota exceeded")
+
+// rule runs one admission rule in its own span. A rule that fails
+// rejects the job, which is not an error of the span.
+func rule(ctx context.Context, name string, check func(context.Context) error) error {
+	ctx, span := tracing.Start(ctx, "rule."+name)
+	defer span.End()
+
+	err := check(ctx)
+	span.SetAttributes(attribute.Bool("janus.rule.passed", err == nil))
+	return err
+}
+
 // checkIdempotency verifies if the job has already been admitted duirng that window or not
 
 func (ac *AdmissionController) checkIdempotency(ctx context.Context, job spec.Job) error {
 	window := time.Duration(ac.Policy.DefaultJobPolicy.IdempotencyWindowMs) * time.Millisecond
 
-	exists, err := ac.Store.CheckAndMarkAdmitted(ctx, job.ID, window)
+	key := idempotencyKey(job)
+
+	exists, err := ac.Store.CheckAndMarkAdmitted(ctx, key, window)
 	if err != nil {
 		return err
 	}
 
 	if exists {
-		return fmt.Errorf("job %s already submitted within window %v", job.ID, window)
+		return fmt.Errorf("job %s already submitted within window %v", clientKey(job), window)
 	}
 
 	return nil
 }
 
+// idempotencyKey is the store key of the job's submissions. Owners pick their keys,
+// so it is scoped to the owner: another owner's key must never replay this owner's decision.
+func idempotencyKey(job spec.Job) string {
+	return store.OwnerKey(job.OwnerID) + ":" + clientKey(job)
+}
+
+// clientKey prefers the Idempotency-Key header over the job_id
+func clientKey(job spec.Job) string {
+	if job.IdempotencyKey != "" {
+		return job.IdempotencyKey
+	}
+	return job.ID
+}
+
+// idempotencyRecord is the compact form of a decision kept under the idempotency key
+type idempotencyRecord struct {
+	JobID      string    `json:"job_id"`
+	BatchID    string    `json:"batch_id"`
+	BatchName  string    `json:"batch_name"`
+	Status     string    `json:"status"`
+	Reason     string    `json:"reason,omitempty"`
+	Timestamp  time.Time `json:"timestamp"`
+	Permit     string    `json:"permit,omitempty"`
+	Dispatched bool      `json:"dispatched,omitempty"`
+}
+
+func encodeRecord(decision *spec.JobDecision) ([]byte, error) {
+	return json.Marshal(idempotencyRecord{
+		JobID:      decision.JobID,
+		BatchID:    decision.BatchID,
+		BatchName:  decision.BatchName,
+		Status:     decision.Status,
+		Reason:     decision.Reason,
+		Timestamp:  decision.Timestamp,
+		Permit:     decision.Permit,
+		Dispatched: decision.Dispatched,
+	})
+}
+
+// rememberDecision stores the decision under the job's idempotency key so retries can replay it
+func (ac *AdmissionController) rememberDecision(ctx context.Context, job spec.Job, decision *spec.JobDecision) error {
+	record, err := encodeRecord(decision)
+	if err != nil {
+		return err
+	}
+
+	return ac.Store.SaveIdempotencyRecord(ctx, idempotencyKey(job), record)
+}
+
+// replayDecision returns the original decision for a duplicate submission.
+// Returns nil if the first submission is still being evaluated or the record is gone.
+func (ac *AdmissionController) replayDecision(ctx context.Context, job spec.Job) *spec.JobDecision {
+	raw, err := ac.Store.GetIdempotencyRecord(ctx, idempotencyKey(job))
+	if err != nil {
+		return nil
+	}
+	return decodeRecord(raw, job)
+}
+
+func decodeRecord(raw []byte, job spec.Job) *spec.JobDecision {
+	if raw == nil {
+		return nil
+	}
+
+	var record idempotencyRecord
+	if err := json.Unmarshal(raw, &record); err != nil {
+		return nil
+	}
+
+	return &spec.JobDecision{
+		JobID:      record.JobID,
+		BatchID:    record.BatchID,
+		BatchName:  record.BatchName,
+		Status:     record.Status,
+		Reason:     record.Reason,
+		Timestamp:  record.Timestamp,
+		Permit:     record.Permit,
+		Dispatched: record.Dispatched,
+		Replayed:   true,
+		Job:        job,
+	}
+}
+
 // check external API dependecies limit to determine of per window rate limits are crossed or not
 
 func (ac *AdmissionController) checkDependecyLimit(ctx context.Context, job spec.Job) error {
@@ -119,8 +221,13 @@ func (ac *AdmissionController) checkPriority(ctx context.Context, job spec.Job)
 
 }
 
+// quotaKey scopes a bucket to the job's owner, see StateStore.ResetQuotas
+func quotaKey(job spec.Job, name string) string {
+	return store.OwnerKey(job.OwnerID) + ":" + name
+}
+
 // 1. Prepare global limit
-func (ac *AdmissionController) getGlobalLimitParameters() store.RateLimitReq {
+func (ac *AdmissionController) getGlobalLimitParameters(job spec.Job) store.RateLimitReq {
 	limit := ac.Policy.GlobalExecutionLimit.MaxJobs
 	windowMs := ac.Policy.GlobalExecutionLimit.WindowMs
 	if windowMs == 0 {
@@ -129,7 +236,7 @@ func (ac *AdmissionController) getGlobalLimitParameters() store.RateLimitReq {
 	refillRate := float64(limit) / (float64(windowMs) / 1000.0)
 
 	return store.RateLimitReq{
-		Key:         "global_request_quota",
+		Key:         quotaKey(job, "global_request_quota"),
 		Capacity:    limit,
 		RefillRate:  refillRate,
 		Cost:        1,
@@ -147,7 +254,7 @@ func (ac *AdmissionController) getTenanatQuotaParams(job spec.Job) store.RateLim
 	refillRate := float64(limit) / (float64(windowMs) / 1000.0)
 
 	return store.RateLimitReq{
-		Key:         fmt.Sprintf("tenant:%s", job.TenantID),
+		Key:         quotaKey(job, "tenant:"+job.TenantID),
 		Capacity:    limit,
 		RefillRate:  refillRate,
 		Cost:        1,
@@ -170,7 +277,7 @@ func (ac *AdmissionController) getDependencyParams(job spec.Job) []store.RateLim
 			refillRate := float64(limit) / (float64(windowMs) / 1000.0)
 
 			reqs = append(reqs, store.RateLimitReq{
-				Key:         fmt.Sprintf("dependency:%s", depName),
+				Key:         quotaKey(job, "dependency:"+depName),
 				Capacity:    limit,
 				RefillRate:  refillRate,
 				Cost:        cost,
@@ -182,6 +289,20 @@ func (ac *AdmissionController) getDependencyParams(job spec.Job) []store.RateLim
 	return reqs
 }
 
+// retryAfter estimates how long until every bucket in reqs can cover its cost again.
+// Buckets are assumed empty, so this is an upper bound for a single rejection.
+func retryAfter(reqs []store.RateLimitReq) time.Duration {
+	var longest float64
+	for _, req := range reqs {
+		wait := req.MinInterval
+		if req.RefillRate > 0 {
+			wait = max(wait, float64(req.Cost)/req.RefillRate)
+		}
+		longest = max(longest, wait)
+	}
+	return time.Duration(longest * float64(time.Second))
+}
+
 // Not relevent for any process for janus or jobs, but for standalone key wise burst smoothing.
 func (ac *AdmissionController) CheckBurstSmoothing(ctx context.Context, key string, minIntervalSeconds float64) error {
 	allowed, err := ac.Store.AllowBurstSmoothing(ctx, key, minIntervalSeconds)
@@ -0,0 +1,202 @@
+package admission
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/satyamraj1643/janus/internal/policy"
+	"github.com/satyamraj1643/janus/internal/store"
+	"github.com/satyamraj1643/janus/spec"
+)
+
+// MaxScheduleAhead bounds how far in the future not_before may be
+const MaxScheduleAhead = 7 * 24 * time.Hour
+
+// scheduledEntry is what the schedule queue keeps for a scheduled job
+type scheduledEntry struct {
+	Job         json.RawMessage `json:"job"` // spec.EncodeJob
+	ScheduledAt time.Time       `json:"scheduled_at"`
+}
+
+// checkSchedule validates the job's not_before / deadline window.
+// It returns the rejection reason, "deadline_exceeded" if the deadline has already passed.
+func checkSchedule(job spec.Job, now time.Time) (string, error) {
+	if job.NotBefore != nil && job.NotBefore.After(now.Add(MaxScheduleAhead)) {
+		return "invalid_schedule", fmt.Errorf("not_before cannot be more than %v ahead", MaxScheduleAhead)
+	}
+	if job.Deadline != nil && job.NotBefore != nil && !job.Deadline.After(*job.NotBefore) {
+		return "invalid_schedule", fmt.Errorf("deadline must be after not_before")
+	}
+	if job.Deadline != nil && !now.Before(*job.Deadline) {
+		return "deadline_exceeded", fmt.Errorf("deadline %v has passed", job.Deadline)
+	}
+	return "", nil
+}
+
+// isScheduled reports whether the job must wait for its not_before
+func isScheduled(job spec.Job, now time.Time) bool {
+	return job.NotBefore != nil && job.NotBefore.After(now)
+}
+
+// rejectSchedule turns a checkSchedule failure into a decision
+func (ac *AdmissionController) rejectSchedule(job spec.Job, reason string, err error) *spec.JobDecision {
+	if reason == "deadline_exceeded" {
+		return ac.Expire(job, reason)
+	}
+	d, _ := ac.Reject(job, reason, err)
+	return d
+}
+
+// Expire is the decision for a job that waited past its max wait or deadline
+func (ac *AdmissionController) Expire(job spec.Job, reason string) *spec.JobDecision {
+	decision, _ := ac.Reject(job, reason, nil)
+	decision.Status = "expired"
+	return decision
+}
+
+// scheduleJob holds a job until its not_before. The idempotency key must already be held,
+// it is released again if the job cannot be stored.
+func (ac *AdmissionController) scheduleJob(ctx context.Context, job spec.Job) *spec.JobDecision {
+	err := fmt.Errorf("scheduling is not enabled")
+
+	if ac.Scheduled != nil {
+		var encoded, entry []byte
+		encoded, err = spec.EncodeJob(job)
+		if err == nil {
+			entry, err = json.Marshal(scheduledEntry{Job: encoded, ScheduledAt: time.Now()})
+		}
+		if err == nil {
+			err = ac.Scheduled.Schedule(ctx, store.ScheduledJob{
+				JobID:   job.ID,
+				OwnerID: job.OwnerID,
+				Entry:   entry,
+				RunAt:   *job.NotBefore,
+			})
+		}
+	}
+
+	if err != nil {
+		_ = ac.Store.ClearIdempotency(ctx, idempotencyKey(job))
+		d, _ := ac.Reject(job, "store_error", err)
+		return d
+	}
+
+	decision := &spec.JobDecision{
+		JobID:     job.ID,
+		BatchID:   job.BatchID,
+		BatchName: job.BatchName,
+		Status:    "scheduled",
+		Timestamp: time.Now(),
+		Job:       job,
+	}
+	_ = ac.rememberDecision(ctx, job, decision)
+
+	return decision
+}
+
+// ReleaseScheduled runs admission for a scheduled job that came due.
+// A job that does not fit is deferred when its policy or deadline allows waiting,
+// otherwise it is rejected. Returns nil if the store could not be reached,
+// the job then comes due again once its claim runs out, or if it was cancelled meanwhile.
+func (ac *AdmissionController) ReleaseScheduled(
+	ctx context.Context,
+	scheduled store.ScheduledJob,
+) (*spec.JobDecision, error) {
+	var entry scheduledEntry
+	if err := json.Unmarshal(scheduled.Entry, &entry); err != nil {
+		_, _ = ac.Scheduled.Unschedule(ctx, scheduled.OwnerID, scheduled.JobID)
+		return nil, fmt.Errorf("corrupt scheduled entry for job %s: %w", scheduled.JobID, err)
+	}
+
+	job, err := spec.DecodeJob(entry.Job)
+	if err != nil {
+		_, _ = ac.Scheduled.Unschedule(ctx, scheduled.OwnerID, scheduled.JobID)
+		return nil, fmt.Errorf("corrupt scheduled job %s: %w", scheduled.JobID, err)
+	}
+
+	// The policy snapshot taken at submission still governs the job
+	jobPolicy, err := policy.ParseConfig(job.Config)
+	if err != nil {
+		_, _ = ac.Scheduled.Unschedule(ctx, scheduled.OwnerID, scheduled.JobID)
+		_ = ac.Store.ClearIdempotency(ctx, idempotencyKey(job))
+		return ac.Reject(job, "invalid_config", err)
+	}
+
+	if job.Deadline != nil && !time.Now().Before(*job.Deadline) {
+		removed, err := ac.Scheduled.Unschedule(ctx, scheduled.OwnerID, scheduled.JobID)
+		if err != nil || !removed {
+			// Cancelled or released elsewhere since it was claimed
+			return nil, err
+		}
+		_ = ac.Store.ClearIdempotency(ctx, idempotencyKey(job))
+		return ac.Expire(job, "deadline_exceeded"), nil
+	}
+
+	tempAC := ac.withPolicy(jobPolicy)
+
+	var reqs []store.RateLimitReq
+	reqs = append(reqs, tempAC.getGlobalLimitParameters(job))
+	reqs = append(reqs, tempAC.getTenanatQuotaParams(job))
+	reqs = append(reqs, tempAC.getDependencyParams(job)...)
+
+	allowed, err := ac.Store.AllowRequestAtomic(ctx, reqs)
+	if err != nil {
+		return nil, err
+	}
+
+	var decision *spec.JobDecision
+	if allowed {
+		decision = tempAC.dispatch(ctx, job, ac.Accept(job))
+		if decision.Status == "accepted" {
+			_ = ac.rememberDecision(ctx, job, decision)
+		}
+	} else if deferred := tempAC.deferJob(ctx, job, true); deferred != nil {
+		decision = deferred
+	} else {
+		_ = ac.Store.ClearIdempotency(ctx, idempotencyKey(job))
+		decision, _ = ac.Reject(job, "rate_limit_exceeded", errQuotaExceeded)
+		decision.RetryAfterMs = retryAfter(reqs).Milliseconds()
+	}
+
+	// After the outcome is stored, a crash in between only repeats the release
+	removed, err := ac.Scheduled.Unschedule(ctx, scheduled.OwnerID, scheduled.JobID)
+	if err != nil {
+		return decision, err
+	}
+	if !removed {
+		// Cancelled after the claim ran out, take back the outcome just stored
+		return nil, ac.undoRelease(ctx, job, decision)
+	}
+
+	return decision, nil
+}
+
+// undoRelease withdraws what releasing a job stored for it, for a job that was
+// cancelled meanwhile. Tokens are given back unless a worker already got the job.
+func (ac *AdmissionController) undoRelease(ctx context.Context, job spec.Job, decision *spec.JobDecision) error {
+	switch decision.Status {
+	case "accepted":
+		if decision.Dispatched {
+			l, err := ac.Ready.RemoveReady(ctx, job.OwnerID, job.ID)
+			if err != nil || l == nil || l.Attempt > 0 {
+				return err
+			}
+		} else if ac.Admitted != nil {
+			raw, err := ac.Admitted.TakeAdmitted(ctx, job.OwnerID, job.ID)
+			if err != nil || raw == nil {
+				return err
+			}
+		}
+		return ac.refund(ctx, job)
+	case "deferred":
+		waiting, err := ac.Waiting.GetWaiting(ctx, job.OwnerID, job.ID)
+		if err != nil || waiting == nil {
+			return err
+		}
+		_, err = ac.Waiting.Release(ctx, *waiting)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package admission
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/satyamraj1643/janus/spec"
+)
+
+func TestCheckSchedule(t *testing.T) {
+	now := time.Now()
+	at := func(d time.Duration) *time.Time {
+		t := now.Add(d)
+		return &t
+	}
+
+	for _, tc := range []struct {
+		name      string
+		notBefore *time.Time
+		deadline  *time.Time
+		want      string
+	}{
+		{"no schedule", nil, nil, ""},
+		{"within a week", at(time.Hour), at(2 * time.Hour), ""},
+		{"too far ahead", at(MaxScheduleAhead + time.Hour), nil, "invalid_schedule"},
+		{"deadline before not_before", at(2 * time.Hour), at(time.Hour), "invalid_schedule"},
+		{"deadline passed", nil, at(-time.Second), "deadline_exceeded"},
+	} {
+		reason, err := checkSchedule(spec.Job{NotBefore: tc.notBefore, Deadline: tc.deadline}, now)
+		if reason != tc.want || (err != nil) != (tc.want != "") {
+			t.Errorf("%s: got %q, %v, want %q", tc.name, reason, err, tc.want)
+		}
+	}
+}
+
+func TestScheduledJobTakesQuotaOnRelease(t *testing.T) {
+	ctx := context.Background()
+	ac, s, _ := newTestController(t)
+	ac.Scheduled = s
+	cfg := testConfig(t, 1, nil)
+
+	job := testJob("owner-a", "job-1", cfg)
+	runAt := time.Now().Add(50 * time.Millisecond)
+	job.NotBefore = &runAt
+
+	d, err := ac.Check(ctx, job)
+	if err != nil || d.Status != "scheduled" {
+		t.Fatalf("got %+v, %v, want scheduled", d, err)
+	}
+
+	// Scheduling took no quota, another job still fits
+	if d, err := ac.Check(ctx, testJob("owner-a", "job-2", cfg)); err != nil || d.Status != "accepted" {
+		t.Fatalf("job submitted meanwhile: %+v, %v", d, err)
+	}
+
+	if due, _ := s.ClaimDue(ctx, time.Now(), 10, time.Minute); len(due) != 0 {
+		t.Fatalf("claimed %+v before not_before", due)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	due, err := s.ClaimDue(ctx, time.Now(), 10, time.Minute)
+	if err != nil || len(due) != 1 {
+		t.Fatalf("claimed %+v, %v, want job-1", due, err)
+	}
+
+	d, err = ac.ReleaseScheduled(ctx, due[0])
+	if err != nil || d.Status != "rejected" || d.Reason != "rate_limit_exceeded" {
+		t.Fatalf("released %+v, %v, want rejected by the job admitted meanwhile", d, err)
+	}
+	if left, _ := s.GetScheduled(ctx, "owner-a", "job-1"); left != nil {
+		t.Fatal("released job is still scheduled")
+	}
+}
@@ -0,0 +1,158 @@
+// Package audit writes one structured record per job decision. It is kept apart from
+// the operational log so it can be sampled, shipped and retained on its own terms.
+package audit
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"log/slog"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/satyamraj1643/janus/internal/admission"
+	"github.com/satyamraj1643/janus/spec"
+)
+
+const redacted = "[REDACTED]"
+
+type Config struct {
+	Output        io.Writer // nil disables the audit log
+	SampleRate    float64   // fraction of jobs audited, 0 to 1
+	PayloadFields []string  // payload fields logged as is, every other value is redacted
+}
+
+type auditor struct {
+	logger    *slog.Logger
+	rate      float64
+	keepField map[string]bool
+}
+
+var current atomic.Pointer[auditor]
+
+// Configure replaces the audit log, decisions are not audited until it is called
+func Configure(cfg Config) {
+	if cfg.Output == nil {
+		current.Store(nil)
+		return
+	}
+
+	keep := make(map[string]bool, len(cfg.PayloadFields))
+	for _, f := range cfg.PayloadFields {
+		keep[f] = true
+	}
+
+	current.Store(&auditor{
+		logger:    slog.New(slog.NewJSONHandler(cfg.Output, nil)),
+		rate:      cfg.SampleRate,
+		keepField: keep,
+	})
+}
+
+// ConfigFromEnv reads AUDIT_LOG (stdout, the default, stderr, off or a file path to append to),
+// AUDIT_SAMPLE_RATE (default 1) and AUDIT_PAYLOAD_FIELDS (comma separated, default none)
+func ConfigFromEnv() (Config, error) {
+	cfg := Config{SampleRate: 1}
+
+	switch out := os.Getenv("AUDIT_LOG"); out {
+	case "", "stdout":
+		cfg.Output = os.Stdout
+	case "stderr":
+		cfg.Output = os.Stderr
+	case "off":
+	default:
+		f, err := os.OpenFile(out, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o640)
+		if err != nil {
+			return cfg, err
+		}
+		cfg.Output = f
+	}
+
+	if v := os.Getenv("AUDIT_SAMPLE_RATE"); v != "" {
+		rate, err := strconv.ParseFloat(v, 64)
+		if err != nil || rate < 0 || rate > 1 {
+			return cfg, fmt.Errorf("AUDIT_SAMPLE_RATE must be between 0 and 1, got %q", v)
+		}
+		cfg.SampleRate = rate
+	}
+
+	if v := os.Getenv("AUDIT_PAYLOAD_FIELDS"); v != "" {
+		for _, f := range strings.Split(v, ",") {
+			if f = strings.TrimSpace(f); f != "" {
+				cfg.PayloadFields = append(cfg.PayloadFields, f)
+			}
+		}
+	}
+
+	return cfg, nil
+}
+
+type startKey struct{}
+
+// WithStart marks when the request began, its decisions report their latency from then
+func WithStart(ctx context.Context, start time.Time) context.Context {
+	return context.WithValue(ctx, startKey{}, start)
+}
+
+// Decision audits the decision if its job is sampled. Sampling is by job ID,
+// so every decision of a sampled job is kept.
+func Decision(ctx context.Context, decision *spec.JobDecision) {
+	a := current.Load()
+	if a == nil || !a.sampled(decision.JobID) {
+		return
+	}
+
+	attrs := []slog.Attr{
+		slog.String("job_id", decision.JobID),
+		slog.String("owner", decision.Job.OwnerID),
+		slog.String("tenant", decision.Job.TenantID),
+		slog.String("batch_id", decision.BatchID),
+		slog.String("status", decision.Status),
+		slog.String("reason", decision.Reason),
+		slog.String("rule", admission.Rule(decision.Reason)),
+		slog.String("actor", decision.Actor),
+		slog.Int("attempt", decision.Attempt),
+		slog.Int("priority", decision.Job.Priority),
+		slog.Any("dependencies", decision.Job.Dependencies),
+		slog.Any("payload", a.redact(decision.Job.Payload)),
+	}
+	if start, ok := ctx.Value(startKey{}).(time.Time); ok {
+		attrs = append(attrs, slog.Float64("latency_ms", float64(time.Since(start).Microseconds())/1000))
+	}
+	if decision.TraceID != "" {
+		attrs = append(attrs, slog.String("trace_id", decision.TraceID))
+	}
+
+	a.logger.LogAttrs(ctx, slog.LevelInfo, "decision", attrs...)
+}
+
+func (a *auditor) sampled(jobID string) bool {
+	if a.rate >= 1 {
+		return true
+	}
+	h := fnv.New32a()
+	h.Write([]byte(jobID))
+	return float64(h.Sum32()) < a.rate*math.MaxUint32
+}
+
+// redact keeps the payload's keys, only allowed fields keep their values
+func (a *auditor) redact(payload map[string]any) map[string]any {
+	if len(payload) == 0 {
+		return nil
+	}
+
+	out := make(map[string]any, len(payload))
+	for k, v := range payload {
+		if a.keepField[k] {
+			out[k] = v
+		} else {
+			out[k] = redacted
+		}
+	}
+	return out
+}
@@ -0,0 +1,105 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/satyamraj1643/janus/spec"
+)
+
+// configureTest sends the audit log to a buffer until the test ends
+func configureTest(t *testing.T, cfg Config) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	cfg.Output = &buf
+	Configure(cfg)
+	t.Cleanup(func() { Configure(Config{}) })
+	return &buf
+}
+
+func TestDecisionRedactsPayload(t *testing.T) {
+	buf := configureTest(t, Config{SampleRate: 1, PayloadFields: []string{"kind"}})
+
+	Decision(context.Background(), &spec.JobDecision{
+		JobID:  "job-1",
+		Status: "rejected",
+		Reason: "rate_limit_exceeded",
+		Job: spec.Job{
+			OwnerID: "owner-a",
+			Payload: map[string]any{"kind": "invoice", "email": "someone@example.com"},
+		},
+	})
+
+	var record struct {
+		JobID   string         `json:"job_id"`
+		Owner   string         `json:"owner"`
+		Rule    string         `json:"rule"`
+		Payload map[string]any `json:"payload"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("%v: %s", err, buf)
+	}
+	if record.JobID != "job-1" || record.Owner != "owner-a" || record.Rule != "rate_limit" {
+		t.Fatalf("record %+v", record)
+	}
+	if record.Payload["kind"] != "invoice" || record.Payload["email"] != redacted {
+		t.Fatalf("payload %v, want only kind kept", record.Payload)
+	}
+	if bytes.Contains(buf.Bytes(), []byte("someone@example.com")) {
+		t.Fatal("redacted value written to the audit log")
+	}
+}
+
+func TestSamplingIsPerJob(t *testing.T) {
+	buf := configureTest(t, Config{SampleRate: 0.5})
+
+	audited := 0
+	for i := range 200 {
+		decision := &spec.JobDecision{JobID: fmt.Sprintf("job-%d", i), Status: "accepted"}
+
+		before := buf.Len()
+		Decision(context.Background(), decision)
+		sampled := buf.Len() > before
+		if sampled {
+			audited++
+		}
+
+		// Every later decision of the job goes the same way
+		before = buf.Len()
+		decision.Status = "succeeded"
+		Decision(context.Background(), decision)
+		if (buf.Len() > before) != sampled {
+			t.Fatalf("%s sampled for one decision but not another", decision.JobID)
+		}
+	}
+
+	if audited == 0 || audited == 200 {
+		t.Fatalf("audited %d of 200 jobs at rate 0.5", audited)
+	}
+
+	Configure(Config{})
+	before := buf.Len()
+	Decision(context.Background(), &spec.JobDecision{JobID: "job-1"})
+	if buf.Len() != before {
+		t.Fatal("audited with the log disabled")
+	}
+}
+
+func TestConfigFromEnv(t *testing.T) {
+	t.Setenv("AUDIT_LOG", "off")
+	t.Setenv("AUDIT_SAMPLE_RATE", "0.25")
+	t.Setenv("AUDIT_PAYLOAD_FIELDS", " kind, ,region")
+
+	cfg, err := ConfigFromEnv()
+	if err != nil || cfg.Output != nil || cfg.SampleRate != 0.25 || len(cfg.PayloadFields) != 2 || cfg.PayloadFields[1] != "region" {
+		t.Fatalf("got %+v, %v", cfg, err)
+	}
+
+	t.Setenv("AUDIT_SAMPLE_RATE", "2")
+	if _, err := ConfigFromEnv(); err == nil {
+		t.Fatal("accepted a sample rate above 1")
+	}
+}
@@ -0,0 +1,245 @@
+package deadletter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/satyamraj1643/janus/internal/admission"
+	"github.com/satyamraj1643/janus/internal/store"
+	"github.com/satyamraj1643/janus/spec"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+const (
+	DefaultPageSize = 100
+	MaxPageSize     = 1000
+)
+
+// Attempt is one failed execution of a job
+type Attempt struct {
+	Attempt  int       `json:"attempt"`
+	Reason   string    `json:"reason"`
+	FailedAt time.Time `json:"failed_at"`
+}
+
+// Item is a dead-lettered job as shown to its owner
+type Item struct {
+	JobID            string     `json:"job_id"`
+	TenantID         string     `json:"tenant_id"`
+	BatchID          string     `json:"batch_id"`
+	Reason           string     `json:"reason"` // last failure
+	Quarantined      bool       `json:"quarantined"`
+	QuarantinedUntil *time.Time `json:"quarantined_until,omitempty"`
+	Attempts         []Attempt  `json:"attempts"`
+	DeadAt           time.Time  `json:"dead_at"`
+	Job              spec.Job   `json:"job"` // original submission, metadata kept for redrive
+}
+
+type Page struct {
+	Items      []Item `json:"items"`
+	Total      int64  `json:"total"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// RedriveResult is the outcome of sending one dead-lettered job back through admission
+type RedriveResult struct {
+	JobID  string `json:"job_id"`
+	Status string `json:"status"` // accepted | deferred | rejected | not_found | quarantined
+	Reason string `json:"reason,omitempty"`
+
+	Decision *spec.JobDecision `json:"-"` // nil unless admission ran
+}
+
+// entry is what the store keeps per item
+type entry struct {
+	Reason           string          `json:"reason"`
+	QuarantinedUntil time.Time       `json:"quarantined_until"`
+	Quarantined      bool            `json:"quarantined"`
+	Attempts         []Attempt       `json:"attempts"`
+	Job              json.RawMessage `json:"job"` // spec.EncodeJob
+}
+
+// Queue holds jobs that will not run again until their owner redrives them
+type Queue struct {
+	Store store.DeadLetterStore
+}
+
+func NewQueue(s store.DeadLetterStore) *Queue {
+	return &Queue{Store: s}
+}
+
+// Add dead-letters a job. A zero quarantinedUntil means the job ran out of attempts,
+// otherwise it was quarantined and cannot be redriven before then.
+func (q *Queue) Add(
+	ctx context.Context,
+	job spec.Job,
+	reason string,
+	attempts []Attempt,
+	quarantinedUntil time.Time,
+) error {
+	encoded, err := spec.EncodeJob(job)
+	if err != nil {
+		return err
+	}
+
+	raw, err := json.Marshal(entry{
+		Reason:           reason,
+		Quarantined:      !quarantinedUntil.IsZero(),
+		QuarantinedUntil: quarantinedUntil,
+		Attempts:         attempts,
+		Job:              encoded,
+	})
+	if err != nil {
+		return err
+	}
+
+	return q.Store.AddDeadLetter(ctx, store.DeadLetterItem{
+		JobID:   job.ID,
+		OwnerID: job.OwnerID,
+		DeadAt:  time.Now(),
+		Entry:   raw,
+	})
+}
+
+// List returns the owner's items, most recently dead first
+func (q *Queue) List(ctx context.Context, ownerID, cursor string, limit int) (*Page, error) {
+	offset := 0
+	if cursor != "" {
+		n, err := strconv.Atoi(cursor)
+		if err != nil || n < 0 {
+			return nil, ErrInvalidCursor
+		}
+		offset = n
+	}
+
+	if limit <= 0 {
+		limit = DefaultPageSize
+	}
+	limit = min(limit, MaxPageSize)
+
+	raw, total, err := q.Store.ListDeadLetters(ctx, ownerID, offset, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &Page{Items: make([]Item, 0, len(raw)), Total: total}
+	for _, r := range raw {
+		item, err := decodeItem(r)
+		if err != nil {
+			return nil, err
+		}
+		page.Items = append(page.Items, *item)
+	}
+
+	if int64(offset+limit) < total {
+		page.NextCursor = strconv.Itoa(offset + limit)
+	}
+
+	return page, nil
+}
+
+// Get returns nil if the job is not dead-lettered
+func (q *Queue) Get(ctx context.Context, ownerID, jobID string) (*Item, error) {
+	raw, err := q.Store.GetDeadLetter(ctx, ownerID, jobID)
+	if err != nil || raw == nil {
+		return nil, err
+	}
+	return decodeItem(*raw)
+}
+
+// Purge deletes the given jobs, or every job of the owner when jobIDs is empty
+func (q *Queue) Purge(ctx context.Context, ownerID string, jobIDs []string) (int, error) {
+	if len(jobIDs) == 0 {
+		return q.Store.PurgeDeadLetters(ctx, ownerID)
+	}
+	return q.Store.RemoveDeadLetters(ctx, ownerID, jobIDs)
+}
+
+// Redrive resubmits jobs through admission under the owner's active config.
+// Jobs that are accepted or deferred leave the dead-letter queue, rejected ones stay.
+func (q *Queue) Redrive(
+	ctx context.Context,
+	ac *admission.AdmissionController,
+	ownerID string,
+	jobIDs []string,
+	config json.RawMessage,
+	configID string,
+) ([]RedriveResult, error) {
+	results := make([]RedriveResult, 0, len(jobIDs))
+
+	for _, jobID := range jobIDs {
+		item, err := q.Get(ctx, ownerID, jobID)
+		if err != nil {
+			return results, err
+		}
+		if item == nil {
+			results = append(results, RedriveResult{JobID: jobID, Status: "not_found"})
+			continue
+		}
+		if item.QuarantinedUntil != nil && time.Now().Before(*item.QuarantinedUntil) {
+			results = append(results, RedriveResult{
+				JobID:  jobID,
+				Status: "quarantined",
+				Reason: fmt.Sprintf("quarantined until %s", item.QuarantinedUntil.Format(time.RFC3339)),
+			})
+			continue
+		}
+
+		job := item.Job
+		job.Config = config
+		job.GlobalConfigID = configID
+
+		decision, _ := ac.Redrive(ctx, job)
+
+		if decision.Status == "accepted" || decision.Status == "deferred" {
+			if _, err := q.Store.RemoveDeadLetters(ctx, ownerID, []string{jobID}); err != nil {
+				return results, err
+			}
+		}
+
+		results = append(results, RedriveResult{
+			JobID:    jobID,
+			Status:   decision.Status,
+			Reason:   decision.Reason,
+			Decision: decision,
+		})
+	}
+
+	return results, nil
+}
+
+func decodeItem(raw store.DeadLetterItem) (*Item, error) {
+	var e entry
+	if err := json.Unmarshal(raw.Entry, &e); err != nil {
+		return nil, fmt.Errorf("corrupt dead letter %s: %w", raw.JobID, err)
+	}
+
+	job, err := spec.DecodeJob(e.Job)
+	if err != nil {
+		return nil, fmt.Errorf("corrupt dead letter job %s: %w", raw.JobID, err)
+	}
+
+	item := &Item{
+		JobID:       raw.JobID,
+		TenantID:    job.TenantID,
+		BatchID:     job.BatchID,
+		Reason:      e.Reason,
+		Quarantined: e.Quarantined,
+		Attempts:    e.Attempts,
+		DeadAt:      raw.DeadAt,
+		Job:         job,
+	}
+	if item.Attempts == nil {
+		item.Attempts = []Attempt{}
+	}
+	if e.Quarantined {
+		item.QuarantinedUntil = &e.QuarantinedUntil
+	}
+
+	return item, nil
+}
@@ -0,0 +1,137 @@
+package deadletter
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/satyamraj1643/janus/internal/admission"
+	"github.com/satyamraj1643/janus/internal/store"
+	"github.com/satyamraj1643/janus/spec"
+)
+
+// newTestQueue returns a queue and a controller sharing an in-memory Redis that lives as long as the test
+func newTestQueue(t *testing.T) (*Queue, *admission.AdmissionController) {
+	t.Helper()
+	s := store.NewRedisStore(miniredis.RunT(t).Addr())
+	return NewQueue(s), admission.NewAdmissionController(s)
+}
+
+var testConfig = json.RawMessage(`{
+	"global_execution_limit": {"max_jobs": 10, "window_ms": 60000, "max_concurrent_per_tenant": 10},
+	"default_job_policy": {"idempotency_window_ms": 60000}
+}`)
+
+func testJob(id string) spec.Job {
+	return spec.Job{ID: id, TenantID: "tenant-a", OwnerID: "owner-a", BatchID: "batch-1"}
+}
+
+func TestListPagesNewestFirst(t *testing.T) {
+	ctx := context.Background()
+	q, _ := newTestQueue(t)
+
+	for _, id := range []string{"job-1", "job-2", "job-3"} {
+		if err := q.Add(ctx, testJob(id), "exhausted", []Attempt{{Attempt: 1, Reason: "boom"}}, time.Time{}); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(2 * time.Millisecond) // dead_at is kept in ms
+	}
+
+	first, err := q.List(ctx, "owner-a", "", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Total != 3 || len(first.Items) != 2 || first.Items[0].JobID != "job-3" || first.NextCursor == "" {
+		t.Fatalf("first page %+v", first)
+	}
+	if first.Items[0].Reason != "exhausted" || len(first.Items[0].Attempts) != 1 || first.Items[0].Quarantined {
+		t.Fatalf("item %+v", first.Items[0])
+	}
+
+	rest, err := q.List(ctx, "owner-a", first.NextCursor, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rest.Items) != 1 || rest.Items[0].JobID != "job-1" || rest.NextCursor != "" {
+		t.Fatalf("second page %+v", rest)
+	}
+
+	if _, err := q.List(ctx, "owner-a", "-1", 2); err != ErrInvalidCursor {
+		t.Fatalf("negative cursor got %v, want ErrInvalidCursor", err)
+	}
+	if other, _ := q.List(ctx, "owner-b", "", 2); other.Total != 0 {
+		t.Fatalf("another owner sees %d items", other.Total)
+	}
+}
+
+func TestOwnersDoNotShareKeys(t *testing.T) {
+	ctx := context.Background()
+	q, _ := newTestQueue(t)
+
+	// Unescaped, one owner's index would be the other's entries
+	for _, owner := range []string{"x", "entries:x"} {
+		job := testJob("job-1")
+		job.OwnerID = owner
+		if err := q.Add(ctx, job, "exhausted", nil, time.Time{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, owner := range []string{"x", "entries:x"} {
+		page, err := q.List(ctx, owner, "", 10)
+		if err != nil || page.Total != 1 || len(page.Items) != 1 {
+			t.Fatalf("owner %s got %+v, %v, want its one job", owner, page, err)
+		}
+	}
+}
+
+func TestRedrive(t *testing.T) {
+	ctx := context.Background()
+	q, ac := newTestQueue(t)
+
+	if err := q.Add(ctx, testJob("job-1"), "exhausted", nil, time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Add(ctx, testJob("job-2"), "poison", nil, time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := q.Redrive(ctx, ac, "owner-a", []string{"job-1", "job-2", "job-3"}, testConfig, "cfg-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"accepted", "quarantined", "not_found"}
+	for i, r := range results {
+		if r.Status != want[i] {
+			t.Errorf("%s: got %s, want %s", r.JobID, r.Status, want[i])
+		}
+	}
+
+	if item, _ := q.Get(ctx, "owner-a", "job-1"); item != nil {
+		t.Fatal("accepted job is still dead-lettered")
+	}
+	if item, _ := q.Get(ctx, "owner-a", "job-2"); item == nil || item.QuarantinedUntil == nil {
+		t.Fatalf("quarantined job got %+v, want it kept", item)
+	}
+}
+
+func TestPurge(t *testing.T) {
+	ctx := context.Background()
+	q, _ := newTestQueue(t)
+
+	for _, id := range []string{"job-1", "job-2", "job-3"} {
+		if err := q.Add(ctx, testJob(id), "exhausted", nil, time.Time{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if n, err := q.Purge(ctx, "owner-a", []string{"job-1", "missing"}); err != nil || n != 1 {
+		t.Fatalf("purging one: %d, %v", n, err)
+	}
+	if n, err := q.Purge(ctx, "owner-a", nil); err != nil || n != 2 {
+		t.Fatalf("purging the rest: %d, %v", n, err)
+	}
+}
@@ -0,0 +1,85 @@
+package lease
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/satyamraj1643/janus/internal/lifecycle"
+	"github.com/satyamraj1643/janus/internal/store"
+)
+
+func TestHeartbeatExtendsWithinMaxLease(t *testing.T) {
+	ctx := context.Background()
+	m, _ := newTestManager(t, 1)
+
+	grants, err := m.Lease(ctx, "owner", store.LeaseFilter{}, 1, time.Minute, 90*time.Second)
+	if err != nil || len(grants) != 1 || grants[0].MaxDeadline == nil {
+		t.Fatalf("leased %+v, %v, want a bounded lease", grants, err)
+	}
+	g := grants[0]
+
+	first, err := m.Heartbeat(ctx, "owner", g.LeaseID, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Running == nil || first.Running.Status != lifecycle.Running || first.Running.Actor != lifecycle.ActorWorker {
+		t.Fatalf("first beat reported %+v, want the job running", first.Running)
+	}
+	if !first.MaxDeadline.Equal(*g.MaxDeadline) {
+		t.Fatalf("max deadline %v, want %v", first.MaxDeadline, *g.MaxDeadline)
+	}
+
+	// A short extension never shortens the lease
+	short, err := m.Heartbeat(ctx, "owner", g.LeaseID, time.Second)
+	if err != nil || short.Running != nil {
+		t.Fatalf("second beat %+v, %v, want no running decision", short, err)
+	}
+	if short.Deadline.Before(first.Deadline) {
+		t.Fatalf("deadline moved back from %v to %v", first.Deadline, short.Deadline)
+	}
+
+	// Asking for more than the ttl gets the ttl; the max deadline is never passed
+	long, err := m.Heartbeat(ctx, "owner", g.LeaseID, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if long.Deadline.After(time.Now().Add(time.Minute)) || long.Deadline.After(long.MaxDeadline) {
+		t.Fatalf("deadline %v past the ttl or the max deadline %v", long.Deadline, long.MaxDeadline)
+	}
+}
+
+func TestHeartbeatAfterCompletion(t *testing.T) {
+	ctx := context.Background()
+	m, _ := newTestManager(t, 1)
+	g := leaseOne(t, m)
+
+	if _, err := m.Complete(ctx, "owner", g.LeaseID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Heartbeat(ctx, "owner", g.LeaseID, 0); !errors.Is(err, store.ErrLeaseNotFound) {
+		t.Fatalf("got %v, want ErrLeaseNotFound", err)
+	}
+	if _, err := m.Heartbeat(ctx, "other", g.LeaseID, 0); !errors.Is(err, store.ErrLeaseNotFound) {
+		t.Fatalf("another owner got %v, want ErrLeaseNotFound", err)
+	}
+}
+
+func TestHeartbeatStopsAtMaxDeadline(t *testing.T) {
+	ctx := context.Background()
+	m, _ := newTestManager(t, 1)
+
+	grants, err := m.Lease(ctx, "owner", store.LeaseFilter{}, 1, time.Minute, 2*time.Second)
+	if err != nil || len(grants) != 1 {
+		t.Fatalf("leased %+v, %v", grants, err)
+	}
+
+	beat, err := m.Heartbeat(ctx, "owner", grants[0].LeaseID, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if beat.Deadline.After(beat.MaxDeadline) || beat.MaxDeadline.After(time.Now().Add(2*time.Second)) {
+		t.Fatalf("deadline %v, max %v, want the lease held to its max", beat.Deadline, beat.MaxDeadline)
+	}
+}
@@ -0,0 +1,389 @@
+package lease
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/satyamraj1643/janus/internal/deadletter"
+	"github.com/satyamraj1643/janus/internal/lifecycle"
+	"github.com/satyamraj1643/janus/internal/policy"
+	"github.com/satyamraj1643/janus/internal/store"
+	"github.com/satyamraj1643/janus/permit"
+	"github.com/satyamraj1643/janus/spec"
+)
+
+// MaxLeasesPerRequest caps how many jobs one lease call hands out
+const MaxLeasesPerRequest = 100
+
+// reapBatch is how many expired leases are reclaimed per owner per pass
+const reapBatch = 100
+
+// reapHold keeps a reclaimed job from being leased until its backoff is applied,
+// it is leasable after it if the reaper stops in between
+const reapHold = time.Minute
+
+// Manager hands admitted jobs to workers and tracks what they report back
+type Manager struct {
+	Queue       store.ReadyQueue
+	DeadLetters *deadletter.Queue // optional, keeps jobs that will not run again
+	Permits     *permit.Signer    // optional, signs a permit for every lease
+}
+
+func NewManager(q store.ReadyQueue, dlq *deadletter.Queue) *Manager {
+	return &Manager{Queue: q, DeadLetters: dlq}
+}
+
+// Grant is a leased job as returned to a worker
+type Grant struct {
+	LeaseID     string     `json:"lease_id"`
+	JobID       string     `json:"job_id"`
+	Attempt     int        `json:"attempt"`
+	Deadline    time.Time  `json:"deadline"`
+	MaxDeadline *time.Time `json:"max_deadline,omitempty"` // heartbeats cannot extend past it
+	Permit      string     `json:"permit,omitempty"`       // valid for this lease until Deadline
+	Job         spec.Job   `json:"job"`
+}
+
+// Decision records that the job was leased
+func (g Grant) Decision() *spec.JobDecision {
+	return &spec.JobDecision{
+		JobID:     g.JobID,
+		BatchID:   g.Job.BatchID,
+		BatchName: g.Job.BatchName,
+		Status:    lifecycle.Leased,
+		Timestamp: time.Now(),
+		Attempt:   g.Attempt,
+		Actor:     lifecycle.ActorWorker,
+		Job:       g.Job,
+	}
+}
+
+// Lease hands out up to max available jobs of the owner, each held for ttl
+// and extendable by heartbeats for up to maxLease (0 = no bound)
+func (m *Manager) Lease(
+	ctx context.Context,
+	ownerID string,
+	filter store.LeaseFilter,
+	max int,
+	ttl, maxLease time.Duration,
+) ([]Grant, error) {
+	max = min(max, MaxLeasesPerRequest)
+	if max <= 0 {
+		return nil, nil
+	}
+
+	leaseIDs := make([]string, max)
+	for i := range leaseIDs {
+		leaseIDs[i] = uuid.NewString()
+	}
+
+	leases, err := m.Queue.Lease(ctx, ownerID, filter, leaseIDs, ttl, maxLease)
+	if err != nil {
+		return nil, err
+	}
+
+	grants := make([]Grant, 0, len(leases))
+	for _, l := range leases {
+		job, err := spec.DecodeJob(l.Job)
+		if err != nil {
+			return nil, fmt.Errorf("corrupt ready job %s: %w", l.JobID, err)
+		}
+
+		g := Grant{
+			LeaseID:  l.LeaseID,
+			JobID:    l.JobID,
+			Attempt:  l.Attempt,
+			Deadline: l.Deadline,
+			Job:      job,
+		}
+		if !l.MaxDeadline.IsZero() {
+			g.MaxDeadline = &l.MaxDeadline
+		}
+		if g.Permit, err = m.signPermit(job, l.LeaseID, l.Attempt, l.Deadline); err != nil {
+			return nil, err
+		}
+		grants = append(grants, g)
+	}
+
+	return grants, nil
+}
+
+// Beat is what a heartbeat returns
+type Beat struct {
+	Deadline    time.Time
+	MaxDeadline time.Time         // zero when heartbeats can extend the lease indefinitely
+	Permit      string            // signed again until the new deadline
+	Running     *spec.JobDecision // set on the lease's first heartbeat
+}
+
+// Heartbeat extends a lease to now plus extend, at most the lease's ttl (which
+// extend 0 stands for) and never past its max deadline. It returns the lease's deadlines
+// and a permit valid until the new deadline.
+// The first heartbeat of a lease also returns the decision that the job is running.
+func (m *Manager) Heartbeat(ctx context.Context, ownerID, leaseID string, extend time.Duration) (*Beat, error) {
+	extended, started, err := m.Queue.ExtendLease(ctx, ownerID, leaseID, extend)
+	if err != nil {
+		return nil, err
+	}
+
+	beat := &Beat{Deadline: extended.Deadline, MaxDeadline: extended.MaxDeadline}
+	if !started && m.Permits == nil {
+		return beat, nil
+	}
+
+	l, err := m.Queue.GetLease(ctx, ownerID, leaseID)
+	if err != nil {
+		// Finished in between, the job is past running already
+		return beat, nil
+	}
+
+	job, err := spec.DecodeJob(l.Job)
+	if err != nil {
+		return nil, fmt.Errorf("corrupt ready job %s: %w", l.JobID, err)
+	}
+
+	if beat.Permit, err = m.signPermit(job, leaseID, l.Attempt, beat.Deadline); err != nil {
+		return nil, err
+	}
+	if started {
+		beat.Running, err = finished(l, lifecycle.Running, "", lifecycle.ActorWorker)
+	}
+	return beat, err
+}
+
+// signPermit signs the permit for one lease of the job, valid until the lease deadline
+func (m *Manager) signPermit(job spec.Job, leaseID string, attempt int, deadline time.Time) (string, error) {
+	if m.Permits == nil {
+		return "", nil
+	}
+
+	return m.Permits.Sign(permit.Claims{
+		JobID:        job.ID,
+		OwnerID:      job.OwnerID,
+		TenantID:     job.TenantID,
+		Dependencies: job.Dependencies,
+		LeaseID:      leaseID,
+		Attempt:      attempt,
+		IssuedAt:     time.Now().Unix(),
+		ExpiresAt:    deadline.Unix(),
+	})
+}
+
+// Complete ends a lease whose job ran successfully
+func (m *Manager) Complete(ctx context.Context, ownerID, leaseID string) (*spec.JobDecision, error) {
+	l, err := m.Queue.CompleteLease(ctx, ownerID, leaseID)
+	if err != nil {
+		return nil, err
+	}
+
+	_ = m.Queue.ClearAttempts(ctx, ownerID, l.JobID)
+
+	return finished(l, lifecycle.Succeeded, "", lifecycle.ActorWorker)
+}
+
+// Fail ends a lease whose job failed. The job is retried after the policy's
+// backoff while attempts are left, otherwise it is dead-lettered and reported dead.
+// A job failing quarantine.failure_threshold times within the monitoring window
+// is dead-lettered right away and cannot be redriven for quarantine_duration_ms.
+func (m *Manager) Fail(ctx context.Context, ownerID, leaseID, reason string) (*spec.JobDecision, error) {
+	l, err := m.Queue.GetLease(ctx, ownerID, leaseID)
+	if err != nil {
+		return nil, err
+	}
+
+	job, err := spec.DecodeJob(l.Job)
+	if err != nil {
+		return nil, fmt.Errorf("corrupt ready job %s: %w", l.JobID, err)
+	}
+
+	// The policy snapshot taken at submission still governs the job
+	jobPolicy, err := policy.ParseConfig(job.Config)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+
+	prior, err := m.Queue.Attempts(ctx, ownerID, l.JobID)
+	if err != nil {
+		return nil, err
+	}
+	attempt := deadletter.Attempt{Attempt: l.Attempt, Reason: reason, FailedAt: now}
+	raw, err := json.Marshal(attempt)
+	if err != nil {
+		return nil, err
+	}
+	history := append(decodeAttempts(prior), attempt)
+
+	var quarantinedUntil time.Time
+	if q := jobPolicy.DefaultJobPolicy.Quarantine; q != nil {
+		if recentFailures(history, now, time.Duration(q.MonitoringWindowMs)*time.Millisecond) >= q.FailureThreshold {
+			quarantinedUntil = now.Add(time.Duration(q.QuarantineDurationMs) * time.Millisecond)
+		}
+	}
+
+	// The lease is checked and ended together with recording the attempt,
+	// so a reaper or a second report acting meanwhile wins and this one fails
+	retry := jobPolicy.DefaultJobPolicy.Retry
+	if quarantinedUntil.IsZero() && l.Attempt < retry.Attempts() {
+		l, err := m.Queue.FailLease(ctx, ownerID, leaseID, l.JobID, raw, now.Add(retry.Delay(l.Attempt)))
+		if err != nil {
+			return nil, err
+		}
+		return finished(l, lifecycle.Retrying, reason, lifecycle.ActorWorker)
+	}
+
+	l, err = m.Queue.FailLease(ctx, ownerID, leaseID, l.JobID, raw, time.Time{})
+	if err != nil {
+		return nil, err
+	}
+
+	decisionReason := reason
+	if !quarantinedUntil.IsZero() {
+		decisionReason = "quarantined"
+	}
+
+	return m.bury(ctx, l, job, reason, decisionReason, history, quarantinedUntil, lifecycle.ActorWorker), nil
+}
+
+// Reap reclaims expired leases of every owner and returns a retrying or dead decision for each.
+// Retried jobs wait out the policy's backoff like jobs whose worker reported a failure.
+func (m *Manager) Reap(ctx context.Context) ([]*spec.JobDecision, error) {
+	owners, err := m.Queue.LeaseOwners(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var decisions []*spec.JobDecision
+	for _, ownerID := range owners {
+		leases, err := m.Queue.ReapExpired(ctx, ownerID, reapBatch, reapHold)
+		if err != nil {
+			return decisions, err
+		}
+
+		for i := range leases {
+			decisions = append(decisions, m.reaped(ctx, &leases[i]))
+		}
+	}
+
+	return decisions, nil
+}
+
+// reaped handles a lease ReapExpired ended. The lease is gone by now, so the job
+// is retried or buried with whatever can still be read about it.
+func (m *Manager) reaped(ctx context.Context, l *store.Lease) *spec.JobDecision {
+	now := time.Now()
+
+	history, err := m.addAttempt(ctx, l, "lease_expired", now)
+	if err != nil {
+		slog.Error("recording expired attempt failed", "job_id", l.JobID, "err", err)
+		history = []deadletter.Attempt{{Attempt: l.Attempt, Reason: "lease_expired", FailedAt: now}}
+	}
+
+	job, err := spec.DecodeJob(l.Job)
+	if err != nil {
+		slog.Error("corrupt ready job", "job_id", l.JobID, "err", err)
+		job = spec.Job{ID: l.JobID, OwnerID: l.OwnerID}
+	}
+
+	if l.Dead {
+		return m.bury(ctx, l, job, "lease_expired", "lease_expired", history, time.Time{}, lifecycle.ActorReaper)
+	}
+
+	// Without its policy the job is leasable again after reapHold
+	if jobPolicy, err := policy.ParseConfig(job.Config); err == nil {
+		retryAt := now.Add(jobPolicy.DefaultJobPolicy.Retry.Delay(l.Attempt))
+		if err := m.Queue.DelayReady(ctx, l.OwnerID, l.JobID, retryAt); err != nil {
+			slog.Error("delaying reaped job failed", "job_id", l.JobID, "err", err)
+		}
+	}
+
+	return decided(l, job, lifecycle.Retrying, "lease_expired", lifecycle.ActorReaper)
+}
+
+// addAttempt records a failed attempt and returns the job's failure history
+func (m *Manager) addAttempt(ctx context.Context, l *store.Lease, reason string, at time.Time) ([]deadletter.Attempt, error) {
+	raw, err := json.Marshal(deadletter.Attempt{Attempt: l.Attempt, Reason: reason, FailedAt: at})
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := m.Queue.AddAttempt(ctx, l.OwnerID, l.JobID, raw)
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeAttempts(entries), nil
+}
+
+// decodeAttempts reads a failure history as stored, skipping corrupt entries
+func decodeAttempts(entries [][]byte) []deadletter.Attempt {
+	history := make([]deadletter.Attempt, 0, len(entries)+1)
+	for _, e := range entries {
+		var a deadletter.Attempt
+		if json.Unmarshal(e, &a) == nil {
+			history = append(history, a)
+		}
+	}
+	return history
+}
+
+// bury moves a job that left the ready queue for good into the dead-letter queue
+func (m *Manager) bury(
+	ctx context.Context,
+	l *store.Lease,
+	job spec.Job,
+	failure, decisionReason string,
+	history []deadletter.Attempt,
+	quarantinedUntil time.Time,
+	actor string,
+) *spec.JobDecision {
+	if m.DeadLetters != nil {
+		if err := m.DeadLetters.Add(ctx, job, failure, history, quarantinedUntil); err != nil {
+			// The dead decision is still recorded, only the redrive copy is lost
+			slog.Error("dead-lettering job failed", "job_id", job.ID, "err", err)
+		}
+	}
+	_ = m.Queue.ClearAttempts(ctx, l.OwnerID, l.JobID)
+
+	return decided(l, job, lifecycle.Dead, decisionReason, actor)
+}
+
+// recentFailures counts the failures within window before now
+func recentFailures(history []deadletter.Attempt, now time.Time, window time.Duration) int {
+	n := 0
+	for _, a := range history {
+		if now.Sub(a.FailedAt) <= window {
+			n++
+		}
+	}
+	return n
+}
+
+// finished builds the decision for a lease that moved its job to status
+func finished(l *store.Lease, status, reason, actor string) (*spec.JobDecision, error) {
+	job, err := spec.DecodeJob(l.Job)
+	if err != nil {
+		return nil, fmt.Errorf("corrupt ready job %s: %w", l.JobID, err)
+	}
+	return decided(l, job, status, reason, actor), nil
+}
+
+// decided is finished for a job already decoded
+func decided(l *store.Lease, job spec.Job, status, reason, actor string) *spec.JobDecision {
+	return &spec.JobDecision{
+		JobID:     job.ID,
+		BatchID:   job.BatchID,
+		BatchName: job.BatchName,
+		Status:    status,
+		Reason:    reason,
+		Timestamp: time.Now(),
+		Attempt:   l.Attempt,
+		Actor:     actor,
+		Job:       job,
+	}
+}
@@ -0,0 +1,261 @@
+package lease
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/satyamraj1643/janus/internal/deadletter"
+	"github.com/satyamraj1643/janus/internal/lifecycle"
+	"github.com/satyamraj1643/janus/internal/store"
+	"github.com/satyamraj1643/janus/spec"
+)
+
+// newTestManager returns a manager on an in-memory Redis holding one ready job
+// that may run maxAttempts times
+func newTestManager(t *testing.T, maxAttempts int) (*Manager, *store.RedisStore) {
+	t.Helper()
+	s := store.NewRedisStore(miniredis.RunT(t).Addr())
+
+	cfg, _ := json.Marshal(map[string]any{
+		"global_execution_limit": map[string]any{"max_jobs": 10, "window_ms": 1000},
+		"default_job_policy": map[string]any{
+			"idempotency_window_ms": 1000,
+			"retry":                 map[string]any{"max_attempts": maxAttempts},
+		},
+	})
+	encoded, err := spec.EncodeJob(spec.Job{ID: "job-1", TenantID: "tenant-a", OwnerID: "owner", Config: cfg})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = s.Enqueue(context.Background(), store.ReadyJob{
+		JobID:       "job-1",
+		OwnerID:     "owner",
+		TenantID:    "tenant-a",
+		MaxAttempts: maxAttempts,
+		Job:         encoded,
+		AvailableAt: time.Now(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return NewManager(s, nil), s
+}
+
+func leaseOne(t *testing.T, m *Manager) Grant {
+	t.Helper()
+	grants, err := m.Lease(context.Background(), "owner", store.LeaseFilter{}, 10, time.Minute, 0)
+	if err != nil || len(grants) != 1 || grants[0].JobID != "job-1" {
+		t.Fatalf("leased %+v, %v, want job-1", grants, err)
+	}
+	return grants[0]
+}
+
+func TestLeaseHandsOutJobOnce(t *testing.T) {
+	ctx := context.Background()
+	m, _ := newTestManager(t, 1)
+
+	g := leaseOne(t, m)
+	if g.Attempt != 1 || g.Job.TenantID != "tenant-a" {
+		t.Fatalf("grant %+v", g)
+	}
+
+	more, err := m.Lease(ctx, "owner", store.LeaseFilter{}, 10, time.Minute, 0)
+	if err != nil || len(more) != 0 {
+		t.Fatalf("second lease got %+v, %v, want nothing while leased", more, err)
+	}
+
+	other, err := m.Lease(ctx, "owner", store.LeaseFilter{TenantID: "tenant-b"}, 10, time.Minute, 0)
+	if err != nil || len(other) != 0 {
+		t.Fatalf("filtered lease got %+v, %v", other, err)
+	}
+}
+
+func TestComplete(t *testing.T) {
+	ctx := context.Background()
+	m, _ := newTestManager(t, 1)
+	g := leaseOne(t, m)
+
+	d, err := m.Complete(ctx, "owner", g.LeaseID)
+	if err != nil || d.Status != lifecycle.Succeeded || d.Attempt != 1 {
+		t.Fatalf("complete got %+v, %v", d, err)
+	}
+
+	if _, err := m.Complete(ctx, "owner", g.LeaseID); !errors.Is(err, store.ErrLeaseNotFound) {
+		t.Fatalf("second complete got %v, want ErrLeaseNotFound", err)
+	}
+}
+
+func TestFailRetriesUntilAttemptsRunOut(t *testing.T) {
+	ctx := context.Background()
+	m, s := newTestManager(t, 2)
+
+	g := leaseOne(t, m)
+	d, err := m.Fail(ctx, "owner", g.LeaseID, "boom")
+	if err != nil || d.Status != lifecycle.Retrying || d.Reason != "boom" {
+		t.Fatalf("first failure got %+v, %v, want retrying", d, err)
+	}
+
+	// A late second report of the same lease changes nothing
+	if _, err := m.Fail(ctx, "owner", g.LeaseID, "boom"); !errors.Is(err, store.ErrLeaseNotFound) {
+		t.Fatalf("repeated failure got %v, want ErrLeaseNotFound", err)
+	}
+
+	g = leaseOne(t, m)
+	if g.Attempt != 2 {
+		t.Fatalf("retry is attempt %d, want 2", g.Attempt)
+	}
+
+	d, err = m.Fail(ctx, "owner", g.LeaseID, "boom again")
+	if err != nil || d.Status != lifecycle.Dead {
+		t.Fatalf("last failure got %+v, %v, want dead", d, err)
+	}
+
+	if left, err := s.RemoveReady(ctx, "owner", "job-1"); err != nil || left != nil {
+		t.Fatalf("dead job still queued: %+v, %v", left, err)
+	}
+	if history, _ := s.Attempts(ctx, "owner", "job-1"); len(history) != 0 {
+		t.Fatalf("history kept after the job died: %d entries", len(history))
+	}
+}
+
+func TestFailLeaseChecksJob(t *testing.T) {
+	ctx := context.Background()
+	m, s := newTestManager(t, 3)
+	g := leaseOne(t, m)
+
+	_, err := s.FailLease(ctx, "owner", g.LeaseID, "job-2", []byte(`{}`), time.Now())
+	if !errors.Is(err, store.ErrLeaseNotFound) {
+		t.Fatalf("failing another job's lease got %v, want ErrLeaseNotFound", err)
+	}
+	if history, _ := s.Attempts(ctx, "owner", "job-1"); len(history) != 0 {
+		t.Fatal("attempt recorded although the lease did not match")
+	}
+
+	_, err = s.FailLease(ctx, "owner", g.LeaseID, "job-1", []byte(`{"attempt":1}`), time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if history, _ := s.Attempts(ctx, "owner", "job-1"); len(history) != 1 {
+		t.Fatalf("history has %d entries, want 1", len(history))
+	}
+}
+
+func TestReapReclaimsExpiredLeases(t *testing.T) {
+	ctx := context.Background()
+	m, _ := newTestManager(t, 2)
+
+	if _, err := m.Lease(ctx, "owner", store.LeaseFilter{}, 1, time.Millisecond, 0); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(5 * time.Millisecond)
+
+	decisions, err := m.Reap(ctx)
+	if err != nil || len(decisions) != 1 {
+		t.Fatalf("reaped %+v, %v, want one decision", decisions, err)
+	}
+	if d := decisions[0]; d.Status != lifecycle.Retrying || d.Reason != "lease_expired" || d.Actor != lifecycle.ActorReaper {
+		t.Fatalf("reaped decision %+v", d)
+	}
+
+	if g := leaseOne(t, m); g.Attempt != 2 {
+		t.Fatalf("retry is attempt %d, want 2", g.Attempt)
+	}
+}
+
+func TestReapAppliesBackoff(t *testing.T) {
+	ctx := context.Background()
+	m, s := newTestManager(t, 2)
+
+	cfg, _ := json.Marshal(map[string]any{
+		"global_execution_limit": map[string]any{"max_jobs": 10, "window_ms": 1000},
+		"default_job_policy": map[string]any{
+			"idempotency_window_ms": 1000,
+			"retry":                 map[string]any{"max_attempts": 2, "initial_delay_ms": 60000},
+		},
+	})
+	encoded, _ := spec.EncodeJob(spec.Job{ID: "job-2", TenantID: "tenant-a", OwnerID: "owner", Config: cfg})
+	err := s.Enqueue(ctx, store.ReadyJob{JobID: "job-2", OwnerID: "owner", MaxAttempts: 2, Job: encoded, AvailableAt: time.Now()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if grants, err := m.Lease(ctx, "owner", store.LeaseFilter{}, 2, time.Millisecond, 0); err != nil || len(grants) != 2 {
+		t.Fatalf("leased %+v, %v, want both jobs", grants, err)
+	}
+	time.Sleep(5 * time.Millisecond)
+
+	if decisions, err := m.Reap(ctx); err != nil || len(decisions) != 2 {
+		t.Fatalf("reaped %+v, %v, want two decisions", decisions, err)
+	}
+
+	// job-2 waits out its minute of backoff, job-1 has none
+	grants, err := m.Lease(ctx, "owner", store.LeaseFilter{}, 2, time.Minute, 0)
+	if err != nil || len(grants) != 1 || grants[0].JobID != "job-1" {
+		t.Fatalf("leased %+v, %v, want only job-1", grants, err)
+	}
+}
+
+// unrecordedAttempts fails to keep failure histories
+type unrecordedAttempts struct {
+	store.ReadyQueue
+}
+
+func (unrecordedAttempts) AddAttempt(context.Context, string, string, []byte) ([][]byte, error) {
+	return nil, errors.New("history unavailable")
+}
+
+func TestReapBuriesJobsWithoutHistory(t *testing.T) {
+	ctx := context.Background()
+	m, s := newTestManager(t, 1)
+	m.Queue = unrecordedAttempts{s}
+	m.DeadLetters = deadletter.NewQueue(s)
+
+	if _, err := m.Lease(ctx, "owner", store.LeaseFilter{}, 1, time.Millisecond, 0); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(5 * time.Millisecond)
+
+	decisions, err := m.Reap(ctx)
+	if err != nil || len(decisions) != 1 || decisions[0].Status != lifecycle.Dead {
+		t.Fatalf("reaped %+v, %v, want job-1 dead", decisions, err)
+	}
+
+	item, err := m.DeadLetters.Get(ctx, "owner", "job-1")
+	if err != nil || item == nil || len(item.Attempts) != 1 {
+		t.Fatalf("dead letter %+v, %v, want job-1 with its last attempt", item, err)
+	}
+}
+
+func TestReapBuriesCorruptJobs(t *testing.T) {
+	ctx := context.Background()
+	_, s := newTestManager(t, 1)
+	m := NewManager(s, deadletter.NewQueue(s))
+
+	err := s.Enqueue(ctx, store.ReadyJob{JobID: "job-2", OwnerID: "owner", MaxAttempts: 1, Job: []byte("corrupt"), AvailableAt: time.Now()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Lease(ctx, "owner", store.LeaseFilter{}, []string{"lease-1", "lease-2"}, time.Millisecond, 0); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(5 * time.Millisecond)
+
+	decisions, err := m.Reap(ctx)
+	if err != nil || len(decisions) != 2 {
+		t.Fatalf("reaped %+v, %v, want both jobs", decisions, err)
+	}
+	for _, d := range decisions {
+		if d.Status != lifecycle.Dead {
+			t.Fatalf("job %s is %s, want dead", d.JobID, d.Status)
+		}
+	}
+	if item, _ := m.DeadLetters.Get(ctx, "owner", "job-2"); item == nil {
+		t.Fatal("corrupt job was not dead-lettered")
+	}
+}
@@ -0,0 +1,43 @@
+package lease
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/satyamraj1643/janus/permit"
+)
+
+func TestLeasePermits(t *testing.T) {
+	ctx := context.Background()
+	m, _ := newTestManager(t, 1)
+
+	signer, err := permit.NewSigner(bytes.Repeat([]byte{1}, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Permits = signer
+
+	g := leaseOne(t, m)
+	claims, err := permit.Verify(signer.PublicKey(), g.Permit, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.JobID != "job-1" || claims.OwnerID != "owner" || claims.LeaseID != g.LeaseID || claims.Attempt != 1 {
+		t.Fatalf("lease permit claims %+v", claims)
+	}
+	if claims.ExpiresAt != g.Deadline.Unix() {
+		t.Fatalf("permit expires %d, want the lease deadline %d", claims.ExpiresAt, g.Deadline.Unix())
+	}
+
+	time.Sleep(time.Second) // the permit is kept in unix seconds
+	beat, err := m.Heartbeat(ctx, "owner", g.LeaseID, 0)
+	if err != nil || beat.Permit == "" || beat.Permit == g.Permit {
+		t.Fatalf("heartbeat %+v, %v, want a new permit", beat, err)
+	}
+	renewed, err := permit.Verify(signer.PublicKey(), beat.Permit, time.Now())
+	if err != nil || renewed.ExpiresAt != beat.Deadline.Unix() || renewed.LeaseID != g.LeaseID {
+		t.Fatalf("heartbeat permit %+v, %v, want it valid until %v", renewed, err, beat.Deadline)
+	}
+}
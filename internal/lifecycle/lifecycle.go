@@ -0,0 +1,90 @@
+// Package lifecycle is the job state machine. Every status Janus persists for a job
+// must be reachable from the stored one, SaveJob checks each decision against it.
+package lifecycle
+
+import "fmt"
+
+// Job statuses. Accepted is the admitted state.
+const (
+	Accepted  = "accepted"
+	Rejected  = "rejected"
+	Scheduled = "scheduled"
+	Deferred  = "deferred"
+	Leased    = "leased"
+	Running   = "running"
+	Succeeded = "succeeded"
+	Failed    = "failed"
+	Retrying  = "retrying"
+	Dead      = "dead"
+	Cancelled = "cancelled"
+	Expired   = "expired"
+)
+
+// Who moved the job, recorded with every transition
+const (
+	ActorJanus  = "janus"  // admission, scheduler and deferred releaser
+	ActorOwner  = "owner"  // the user, e.g. by cancelling
+	ActorWorker = "worker" // lease holder reporting progress
+	ActorReaper = "reaper" // lease reaper reclaiming an expired lease
+)
+
+// submitted is where admission can put a job, either new or submitted again
+// after it ended or was accepted (its idempotency key cleared or expired)
+var submitted = []string{Accepted, Rejected, Scheduled, Deferred, Expired}
+
+var transitions = map[string][]string{
+	"":        submitted,
+	Scheduled: {Accepted, Rejected, Deferred, Expired, Cancelled},
+	Deferred:  {Accepted, Rejected, Expired, Cancelled},
+	Accepted:  append([]string{Leased, Cancelled}, submitted...),
+	Leased:    {Running, Succeeded, Failed, Cancelled},
+	Running:   {Succeeded, Failed, Cancelled},
+	Failed:    {Retrying, Dead},
+	Retrying:  {Leased, Cancelled},
+
+	Succeeded: submitted,
+	Rejected:  submitted,
+	Expired:   submitted,
+	Cancelled: submitted,
+	Dead:      submitted, // redrive
+}
+
+// TransitionError is returned for a status that cannot follow the stored one
+type TransitionError struct {
+	From string // "" if the job is not stored yet
+	To   string
+}
+
+func (e *TransitionError) Error() string {
+	from := e.From
+	if from == "" {
+		from = "new"
+	}
+	return fmt.Sprintf("illegal job transition %s -> %s", from, e.To)
+}
+
+// Path returns the states a job passes through from one status to the next.
+// A failure is reported together with its outcome, so a leased or running job
+// moving to retrying or dead passes through failed. Moving to the same status
+// returns an empty path, unless it is a new submission of an accepted or ended job.
+func Path(from, to string) ([]string, error) {
+	if allowed(from, to) {
+		return []string{to}, nil
+	}
+	if from == to {
+		return nil, nil
+	}
+	if allowed(from, Failed) && allowed(Failed, to) {
+		return []string{Failed, to}, nil
+	}
+	return nil, &TransitionError{From: from, To: to}
+}
+
+func allowed(from, to string) bool {
+	for _, s := range transitions[from] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
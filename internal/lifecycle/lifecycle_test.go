@@ -0,0 +1,60 @@
+package lifecycle
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestPath(t *testing.T) {
+	for _, tc := range []struct {
+		from, to string
+		want     []string
+	}{
+		{"", Accepted, []string{Accepted}},
+		{"", Scheduled, []string{Scheduled}},
+		{Scheduled, Deferred, []string{Deferred}},
+		{Accepted, Leased, []string{Leased}},
+		{Leased, Running, []string{Running}},
+		{Running, Succeeded, []string{Succeeded}},
+		{Leased, Retrying, []string{Failed, Retrying}},
+		{Running, Dead, []string{Failed, Dead}},
+		{Retrying, Leased, []string{Leased}},
+		{Dead, Accepted, []string{Accepted}},
+		{Succeeded, Deferred, []string{Deferred}},
+		{Running, Running, nil},
+		{Scheduled, Scheduled, nil},
+		// Submitted again after the idempotency window
+		{Accepted, Accepted, []string{Accepted}},
+		{Accepted, Rejected, []string{Rejected}},
+		{Rejected, Rejected, []string{Rejected}},
+	} {
+		got, err := Path(tc.from, tc.to)
+		if err != nil || !slices.Equal(got, tc.want) {
+			t.Errorf("%q -> %s: got %v, %v, want %v", tc.from, tc.to, got, err, tc.want)
+		}
+	}
+}
+
+func TestPathRejectsIllegalTransitions(t *testing.T) {
+	for _, tc := range []struct{ from, to string }{
+		{"", Leased},
+		{"", Succeeded},
+		{Accepted, Running},
+		{Scheduled, Leased},
+		{Succeeded, Failed},
+		{Dead, Retrying},
+		{Cancelled, Leased},
+		{Accepted, Retrying},
+	} {
+		_, err := Path(tc.from, tc.to)
+		var illegal *TransitionError
+		if !errors.As(err, &illegal) || illegal.From != tc.from || illegal.To != tc.to {
+			t.Errorf("%q -> %s: got %v, want a TransitionError", tc.from, tc.to, err)
+		}
+	}
+
+	if msg := (&TransitionError{To: Leased}).Error(); msg != "illegal job transition new -> leased" {
+		t.Errorf("got %q", msg)
+	}
+}
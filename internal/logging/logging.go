@@ -0,0 +1,59 @@
+// Package logging configures the process wide slog logger
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// Setup installs the default logger from LOG_LEVEL (debug, info, warn, error; default info)
+// and LOG_FORMAT (json, the default, or text). Output goes to stderr.
+// The stdlib log package is routed through it as well.
+func Setup() error {
+	level, err := ParseLevel(os.Getenv("LOG_LEVEL"))
+	if err != nil {
+		return err
+	}
+
+	handler, err := newHandler(os.Getenv("LOG_FORMAT"), os.Stderr, level)
+	if err != nil {
+		return err
+	}
+
+	slog.SetDefault(slog.New(handler))
+	return nil
+}
+
+// ParseLevel reads a level name, info when empty
+func ParseLevel(s string) (slog.Level, error) {
+	var level slog.Level
+	if s == "" {
+		return slog.LevelInfo, nil
+	}
+	if err := level.UnmarshalText([]byte(s)); err != nil {
+		return 0, fmt.Errorf("invalid log level %q", s)
+	}
+	return level, nil
+}
+
+func newHandler(format string, w io.Writer, level slog.Leveler) (slog.Handler, error) {
+	opts := &slog.HandlerOptions{Level: level}
+
+	switch strings.ToLower(format) {
+	case "", "json":
+		return slog.NewJSONHandler(w, opts), nil
+	case "text":
+		return slog.NewTextHandler(w, opts), nil
+	default:
+		return nil, fmt.Errorf("invalid log format %q, want json or text", format)
+	}
+}
+
+// Fatal logs msg at error level and exits
+func Fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
@@ -0,0 +1,39 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestParseLevel(t *testing.T) {
+	for in, want := range map[string]slog.Level{"": slog.LevelInfo, "debug": slog.LevelDebug, "WARN": slog.LevelWarn, "error": slog.LevelError} {
+		if got, err := ParseLevel(in); err != nil || got != want {
+			t.Errorf("%q: got %v, %v, want %v", in, got, err, want)
+		}
+	}
+	if _, err := ParseLevel("verbose"); err == nil {
+		t.Error("accepted an unknown level")
+	}
+}
+
+func TestNewHandler(t *testing.T) {
+	var buf bytes.Buffer
+	h, err := newHandler("text", &buf, slog.LevelWarn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.Enabled(context.Background(), slog.LevelInfo) {
+		t.Error("info enabled at warn level")
+	}
+	slog.New(h).Warn("careful", "job_id", "job-1")
+	if !strings.Contains(buf.String(), "job_id=job-1") {
+		t.Errorf("text handler wrote %q", buf.String())
+	}
+
+	if _, err := newHandler("xml", &buf, slog.LevelInfo); err == nil {
+		t.Error("accepted an unknown format")
+	}
+}
@@ -0,0 +1,114 @@
+// Package metrics holds the Prometheus collectors Janus exposes on /metrics
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// OtherDependency labels decisions for dependencies the policy does not configure
+const OtherDependency = "other"
+
+var (
+	// Decisions counts every recorded decision. A job with dependencies is counted once
+	// per dependency, one without under dependency "". Dependencies the job's policy does
+	// not configure are counted under OtherDependency, so producers cannot add series.
+	Decisions = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "janus_decisions_total",
+		Help: "Job decisions by status, reason, owner and dependency.",
+	}, []string{"status", "reason", "owner", "dependency"})
+
+	CheckDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "janus_check_duration_seconds",
+		Help:    "Admission latency by operation (check, redrive, batch_atomic, batch_partial, batch_prefix, batch_priority).",
+		Buckets: prometheus.ExponentialBuckets(0.0005, 2, 14), // 0.5ms .. 4s
+	}, []string{"op"})
+
+	ScriptDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "janus_redis_script_duration_seconds",
+		Help:    "Redis Lua script latency by script.",
+		Buckets: prometheus.ExponentialBuckets(0.0001, 2, 14), // 0.1ms .. 800ms
+	}, []string{"script"})
+
+	ScriptErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "janus_redis_script_errors_total",
+		Help: "Redis Lua script calls that failed, by script.",
+	}, []string{"script"})
+
+	DBSaveDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "janus_db_save_duration_seconds",
+		Help:    "DBWriter latency of saving one flush of decisions.",
+		Buckets: prometheus.ExponentialBuckets(0.0005, 2, 14),
+	})
+
+	DBFlushSize = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "janus_db_flush_size",
+		Help:    "Decisions saved per DBWriter flush.",
+		Buckets: prometheus.ExponentialBuckets(1, 2, 12),
+	})
+
+	// DBSaveFailures is labelled error for saves left to be redelivered,
+	// illegal_transition for decisions dropped by the lifecycle check
+	DBSaveFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "janus_db_save_failures_total",
+		Help: "DBWriter saves that failed, by kind.",
+	}, []string{"kind"})
+
+	DBOutboxed = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "janus_db_outboxed_total",
+		Help: "Decisions the DBWriter held in the outbox instead of saving them.",
+	})
+
+	// OutboxRelayed is labelled saved, illegal_transition for decisions dropped by the lifecycle check,
+	// or dead_lettered for those given up on after outbox.MaxAttempts failed relays
+	OutboxRelayed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "janus_outbox_relayed_total",
+		Help: "Decisions relayed from the outbox to the database, by outcome.",
+	}, []string{"outcome"})
+
+	ConfigCacheHits = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "janus_config_cache_hits_total",
+		Help: "Active config lookups served from the in-memory cache.",
+	})
+
+	ConfigCacheMisses = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "janus_config_cache_misses_total",
+		Help: "Active config lookups that went to the database.",
+	})
+
+	ListenerReconnects = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "janus_config_listener_reconnects_total",
+		Help: "Times the config LISTEN connection was re-established.",
+	})
+)
+
+// Since observes the seconds elapsed since start on h
+func Since(h prometheus.Observer, start time.Time) {
+	h.Observe(time.Since(start).Seconds())
+}
+
+// WatchResultQueue exports depth, read at scrape time, as the ResultQueue backlog
+func WatchResultQueue(depth func() float64) {
+	promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "janus_result_queue_depth",
+		Help: "Decisions waiting for the DB writer, -1 when unknown.",
+	}, depth)
+}
+
+// WatchResultSpill exports spilled, read at scrape time, as the decisions ResultQueue holds on disk
+func WatchResultSpill(spilled func() float64) {
+	promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "janus_result_queue_spilled",
+		Help: "Decisions spilled to disk waiting to be replayed to the DB writer.",
+	}, spilled)
+}
+
+// WatchOutbox exports jobs, read at scrape time, as the jobs waiting in the outbox
+func WatchOutbox(jobs func() float64) {
+	promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "janus_outbox_jobs",
+		Help: "Jobs with decisions in the outbox waiting to be relayed, -1 when unknown.",
+	}, jobs)
+}
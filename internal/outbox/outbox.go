@@ -0,0 +1,215 @@
+// Package outbox holds decisions the database could not save until they can be relayed to it
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/satyamraj1643/janus/internal/metrics"
+	"github.com/satyamraj1643/janus/internal/store"
+	"github.com/satyamraj1643/janus/spec"
+)
+
+const (
+	// MaxAttempts is how many relays of a decision may fail before it is dead-lettered
+	MaxAttempts = 10
+	maxBackoff  = 10 * time.Minute
+)
+
+// entry is a decision as the outbox holds it
+type entry struct {
+	Attempts int             `json:"attempts,omitempty"` // relays that failed so far
+	Decision json.RawMessage `json:"decision"`
+}
+
+// Job is a job's held decisions, in the order they were recorded
+type Job struct {
+	JobID       string
+	Decisions   []*spec.JobDecision
+	attempts    []int    // failed relays of each decision
+	held        int      // entries claimed, undecodable ones included
+	undecodable [][]byte // dead-lettered with the next change to the job
+}
+
+// Attempts is how many relays of the job's oldest decision failed
+func (j Job) Attempts() int {
+	if len(j.attempts) == 0 {
+		return 0
+	}
+	return j.attempts[0]
+}
+
+// Outbox keeps decisions per job, so a job's later decisions queue up behind
+// the ones that failed instead of reaching the database before them
+type Outbox struct {
+	Store store.OutboxStore
+}
+
+func New(s store.OutboxStore) *Outbox {
+	return &Outbox{Store: s}
+}
+
+// Add holds the decisions. Jobs the outbox does not hold yet are due for relaying after delay.
+func (o *Outbox) Add(ctx context.Context, decisions []*spec.JobDecision, delay time.Duration) error {
+	attempts := make([]int, len(decisions))
+	return o.add(ctx, decisions, attempts, delay)
+}
+
+func (o *Outbox) add(ctx context.Context, decisions []*spec.JobDecision, attempts []int, delay time.Duration) error {
+	entries := make([]store.OutboxEntry, 0, len(decisions))
+	for i, decision := range decisions {
+		encoded, err := encode(decision, attempts[i])
+		if err != nil {
+			return err
+		}
+		entries = append(entries, store.OutboxEntry{JobID: decision.JobID, Entry: encoded})
+	}
+
+	return o.Store.AddToOutbox(ctx, entries, time.Now().Add(delay))
+}
+
+// Holding returns which of the decisions' jobs have decisions in the outbox
+func (o *Outbox) Holding(ctx context.Context, decisions []*spec.JobDecision) (map[string]bool, error) {
+	ids := make([]string, len(decisions))
+	for i, decision := range decisions {
+		ids[i] = decision.JobID
+	}
+	return o.Store.InOutbox(ctx, ids)
+}
+
+// Claim returns up to limit due jobs. They come due again after retryAfter unless Done is called.
+func (o *Outbox) Claim(ctx context.Context, limit int, retryAfter time.Duration) ([]Job, error) {
+	claimed, err := o.Store.ClaimOutbox(ctx, time.Now(), limit, retryAfter)
+	if err != nil {
+		return nil, err
+	}
+
+	jobs := make([]Job, 0, len(claimed))
+	for _, c := range claimed {
+		job := Job{JobID: c.JobID, held: len(c.Entries)}
+		for _, data := range c.Entries {
+			decision, attempts, err := decode(data)
+			if err != nil {
+				// Would fail on every relay, dead-lettered once the others are done with
+				slog.Error("outbox: undecodable decision", "job_id", c.JobID, "err", err)
+				job.undecodable = append(job.undecodable, data)
+				continue
+			}
+			job.Decisions = append(job.Decisions, decision)
+			job.attempts = append(job.attempts, attempts)
+		}
+		jobs = append(jobs, job)
+	}
+
+	return jobs, nil
+}
+
+// Done removes the job's claimed decisions, those held since stay in the outbox
+func (o *Outbox) Done(ctx context.Context, job Job) error {
+	return o.replace(ctx, job, nil, nil, time.Now())
+}
+
+// Retry removes the job's claimed decisions but holds those at failed, indexes into
+// job.Decisions, again behind those held since. The job comes due after delay, doubled
+// for every attempt they failed before. Those that failed MaxAttempts times are dead-lettered.
+// Failed ones are held again first, so a crash in between only relays some twice.
+func (o *Outbox) Retry(ctx context.Context, job Job, failed []int, delay time.Duration) error {
+	var retry []*spec.JobDecision
+	var attempts []int
+	var dead [][]byte
+	for _, i := range failed {
+		n := job.attempts[i] + 1
+		if n >= MaxAttempts {
+			encoded, err := encode(job.Decisions[i], n)
+			if err != nil {
+				return err
+			}
+			dead = append(dead, encoded)
+			continue
+		}
+		retry = append(retry, job.Decisions[i])
+		attempts = append(attempts, n)
+	}
+
+	delay = backoff(delay, slices.Max(append(attempts, 0)))
+	if len(retry) > 0 {
+		if err := o.add(ctx, retry, attempts, delay); err != nil {
+			return err
+		}
+	}
+	return o.replace(ctx, job, nil, dead, time.Now().Add(delay))
+}
+
+// Fail keeps the job's claimed decisions where they are, one attempt further, after a
+// relay could not save them. The job comes due after delay, doubled for every attempt
+// before this one. Decisions that failed MaxAttempts times are dead-lettered.
+func (o *Outbox) Fail(ctx context.Context, job Job, delay time.Duration) error {
+	var keep, dead [][]byte
+	for i, decision := range job.Decisions {
+		n := job.attempts[i] + 1
+		encoded, err := encode(decision, n)
+		if err != nil {
+			return err
+		}
+		if n >= MaxAttempts {
+			dead = append(dead, encoded)
+		} else {
+			keep = append(keep, encoded)
+		}
+	}
+
+	return o.replace(ctx, job, keep, dead, time.Now().Add(backoff(delay, job.Attempts()+1)))
+}
+
+// replace swaps the job's claimed entries for keep and dead-letters dead with the undecodable ones
+func (o *Outbox) replace(ctx context.Context, job Job, keep, dead [][]byte, dueAt time.Time) error {
+	dead = append(dead, job.undecodable...)
+	if err := o.Store.ReplaceInOutbox(ctx, job.JobID, job.held, keep, dead, dueAt); err != nil {
+		return err
+	}
+
+	if len(dead) > 0 {
+		metrics.OutboxRelayed.WithLabelValues("dead_lettered").Add(float64(len(dead)))
+		slog.Error("outbox: dead-lettered decisions", "job_id", job.JobID, "count", len(dead), "max_attempts", MaxAttempts)
+	}
+	return nil
+}
+
+// backoff doubles delay for every attempt after the first, up to maxBackoff
+func backoff(delay time.Duration, attempts int) time.Duration {
+	for ; attempts > 1 && delay < maxBackoff; attempts-- {
+		delay *= 2
+	}
+	return min(delay, maxBackoff)
+}
+
+func encode(decision *spec.JobDecision, attempts int) ([]byte, error) {
+	encoded, err := spec.EncodeDecision(decision)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(entry{Attempts: attempts, Decision: encoded})
+}
+
+// decode also reads bare decisions, held before entries counted attempts
+func decode(data []byte) (*spec.JobDecision, int, error) {
+	var e entry
+	if err := json.Unmarshal(data, &e); err != nil {
+		return nil, 0, err
+	}
+	if len(e.Decision) == 0 {
+		decision, err := spec.DecodeDecision(data)
+		return decision, 0, err
+	}
+
+	decision, err := spec.DecodeDecision(e.Decision)
+	return decision, e.Attempts, err
+}
+
+// Len is the number of jobs with decisions in the outbox
+func (o *Outbox) Len(ctx context.Context) (int64, error) {
+	return o.Store.OutboxLen(ctx)
+}
@@ -0,0 +1,175 @@
+package outbox
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/satyamraj1643/janus/internal/store"
+	"github.com/satyamraj1643/janus/spec"
+)
+
+const deadLettersKey = "janus:outbox:dead_letters"
+
+func newTestOutbox(t *testing.T) (*Outbox, *miniredis.Miniredis) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	return New(store.NewRedisStore(mr.Addr())), mr
+}
+
+func decision(status string) *spec.JobDecision {
+	return &spec.JobDecision{JobID: "job-1", Status: status, Job: spec.Job{ID: "job-1", OwnerID: "owner-a"}}
+}
+
+// claimOne claims the outbox's only due job
+func claimOne(t *testing.T, o *Outbox) Job {
+	t.Helper()
+	jobs, err := o.Claim(context.Background(), 10, time.Minute)
+	if err != nil || len(jobs) != 1 {
+		t.Fatalf("claimed %+v, %v, want one job", jobs, err)
+	}
+	return jobs[0]
+}
+
+func statuses(job Job) []string {
+	var s []string
+	for _, d := range job.Decisions {
+		s = append(s, d.Status)
+	}
+	return s
+}
+
+func TestBackoff(t *testing.T) {
+	for _, tc := range []struct {
+		delay    time.Duration
+		attempts int
+		want     time.Duration
+	}{
+		{time.Second, 0, time.Second},
+		{time.Second, 1, time.Second},
+		{time.Second, 2, 2 * time.Second},
+		{time.Second, 4, 8 * time.Second},
+		{time.Minute, 30, maxBackoff},
+	} {
+		if got := backoff(tc.delay, tc.attempts); got != tc.want {
+			t.Errorf("backoff(%v, %d) = %v, want %v", tc.delay, tc.attempts, got, tc.want)
+		}
+	}
+}
+
+func TestDoneKeepsDecisionsHeldSinceTheClaim(t *testing.T) {
+	ctx := context.Background()
+	o, _ := newTestOutbox(t)
+
+	if err := o.Add(ctx, []*spec.JobDecision{decision("accepted")}, 0); err != nil {
+		t.Fatal(err)
+	}
+	job := claimOne(t, o)
+
+	if held, _ := o.Holding(ctx, []*spec.JobDecision{decision("leased")}); !held["job-1"] {
+		t.Fatal("claimed job is not reported as held")
+	}
+	if err := o.Add(ctx, []*spec.JobDecision{decision("leased")}, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := o.Done(ctx, job); err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := o.Len(ctx); n != 1 {
+		t.Fatalf("Len %d, want the job still held", n)
+	}
+
+	// Done brought the job due again for what was added meanwhile
+	if left := statuses(claimOne(t, o)); len(left) != 1 || left[0] != "leased" {
+		t.Fatalf("held %v, want only leased", left)
+	}
+}
+
+func TestRetryHoldsFailedBehindNewer(t *testing.T) {
+	ctx := context.Background()
+	o, _ := newTestOutbox(t)
+
+	if err := o.Add(ctx, []*spec.JobDecision{decision("accepted"), decision("leased")}, 0); err != nil {
+		t.Fatal(err)
+	}
+	job := claimOne(t, o)
+	if err := o.Add(ctx, []*spec.JobDecision{decision("running")}, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := o.Retry(ctx, job, []int{1}, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	job = claimOne(t, o)
+	if got := statuses(job); len(got) != 2 || got[0] != "running" || got[1] != "leased" {
+		t.Fatalf("held %v, want running then the failed leased", got)
+	}
+	if job.attempts[1] != 1 || job.Attempts() != 0 {
+		t.Fatalf("attempts %v, want only the failed decision counted", job.attempts)
+	}
+}
+
+func TestFailDeadLettersAfterMaxAttempts(t *testing.T) {
+	ctx := context.Background()
+	o, mr := newTestOutbox(t)
+
+	if err := o.Add(ctx, []*spec.JobDecision{decision("accepted")}, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	for attempt := range MaxAttempts {
+		job := claimOne(t, o)
+		if job.Attempts() != attempt {
+			t.Fatalf("claim %d: attempts %d", attempt, job.Attempts())
+		}
+		if err := o.Fail(ctx, job, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if n, _ := o.Len(ctx); n != 0 {
+		t.Fatalf("Len %d, want the job given up on", n)
+	}
+	dead, err := mr.List(deadLettersKey)
+	if err != nil || len(dead) != 1 {
+		t.Fatalf("dead letters %v, %v, want the decision", dead, err)
+	}
+	if d, attempts, err := decode([]byte(dead[0])); err != nil || d.Status != "accepted" || attempts != MaxAttempts {
+		t.Fatalf("dead-lettered %+v after %d attempts, %v", d, attempts, err)
+	}
+}
+
+func TestClaimReadsBareAndUndecodableEntries(t *testing.T) {
+	ctx := context.Background()
+	o, mr := newTestOutbox(t)
+
+	bare, err := spec.EncodeDecision(decision("accepted"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = o.Store.AddToOutbox(ctx, []store.OutboxEntry{
+		{JobID: "job-1", Entry: bare},
+		{JobID: "job-1", Entry: []byte("not json")},
+	}, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	job := claimOne(t, o)
+	if got := statuses(job); len(got) != 1 || got[0] != "accepted" || job.Attempts() != 0 {
+		t.Fatalf("decoded %v with %d attempts, want the bare decision", got, job.Attempts())
+	}
+
+	if err := o.Done(ctx, job); err != nil {
+		t.Fatal(err)
+	}
+	if dead, _ := mr.List(deadLettersKey); len(dead) != 1 || dead[0] != "not json" {
+		t.Fatalf("dead letters %v, want the undecodable entry", dead)
+	}
+	if n, _ := o.Len(ctx); n != 0 {
+		t.Fatalf("Len %d after Done", n)
+	}
+}
@@ -0,0 +1,70 @@
+package policy
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// Change is one difference between two configs. Path is a JSON pointer (RFC 6901) into them.
+type Change struct {
+	Path string `json:"path"`
+	Op   string `json:"op"` // added | removed | changed
+	From any    `json:"from,omitempty"`
+	To   any    `json:"to,omitempty"`
+}
+
+// Diff compares two raw configs field by field, sorted by path. Arrays are compared whole.
+func Diff(from, to json.RawMessage) ([]Change, error) {
+	var a, b any
+	if err := json.Unmarshal(from, &a); err != nil {
+		return nil, fmt.Errorf("invalid config JSON: %w", err)
+	}
+	if err := json.Unmarshal(to, &b); err != nil {
+		return nil, fmt.Errorf("invalid config JSON: %w", err)
+	}
+
+	changes := []Change{}
+	diff("", a, b, &changes)
+	return changes, nil
+}
+
+func diff(path string, a, b any, changes *[]Change) {
+	am, aObj := a.(map[string]any)
+	bm, bObj := b.(map[string]any)
+	if !aObj || !bObj {
+		if !reflect.DeepEqual(a, b) {
+			*changes = append(*changes, Change{Path: path, Op: "changed", From: a, To: b})
+		}
+		return
+	}
+
+	keys := slices.Sorted(maps.Keys(am))
+	for k := range bm {
+		if _, ok := am[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	slices.Sort(keys)
+
+	for _, k := range keys {
+		av, inA := am[k]
+		bv, inB := bm[k]
+		p := path + "/" + pointerEscaper.Replace(k)
+
+		switch {
+		case !inA:
+			*changes = append(*changes, Change{Path: p, Op: "added", To: bv})
+		case !inB:
+			*changes = append(*changes, Change{Path: p, Op: "removed", From: av})
+		default:
+			diff(p, av, bv, changes)
+		}
+	}
+}
+
+// pointerEscaper escapes a key for a JSON pointer, ~ as ~0 and / as ~1
+var pointerEscaper = strings.NewReplacer("~", "~0", "/", "~1")
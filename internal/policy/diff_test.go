@@ -0,0 +1,46 @@
+package policy
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	from := json.RawMessage(`{
+		"global_execution_limit": {"max_jobs": 10, "window_ms": 1000},
+		"dependencies": {"payment/api": {"concurrent": 2}, "email_api": {"concurrent": 1}},
+		"tags": ["a", "b"]
+	}`)
+	to := json.RawMessage(`{
+		"global_execution_limit": {"max_jobs": 20, "window_ms": 1000},
+		"dependencies": {"payment/api": {"concurrent": 2}, "sms~api": {"concurrent": 1}},
+		"tags": ["a", "c"]
+	}`)
+
+	changes, err := Diff(from, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []Change{
+		{Path: "/dependencies/email_api", Op: "removed", From: map[string]any{"concurrent": 1.0}},
+		{Path: "/dependencies/sms~0api", Op: "added", To: map[string]any{"concurrent": 1.0}},
+		{Path: "/global_execution_limit/max_jobs", Op: "changed", From: 10.0, To: 20.0},
+		{Path: "/tags", Op: "changed", From: []any{"a", "b"}, To: []any{"a", "c"}},
+	}
+	if !reflect.DeepEqual(changes, want) {
+		t.Fatalf("got %+v\nwant %+v", changes, want)
+	}
+}
+
+func TestDiffOfEqualConfigs(t *testing.T) {
+	changes, err := Diff(json.RawMessage(`{"a": {"b": 1}}`), json.RawMessage(`{ "a": { "b": 1 } }`))
+	if err != nil || changes == nil || len(changes) != 0 {
+		t.Fatalf("got %v, %v, want an empty list", changes, err)
+	}
+
+	if _, err := Diff(json.RawMessage(`{`), json.RawMessage(`{}`)); err == nil {
+		t.Fatal("diffed invalid JSON")
+	}
+}
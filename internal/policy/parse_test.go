@@ -0,0 +1,56 @@
+package policy
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+const validConfig = `{
+	"version": 1,
+	"global_execution_limit": {"max_jobs": 10, "window_ms": 1000},
+	"default_job_policy": {"idempotency_window_ms": 1000}
+}`
+
+func TestParseConfig(t *testing.T) {
+	p, err := ParseConfig(json.RawMessage(validConfig))
+	if err != nil || p.Validate() != nil {
+		t.Fatalf("valid config: %v, %v", err, p.Validate())
+	}
+
+	for name, raw := range map[string]string{
+		"empty":          ``,
+		"not JSON":       `{"version":`,
+		"no max_jobs":    `{"global_execution_limit": {"window_ms": 1000}, "default_job_policy": {"idempotency_window_ms": 1000}}`,
+		"no window":      `{"global_execution_limit": {"max_jobs": 10}, "default_job_policy": {"idempotency_window_ms": 1000}}`,
+		"no idempotency": `{"global_execution_limit": {"max_jobs": 10, "window_ms": 1000}}`,
+	} {
+		if _, err := ParseConfig(json.RawMessage(raw)); err == nil {
+			t.Errorf("%s: parsed", name)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for name, edit := range map[string]func(p *Policy){
+		"unknown version": func(p *Policy) { p.Version = 2 },
+		"dependency without limits": func(p *Policy) {
+			p.Dependencies = map[string]DependencyPolicy{"payment_api": {}}
+		},
+		"negative tenant limit": func(p *Policy) { p.GlobalExecutionLimit.MaxConcurrentPerTenant = -1 },
+		"unknown backoff":       func(p *Policy) { p.DefaultJobPolicy.Retry.Backoff = "linear" },
+		"max lease below timeout": func(p *Policy) {
+			p.DefaultJobPolicy.Execution.TimeoutMs = 10000
+			p.DefaultJobPolicy.Execution.MaxLeaseMs = 5000
+		},
+		"defer without max wait": func(p *Policy) { p.DefaultJobPolicy.Defer = &DeferPolicy{} },
+	} {
+		p, err := ParseConfig(json.RawMessage(validConfig))
+		if err != nil {
+			t.Fatal(err)
+		}
+		edit(p)
+		if err := p.Validate(); err == nil {
+			t.Errorf("%s: validated", name)
+		}
+	}
+}
@@ -43,6 +43,26 @@ type JobPolicy struct {
 	Retry               RetryPolicy       `json:"retry"`
 	Execution           ExecutionPolicy   `json:"execution"`
 	Quarantine          *QuarantinePolicy `json:"quarantine,omitempty"` // : Poison Pill Protection
+	Defer               *DeferPolicy      `json:"defer,omitempty"`      // : Hold rate limited jobs instead of rejecting
+}
+
+// DeferPolicy holds jobs rejected only by rate limits in a wait queue,
+// re-evaluating them as tokens refill until MaxWaitMs has passed.
+// Waiting jobs are released per tenant by weighted deficit round robin.
+type DeferPolicy struct {
+	MaxWaitMs     int64          `json:"max_wait_ms"`              // : Expire the job after waiting this long
+	TenantWeights map[string]int `json:"tenant_weights,omitempty"` // : Share of released capacity, default 1
+	AgingMs       int64          `json:"aging_ms,omitempty"`       // : Head start per priority point, default 1000
+}
+
+const DefaultDeferAgingMs = 1000
+
+// Weight returns the fair share weight of a tenant
+func (d *DeferPolicy) Weight(tenantID string) int {
+	if w, ok := d.TenantWeights[tenantID]; ok && w > 0 {
+		return w
+	}
+	return 1
 }
 
 type QuarantinePolicy struct {
@@ -58,5 +78,51 @@ type RetryPolicy struct {
 }
 
 type ExecutionPolicy struct {
-	TimeoutMs int `json:"timeout_ms"`
+	TimeoutMs   int   `json:"timeout_ms"`
+	MaxLeaseMs  int64 `json:"max_lease_ms,omitempty"`  // : Longest heartbeats can hold a lease, 0 = no limit
+	Dispatch    bool  `json:"dispatch"`                // : Queue admitted jobs for workers to lease from Janus
+	PermitTTLMs int64 `json:"permit_ttl_ms,omitempty"` // : How long an acceptance permit stays valid, default 1h
+}
+
+// DefaultLeaseTimeoutMs applies when execution.timeout_ms is not set
+const DefaultLeaseTimeoutMs = 30000
+
+// LeaseTimeout is how long a worker holds a leased job before it is reclaimed
+func (e ExecutionPolicy) LeaseTimeout() time.Duration {
+	if e.TimeoutMs <= 0 {
+		return DefaultLeaseTimeoutMs * time.Millisecond
+	}
+	return time.Duration(e.TimeoutMs) * time.Millisecond
+}
+
+// DefaultPermitTTLMs applies when execution.permit_ttl_ms is not set
+const DefaultPermitTTLMs = 60 * 60 * 1000
+
+// PermitTTL is how long after acceptance a job's permit can be used
+func (e ExecutionPolicy) PermitTTL() time.Duration {
+	if e.PermitTTLMs <= 0 {
+		return DefaultPermitTTLMs * time.Millisecond
+	}
+	return time.Duration(e.PermitTTLMs) * time.Millisecond
+}
+
+// MaxLease bounds how long heartbeats can keep a lease alive, 0 means no bound
+func (e ExecutionPolicy) MaxLease() time.Duration {
+	return time.Duration(e.MaxLeaseMs) * time.Millisecond
+}
+
+// Attempts is the total number of executions allowed, the first one included
+func (r RetryPolicy) Attempts() int {
+	return max(r.MaxAttempts, 1)
+}
+
+// Delay is the wait before retrying after the given failed attempt (1-based)
+func (r RetryPolicy) Delay(attempt int) time.Duration {
+	delay := time.Duration(r.InitialDelayMs) * time.Millisecond
+	if r.Backoff == "exponential" {
+		for i := 1; i < attempt && delay < time.Hour; i++ {
+			delay *= 2
+		}
+	}
+	return min(delay, time.Hour)
 }
@@ -36,5 +36,43 @@ func (p *Policy) Validate() error {
 		}
 	}
 
+	retry := p.DefaultJobPolicy.Retry
+	if retry.MaxAttempts < 0 || retry.InitialDelayMs < 0 {
+		return fmt.Errorf("default_job_policy retry max_attempts and initial_delay_ms cannot be negative")
+	}
+	if retry.Backoff != "" && retry.Backoff != "fixed" && retry.Backoff != "exponential" {
+		return fmt.Errorf("default_job_policy retry backoff must be fixed or exponential")
+	}
+
+	if p.DefaultJobPolicy.Execution.TimeoutMs < 0 {
+		return fmt.Errorf("default_job_policy execution timeout_ms cannot be negative")
+	}
+	if maxLease := p.DefaultJobPolicy.Execution.MaxLease(); maxLease < 0 || (maxLease > 0 && maxLease < p.DefaultJobPolicy.Execution.LeaseTimeout()) {
+		return fmt.Errorf("default_job_policy execution max_lease_ms must be 0 or at least timeout_ms")
+	}
+	if p.DefaultJobPolicy.Execution.PermitTTLMs < 0 {
+		return fmt.Errorf("default_job_policy execution permit_ttl_ms cannot be negative")
+	}
+
+	if p.DefaultJobPolicy.Defer != nil {
+		if p.DefaultJobPolicy.Defer.MaxWaitMs <= 0 {
+			return fmt.Errorf("default_job_policy defer max_wait_ms must be > 0")
+		}
+		if p.DefaultJobPolicy.Defer.MaxWaitMs > MaxDeferWaitMs {
+			return fmt.Errorf("default_job_policy defer max_wait_ms cannot exceed %d", MaxDeferWaitMs)
+		}
+		if p.DefaultJobPolicy.Defer.AgingMs < 0 {
+			return fmt.Errorf("default_job_policy defer aging_ms cannot be negative")
+		}
+		for tenant, weight := range p.DefaultJobPolicy.Defer.TenantWeights {
+			if weight <= 0 {
+				return fmt.Errorf("default_job_policy defer tenant_weights '%s' must be > 0", tenant)
+			}
+		}
+	}
+
 	return nil
 }
+
+// MaxDeferWaitMs bounds how long a deferred job may wait for capacity (24h)
+const MaxDeferWaitMs = 24 * 60 * 60 * 1000
@@ -0,0 +1,128 @@
+-- Ordered batch admission in a single round trip.
+-- Each job is checked in order against the buckets as left by the jobs before it.
+-- A job that fits is committed and its idempotency record written. One that does not is
+-- skipped, or with stop_on_reject ends the batch so only the in-order prefix is admitted.
+
+-- KEYS: per job [idem_key, tokens_key_1, ts_key_1, created_key_1, tokens_key_2, ...]
+-- ARGV: [now, job_count, stop_on_reject,
+--        per job: idem_window_ms (0: no expiry), idem_record, req_count,
+--                 per req: cap, rate, cost, min_int, warmup]
+-- Returns: [bitmap, prior_1, prior_2, ...]
+--   bitmap has one char per job: 1 admitted, 0 quota exceeded, 2 duplicate, 3 not evaluated
+--   prior_n is the stored idempotency value of the nth duplicate
+
+local now_time = tonumber(ARGV[1])
+local job_count = tonumber(ARGV[2])
+local stop_on_reject = tonumber(ARGV[3]) == 1
+
+local key_idx = 1
+local arg_idx = 4
+local stopped = false
+
+local bitmap = {}
+local priors = {}
+
+for j = 1, job_count do
+    local idem_key = KEYS[key_idx]
+    local idem_window = tonumber(ARGV[arg_idx])
+    local idem_record = ARGV[arg_idx + 1]
+    local req_count = tonumber(ARGV[arg_idx + 2])
+
+    local first_req_key = key_idx + 1
+    local first_req_arg = arg_idx + 3
+
+    -- Advance past this job before any early decision
+    key_idx = first_req_key + (req_count * 3)
+    arg_idx = first_req_arg + (req_count * 5)
+
+    if stopped then
+        bitmap[j] = "3"
+    elseif redis.call("exists", idem_key) == 1 then
+        bitmap[j] = "2"
+        priors[#priors + 1] = redis.call("get", idem_key)
+    else
+        --1. CHECK PHASE (same rules as atomic_token_bucket.lua)
+        local allowed = true
+        local new_token_list = {}
+
+        for i = 0, req_count - 1 do
+            local base_key = first_req_key + (i * 3)
+            local base_arg = first_req_arg + (i * 5)
+
+            local tokens_key = KEYS[base_key]
+            local ts_key = KEYS[base_key + 1]
+            local created_key = KEYS[base_key + 2]
+
+            local capacity = tonumber(ARGV[base_arg])
+            local refill_rate = tonumber(ARGV[base_arg + 1])
+            local cost = tonumber(ARGV[base_arg + 2])
+            local min_interval = tonumber(ARGV[base_arg + 3])
+            local warmup_ms = tonumber(ARGV[base_arg + 4])
+
+            local created_at = tonumber(redis.call("get", created_key))
+            if created_at == nil then
+                created_at = now_time
+                redis.call("set", created_key, now_time)
+            end
+
+            local effective_capacity = capacity
+            local effective_rate = refill_rate
+
+            if warmup_ms > 0 then
+                local age = now_time - created_at
+                local warmup_sec = warmup_ms / 1000.0
+                if age < warmup_sec then
+                    local factor = 0.1 + (0.9 * (age / warmup_sec))
+                    effective_capacity = capacity * factor
+                    effective_rate = refill_rate * factor
+                end
+            end
+
+            local last_tokens = tonumber(redis.call("get", tokens_key))
+            if last_tokens == nil then
+                last_tokens = effective_capacity
+            end
+
+            local last_ts = tonumber(redis.call("get", ts_key))
+            if last_ts == nil then
+                last_ts = 0
+            end
+
+            local delta = math.max(0, now_time - last_ts)
+            local filled = math.min(effective_capacity, last_tokens + (delta * effective_rate))
+
+            if delta < min_interval or filled < cost then
+                allowed = false
+                break
+            end
+
+            new_token_list[i + 1] = filled - cost
+        end
+
+        --2. COMMIT PHASE
+        if allowed then
+            for i = 0, req_count - 1 do
+                local base_key = first_req_key + (i * 3)
+                redis.call("set", KEYS[base_key], new_token_list[i + 1])
+                redis.call("set", KEYS[base_key + 1], now_time)
+            end
+            -- A window of 0 keeps the record forever, as CheckAndMarkAdmitted does
+            if idem_window > 0 then
+                redis.call("set", idem_key, idem_record, "PX", idem_window)
+            else
+                redis.call("set", idem_key, idem_record)
+            end
+            bitmap[j] = "1"
+        else
+            bitmap[j] = "0"
+            stopped = stop_on_reject
+        end
+    end
+end
+
+local result = { table.concat(bitmap) }
+for _, prior in ipairs(priors) do
+    result[#result + 1] = prior
+end
+
+return result
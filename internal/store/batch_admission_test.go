@@ -0,0 +1,65 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// batchJob is a batch job costing one token of a bucket holding capacity
+func batchJob(key string, window time.Duration, capacity int) BatchAdmissionReq {
+	return BatchAdmissionReq{
+		IdempotencyKey:    key,
+		IdempotencyWindow: window,
+		Record:            []byte(`{"job_id":"` + key + `"}`),
+		Reqs:              []RateLimitReq{{Key: "owner:global", Capacity: capacity, RefillRate: 0.001, Cost: 1}},
+	}
+}
+
+func TestAllowBatchPartial(t *testing.T) {
+	ctx := context.Background()
+	s, mr := newTestStore(t)
+
+	res, err := s.AllowBatchPartial(ctx, []BatchAdmissionReq{
+		batchJob("owner:job-1", time.Minute, 2),
+		batchJob("owner:job-2", time.Minute, 2),
+		batchJob("owner:job-3", time.Minute, 2),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res[0].Admitted || !res[1].Admitted || res[2].Admitted || res[2].Skipped {
+		t.Fatalf("got %+v, want the first two admitted and the third over quota", res)
+	}
+
+	if got, _ := mr.Get(idempotencyRedisKey("owner:job-1")); got != `{"job_id":"owner:job-1"}` {
+		t.Fatalf("admitted job's record %q", got)
+	}
+	if mr.Exists(idempotencyRedisKey("owner:job-3")) {
+		t.Fatal("rejected job kept its idempotency key")
+	}
+
+	res, err = s.AllowBatchPartial(ctx, []BatchAdmissionReq{batchJob("owner:job-1", time.Minute, 2)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res[0].Duplicate || string(res[0].Prior) != `{"job_id":"owner:job-1"}` {
+		t.Fatalf("resubmission got %+v, want a duplicate with the stored record", res[0])
+	}
+}
+
+func TestAllowBatchWithoutIdempotencyWindow(t *testing.T) {
+	ctx := context.Background()
+	s, mr := newTestStore(t)
+
+	res, err := s.AllowBatchPartial(ctx, []BatchAdmissionReq{batchJob("owner:job-1", 0, 5)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res[0].Admitted {
+		t.Fatalf("got %+v, want admitted", res[0])
+	}
+	if ttl := mr.TTL(idempotencyRedisKey("owner:job-1")); ttl != 0 {
+		t.Fatalf("record TTL %v, want none", ttl)
+	}
+}
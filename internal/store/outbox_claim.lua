@@ -0,0 +1,27 @@
+-- Claims due outbox jobs without removing them.
+-- A claim pushes the job's due time to the claim deadline, so a crashed relay's jobs come due again.
+
+-- KEYS: [outbox_zset]
+-- ARGV: [now_ms, limit, claim_until_ms, entries_key_prefix]
+-- Returns: [job_id_1, entry_count_1, entry_1_1, entry_1_2, ..., job_id_2, ...]
+
+local due = redis.call("zrangebyscore", KEYS[1], "-inf", ARGV[1], "LIMIT", 0, tonumber(ARGV[2]))
+
+local result = {}
+
+for _, id in ipairs(due) do
+    local entries = redis.call("lrange", ARGV[4] .. id, 0, -1)
+    if #entries > 0 then
+        redis.call("zadd", KEYS[1], ARGV[3], id)
+        result[#result + 1] = id
+        result[#result + 1] = tostring(#entries)
+        for _, entry in ipairs(entries) do
+            result[#result + 1] = entry
+        end
+    else
+        -- Entries already removed, drop the orphaned index
+        redis.call("zrem", KEYS[1], id)
+    end
+end
+
+return result
@@ -0,0 +1,30 @@
+-- Drops the first entries of an outbox job, and the job once it has none left.
+-- The keep entries are put back in their place, the dead ones move to the capped dead letter list.
+-- Entries appended since the claim are kept and the job comes due again for them at due_ms.
+
+-- KEYS: [outbox_zset, entries_list, dead_letters_list]
+-- ARGV: [job_id, count, due_ms, keep_count, dead_letters_max, keep_1, ..., keep_k, dead_1, ...]
+-- Returns: entries left
+
+redis.call("ltrim", KEYS[2], tonumber(ARGV[2]), -1)
+
+local keep = tonumber(ARGV[4])
+for i = 5 + keep, 6, -1 do
+    redis.call("lpush", KEYS[2], ARGV[i])
+end
+
+if #ARGV >= 6 + keep then
+    for i = 6 + keep, #ARGV do
+        redis.call("lpush", KEYS[3], ARGV[i])
+    end
+    redis.call("ltrim", KEYS[3], 0, tonumber(ARGV[5]) - 1)
+end
+
+local left = redis.call("llen", KEYS[2])
+if left == 0 then
+    redis.call("zrem", KEYS[1], ARGV[1])
+else
+    redis.call("zadd", KEYS[1], "XX", ARGV[3], ARGV[1])
+end
+
+return left
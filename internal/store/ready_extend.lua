@@ -0,0 +1,33 @@
+-- Extends a live lease to now + extend_ms, at most its ttl and never past its max end.
+-- A lease is never shortened. The first heartbeat of a lease marks its job as started.
+
+-- KEYS: [leases_zset, jobs_hash]
+-- ARGV: [lease_id, now_ms, extend_ms (0 = ttl)]
+-- Returns: [deadline (ms), 1 on the first heartbeat else 0, max end (ms, 0 = none)], or [-1, 0, 0] if the lease is gone
+
+local lease_id = ARGV[1]
+
+local current = tonumber(redis.call("zscore", KEYS[1], lease_id))
+if not current then
+    return { -1, 0, 0 }
+end
+
+local ttl_ms = tonumber(redis.call("hget", KEYS[2], "ttl:" .. lease_id)) or 0
+local extend_ms = tonumber(ARGV[3])
+if extend_ms <= 0 or extend_ms > ttl_ms then
+    extend_ms = ttl_ms
+end
+
+local deadline = tonumber(ARGV[2]) + extend_ms
+
+local max_end = tonumber(redis.call("hget", KEYS[2], "maxend:" .. lease_id)) or 0
+if max_end > 0 and deadline > max_end then
+    deadline = max_end
+end
+
+deadline = math.max(deadline, current)
+redis.call("zadd", KEYS[1], deadline, lease_id)
+
+local first = redis.call("hsetnx", KEYS[2], "started:" .. lease_id, 1)
+
+return { deadline, first, max_end }
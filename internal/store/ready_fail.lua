@@ -0,0 +1,36 @@
+-- Ends a live lease of a failed job in one step: the attempt is appended to the job's
+-- history, then the job is put back for a retry or, with available_at_ms 0, removed.
+-- Nothing changes if the lease expired, finished or no longer holds job_id, so a
+-- failure racing the reaper or another report is applied once.
+
+-- KEYS: [ready_zset, leases_zset, jobs_hash, lease_owners_set, attempts_list]
+-- ARGV: [owner, lease_id, job_id, available_at_ms (0 = remove), attempt_entry, attempts_ttl_ms]
+-- Returns: [job_id, attempt, job] or an empty table if the lease is gone
+
+local lease_id = ARGV[2]
+local id = ARGV[3]
+
+if redis.call("hget", KEYS[3], "lease:" .. lease_id) ~= id or not redis.call("zscore", KEYS[2], lease_id) then
+    return {}
+end
+
+redis.call("zrem", KEYS[2], lease_id)
+redis.call("hdel", KEYS[3], "lease:" .. lease_id, "ttl:" .. lease_id, "started:" .. lease_id, "maxend:" .. lease_id, "leaseof:" .. id)
+
+redis.call("rpush", KEYS[5], ARGV[5])
+redis.call("pexpire", KEYS[5], ARGV[6])
+
+local attempt = tonumber(redis.call("hget", KEYS[3], "attempts:" .. id)) or 0
+local job = redis.call("hget", KEYS[3], "job:" .. id)
+
+if tonumber(ARGV[4]) > 0 then
+    redis.call("zadd", KEYS[1], ARGV[4], id)
+else
+    redis.call("hdel", KEYS[3], "job:" .. id, "tenant:" .. id, "deps:" .. id, "max:" .. id, "attempts:" .. id)
+end
+
+if redis.call("zcard", KEYS[2]) == 0 then
+    redis.call("srem", KEYS[4], ARGV[1])
+end
+
+return { id, attempt, job }
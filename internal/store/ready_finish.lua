@@ -0,0 +1,26 @@
+-- Ends a live lease and removes its job from the queue.
+
+-- KEYS: [ready_zset, leases_zset, jobs_hash, lease_owners_set]
+-- ARGV: [owner, lease_id]
+-- Returns: [job_id, attempt, job] or an empty table if the lease is gone
+
+local lease_id = ARGV[2]
+
+local id = redis.call("hget", KEYS[3], "lease:" .. lease_id)
+if not id or not redis.call("zscore", KEYS[2], lease_id) then
+    return {}
+end
+
+redis.call("zrem", KEYS[2], lease_id)
+redis.call("hdel", KEYS[3], "lease:" .. lease_id, "ttl:" .. lease_id, "started:" .. lease_id, "maxend:" .. lease_id, "leaseof:" .. id)
+
+local attempt = tonumber(redis.call("hget", KEYS[3], "attempts:" .. id)) or 0
+local job = redis.call("hget", KEYS[3], "job:" .. id)
+
+redis.call("hdel", KEYS[3], "job:" .. id, "tenant:" .. id, "deps:" .. id, "max:" .. id, "attempts:" .. id)
+
+if redis.call("zcard", KEYS[2]) == 0 then
+    redis.call("srem", KEYS[4], ARGV[1])
+end
+
+return { id, attempt, job }
@@ -0,0 +1,67 @@
+-- Leases available jobs from an owner's ready queue, oldest first.
+
+-- KEYS: [ready_zset, leases_zset, jobs_hash, lease_owners_set]
+-- ARGV: [owner, now_ms, ttl_ms, max_lease_ms (0 = none), tenant_filter, dependency_filter, scan_limit, lease_id_1, lease_id_2, ...]
+-- Returns: [lease_id_1, job_id_1, attempt_1, job_1, lease_id_2, ...]
+--
+-- jobs_hash fields per job: job:<id>, tenant:<id>, deps:<id> (",a,b,"), max:<id>, attempts:<id>, leaseof:<id>
+-- and per lease: lease:<lease_id> -> job id, ttl:<lease_id>, maxend:<lease_id> if bounded, started:<lease_id> once heartbeated
+
+local owner = ARGV[1]
+local now_ms = tonumber(ARGV[2])
+local ttl_ms = tonumber(ARGV[3])
+local max_lease_ms = tonumber(ARGV[4])
+local tenant_filter = ARGV[5]
+local dependency_filter = ARGV[6]
+local max_leases = #ARGV - 7
+
+local candidates = redis.call("zrangebyscore", KEYS[1], "-inf", now_ms, "LIMIT", 0, tonumber(ARGV[7]))
+
+local result = {}
+local leased = 0
+
+for _, id in ipairs(candidates) do
+    if leased >= max_leases then
+        break
+    end
+
+    local match = true
+    if tenant_filter ~= "" and redis.call("hget", KEYS[3], "tenant:" .. id) ~= tenant_filter then
+        match = false
+    end
+    if match and dependency_filter ~= "" then
+        local deps = redis.call("hget", KEYS[3], "deps:" .. id) or ""
+        if not string.find(deps, "," .. dependency_filter .. ",", 1, true) then
+            match = false
+        end
+    end
+
+    if match then
+        leased = leased + 1
+        local lease_id = ARGV[7 + leased]
+
+        local deadline = now_ms + ttl_ms
+        if max_lease_ms > 0 then
+            deadline = math.min(deadline, now_ms + max_lease_ms)
+        end
+
+        redis.call("zrem", KEYS[1], id)
+        redis.call("zadd", KEYS[2], deadline, lease_id)
+        redis.call("hset", KEYS[3], "lease:" .. lease_id, id, "ttl:" .. lease_id, ttl_ms, "leaseof:" .. id, lease_id)
+        if max_lease_ms > 0 then
+            redis.call("hset", KEYS[3], "maxend:" .. lease_id, now_ms + max_lease_ms)
+        end
+        local attempt = redis.call("hincrby", KEYS[3], "attempts:" .. id, 1)
+
+        result[#result + 1] = lease_id
+        result[#result + 1] = id
+        result[#result + 1] = attempt
+        result[#result + 1] = redis.call("hget", KEYS[3], "job:" .. id)
+    end
+end
+
+if leased > 0 then
+    redis.call("sadd", KEYS[4], owner)
+end
+
+return result
@@ -0,0 +1,48 @@
+-- Reclaims leases whose deadline passed without a heartbeat.
+-- Jobs with attempts left go back to the ready queue, leasable from hold_until_ms so the
+-- caller can apply the job's backoff first, the rest are removed and reported dead.
+
+-- KEYS: [ready_zset, leases_zset, jobs_hash, lease_owners_set]
+-- ARGV: [owner, now_ms, limit, hold_until_ms]
+-- Returns: [lease_id_1, job_id_1, attempt_1, dead_1, job_1, lease_id_2, ...]
+
+local now_ms = tonumber(ARGV[2])
+
+local expired = redis.call("zrangebyscore", KEYS[2], "-inf", now_ms, "LIMIT", 0, tonumber(ARGV[3]))
+
+local result = {}
+
+for _, lease_id in ipairs(expired) do
+    local id = redis.call("hget", KEYS[3], "lease:" .. lease_id)
+
+    redis.call("zrem", KEYS[2], lease_id)
+    redis.call("hdel", KEYS[3], "lease:" .. lease_id, "ttl:" .. lease_id, "started:" .. lease_id, "maxend:" .. lease_id)
+
+    if id then
+        redis.call("hdel", KEYS[3], "leaseof:" .. id)
+
+        local attempt = tonumber(redis.call("hget", KEYS[3], "attempts:" .. id)) or 0
+        local max_attempts = tonumber(redis.call("hget", KEYS[3], "max:" .. id)) or 1
+        local job = redis.call("hget", KEYS[3], "job:" .. id)
+        local dead = 0
+
+        if attempt < max_attempts then
+            redis.call("zadd", KEYS[1], ARGV[4], id)
+        else
+            dead = 1
+            redis.call("hdel", KEYS[3], "job:" .. id, "tenant:" .. id, "deps:" .. id, "max:" .. id, "attempts:" .. id)
+        end
+
+        result[#result + 1] = lease_id
+        result[#result + 1] = id
+        result[#result + 1] = attempt
+        result[#result + 1] = dead
+        result[#result + 1] = job
+    end
+end
+
+if redis.call("zcard", KEYS[2]) == 0 then
+    redis.call("srem", KEYS[4], ARGV[1])
+end
+
+return result
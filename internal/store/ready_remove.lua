@@ -0,0 +1,31 @@
+-- Takes a job out of an owner's ready queue, ending its lease if it holds one.
+
+-- KEYS: [ready_zset, leases_zset, jobs_hash, lease_owners_set]
+-- ARGV: [owner, job_id]
+-- Returns: [lease_id ("" if not leased), attempts, job] or an empty table if the job is not queued
+
+local id = ARGV[2]
+
+local job = redis.call("hget", KEYS[3], "job:" .. id)
+if not job then
+    return {}
+end
+
+local lease_id = redis.call("hget", KEYS[3], "leaseof:" .. id)
+if lease_id then
+    redis.call("zrem", KEYS[2], lease_id)
+    redis.call("hdel", KEYS[3], "lease:" .. lease_id, "ttl:" .. lease_id, "started:" .. lease_id, "maxend:" .. lease_id)
+else
+    lease_id = ""
+end
+
+local attempts = tonumber(redis.call("hget", KEYS[3], "attempts:" .. id)) or 0
+
+redis.call("zrem", KEYS[1], id)
+redis.call("hdel", KEYS[3], "job:" .. id, "tenant:" .. id, "deps:" .. id, "max:" .. id, "attempts:" .. id, "leaseof:" .. id)
+
+if redis.call("zcard", KEYS[2]) == 0 then
+    redis.call("srem", KEYS[4], ARGV[1])
+end
+
+return { lease_id, attempts, job }
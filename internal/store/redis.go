@@ -3,10 +3,17 @@ package store
 import (
 	"context"
 	_ "embed"
+	"errors"
 	"fmt"
+	"maps"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/redis/go-redis/v9"
+	"github.com/satyamraj1643/janus/internal/metrics"
+	"github.com/satyamraj1643/janus/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // -- Embedding Lua Scripts Start --
@@ -23,6 +30,36 @@ var atomicTokenBucketScript = redis.NewScript(atomicTokenBucketScriptContent)
 var burstSmoothingScriptContent string
 var burstSmoothingScript = redis.NewScript(burstSmoothingScriptContent)
 
+//go:embed batch_admission.lua
+var batchAdmissionScriptContent string
+var batchAdmissionScript = redis.NewScript(batchAdmissionScriptContent)
+
+//go:embed refund_tokens.lua
+var refundTokensScriptContent string
+var refundTokensScript = redis.NewScript(refundTokensScriptContent)
+
+// scripts are every Lua script RedisStore runs, by name
+var scripts = map[string]*redis.Script{
+	"tenant_starvation":   tenantStarvationScript,
+	"atomic_token_bucket": atomicTokenBucketScript,
+	"burst_smoothing":     burstSmoothingScript,
+	"batch_admission":     batchAdmissionScript,
+	"refund_tokens":       refundTokensScript,
+	"ready_lease":         readyLeaseScript,
+	"ready_extend":        readyExtendScript,
+	"ready_finish":        readyFinishScript,
+	"ready_fail":          readyFailScript,
+	"ready_reap":          readyReapScript,
+	"ready_remove":        readyRemoveScript,
+	"schedule_claim":      scheduleClaimScript,
+	"schedule_remove":     scheduleRemoveScript,
+	"outbox_claim":        outboxClaimScript,
+	"outbox_remove":       outboxRemoveScript,
+	"wait_defer":          waitDeferScript,
+	"wait_claim":          waitClaimScript,
+	"wait_release":        waitReleaseScript,
+}
+
 type RedisStore struct {
 	client *redis.Client
 }
@@ -36,23 +73,92 @@ func NewRedisStore(addr string) *RedisStore {
 	}
 }
 
+// run executes a Lua script in its own span and records its latency and failures under name.
+// redis.Nil is a normal empty reply and not counted as an error.
+func (r *RedisStore) run(ctx context.Context, name string, script *redis.Script, keys []string, args ...any) *redis.Cmd {
+	ctx, span := tracing.Start(ctx, "redis.script "+name,
+		attribute.String("db.system", "redis"),
+		attribute.Int("janus.script.keys", len(keys)),
+	)
+
+	start := time.Now()
+	cmd := script.Run(ctx, r.client, keys, args...)
+	metrics.Since(metrics.ScriptDuration.WithLabelValues(name), start)
+
+	err := cmd.Err()
+	if errors.Is(err, redis.Nil) {
+		err = nil
+	}
+	if err != nil {
+		metrics.ScriptErrors.WithLabelValues(name).Inc()
+	}
+	tracing.End(span, err)
+
+	return cmd
+}
+
 func (r *RedisStore) Ping(ctx context.Context) error {
 	return r.client.Ping(ctx).Err()
 }
 
+// LoadScripts makes sure Redis has every Lua script cached, loading those it lost
+// (after a restart or SCRIPT FLUSH). Returns how many scripts there are and how many were missing.
+func (r *RedisStore) LoadScripts(ctx context.Context) (total, missing int, err error) {
+	names, err := r.MissingScripts(ctx)
+	if err != nil {
+		return len(scripts), 0, err
+	}
+
+	for _, name := range names {
+		if err := scripts[name].Load(ctx, r.client).Err(); err != nil {
+			return len(scripts), len(names), fmt.Errorf("loading script %s: %w", name, err)
+		}
+	}
+
+	return len(scripts), len(names), nil
+}
+
+// MissingScripts returns the Lua scripts Redis has not cached, without loading them.
+// Scripts run fine either way, a missing one is sent in full on its next call.
+func (r *RedisStore) MissingScripts(ctx context.Context) ([]string, error) {
+	names := slices.Sorted(maps.Keys(scripts))
+	hashes := make([]string, len(names))
+	for i, name := range names {
+		hashes[i] = scripts[name].Hash()
+	}
+
+	exists, err := r.client.ScriptExists(ctx, hashes...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	var missing []string
+	for i, ok := range exists {
+		if !ok {
+			missing = append(missing, names[i])
+		}
+	}
+	return missing, nil
+}
+
 // Standalone Idempotency logic
 
-func (r *RedisStore) CheckAndMarkAdmitted(ctx context.Context, jobID string, window time.Duration) (bool, error) {
+// idempotencyRedisKey is where the decision for an owner scoped idempotency key is kept
+func idempotencyRedisKey(key string) string {
+	return fmt.Sprintf("janus:idempotency:%s", key)
+}
+
+func (r *RedisStore) CheckAndMarkAdmitted(ctx context.Context, key string, window time.Duration) (bool, error) {
 	// Construct a unique key for job's idempotency
 
-	key := fmt.Sprintf("janus:idempotency:%s", jobID)
+	redisKey := idempotencyRedisKey(key)
 
 	// SETNX (Set if Not exists)
 
 	// If the key is set successfully, it returns true (meaning it is a new job)
 	// If the key already exists, it returns false (meaning it is a duplicate job)
 
-	isNew, err := r.client.SetNX(ctx, key, true, window).Result()
+	isNew, err := r.client.SetNX(ctx, redisKey, true, window).Result()
 	if err != nil {
 		return false, err
 	}
@@ -101,7 +207,7 @@ func (r *RedisStore) AllowRequestTokenBucket(ctx context.Context, key string, ca
 	//Keys : [tokensKey, timestampKey]
 	//Args : [capacity, refill_rate, cost, now]
 
-	result, err := tenantStarvationScript.Run(ctx, r.client, []string{tokensKey, timestampKey}, capacity, refillRate, cost, now).Result()
+	result, err := r.run(ctx, "tenant_starvation", tenantStarvationScript, []string{tokensKey, timestampKey}, capacity, refillRate, cost, now).Result()
 	if err != nil {
 		return false, err
 	}
@@ -132,7 +238,7 @@ func (r *RedisStore) AllowRequestAtomic(ctx context.Context, reqs []RateLimitReq
 		args = append(args, req.Capacity, req.RefillRate, req.Cost, req.MinInterval, req.WarmupMs)
 	}
 
-	res, err := atomicTokenBucketScript.Run(ctx, r.client, keys, args...).Result()
+	res, err := r.run(ctx, "atomic_token_bucket", atomicTokenBucketScript, keys, args...).Result()
 	if err != nil {
 		return false, err
 	}
@@ -140,12 +246,83 @@ func (r *RedisStore) AllowRequestAtomic(ctx context.Context, reqs []RateLimitReq
 	return res.(int64) == 1, nil
 }
 
+// Partial batch admission, one EVAL for the whole batch instead of SETNX + EVAL per job
+func (r *RedisStore) AllowBatchPartial(ctx context.Context, jobs []BatchAdmissionReq) ([]BatchAdmissionResult, error) {
+	return r.allowBatch(ctx, jobs, false)
+}
+
+// Max-prefix batch admission, same script but stops at the first rejection
+func (r *RedisStore) AllowBatchPrefix(ctx context.Context, jobs []BatchAdmissionReq) ([]BatchAdmissionResult, error) {
+	return r.allowBatch(ctx, jobs, true)
+}
+
+func (r *RedisStore) allowBatch(ctx context.Context, jobs []BatchAdmissionReq, stopOnReject bool) ([]BatchAdmissionResult, error) {
+	if len(jobs) == 0 {
+		return nil, nil
+	}
+
+	keys := make([]string, 0, len(jobs)*4)
+	args := make([]any, 0, 3+len(jobs)*8)
+
+	stop := 0
+	if stopOnReject {
+		stop = 1
+	}
+
+	now := float64(time.Now().UnixNano()) / 1e9
+	args = append(args, now, len(jobs), stop)
+
+	for _, job := range jobs {
+		keys = append(keys, idempotencyRedisKey(job.IdempotencyKey))
+		args = append(args, job.IdempotencyWindow.Milliseconds(), job.Record, len(job.Reqs))
+
+		for _, req := range job.Reqs {
+			keys = append(keys, fmt.Sprintf("janus:quota:%s:tokens", req.Key))
+			keys = append(keys, fmt.Sprintf("janus:quota:%s:ts", req.Key))
+			keys = append(keys, fmt.Sprintf("janus:quota:%s:created", req.Key))
+			args = append(args, req.Capacity, req.RefillRate, req.Cost, req.MinInterval, req.WarmupMs)
+		}
+	}
+
+	res, err := r.run(ctx, "batch_admission", batchAdmissionScript, keys, args...).Slice()
+	if err != nil {
+		return nil, err
+	}
+
+	bitmap, _ := res[0].(string)
+	if len(bitmap) != len(jobs) {
+		return nil, fmt.Errorf("batch admission returned %d results for %d jobs", len(bitmap), len(jobs))
+	}
+
+	priors := res[1:]
+	results := make([]BatchAdmissionResult, len(jobs))
+
+	for i := range jobs {
+		switch bitmap[i] {
+		case '1':
+			results[i].Admitted = true
+		case '3':
+			results[i].Skipped = true
+		case '2':
+			results[i].Duplicate = true
+			if len(priors) > 0 {
+				if prior, ok := priors[0].(string); ok && prior != "1" {
+					results[i].Prior = []byte(prior)
+				}
+				priors = priors[1:]
+			}
+		}
+	}
+
+	return results, nil
+}
+
 // AllowBurstSmoothing implements [StateStore].
 func (r *RedisStore) AllowBurstSmoothing(ctx context.Context, key string, minIntervalSeconds float64) (bool, error) {
 	tsKey := fmt.Sprintf("janus:smoothing:%s:ts", key)
 	now := float64(time.Now().UnixNano()) / 1e9
 
-	res, err := burstSmoothingScript.Run(ctx, r.client, []string{tsKey}, now, minIntervalSeconds).Result()
+	res, err := r.run(ctx, "burst_smoothing", burstSmoothingScript, []string{tsKey}, now, minIntervalSeconds).Result()
 	if err != nil {
 		return false, err
 	}
@@ -154,11 +331,80 @@ func (r *RedisStore) AllowBurstSmoothing(ctx context.Context, key string, minInt
 
 }
 
-func (r *RedisStore) ClearIdempotency(ctx context.Context, jobID string) error {
-	key := fmt.Sprintf("janus:idempotency:%s", jobID)
-	return r.client.Del(ctx, key).Err()
+func (r *RedisStore) ClearIdempotency(ctx context.Context, key string) error {
+	return r.client.Del(ctx, idempotencyRedisKey(key)).Err()
+}
+
+func (r *RedisStore) GetIdempotencyRecord(ctx context.Context, key string) ([]byte, error) {
+	record, err := r.client.Get(ctx, idempotencyRedisKey(key)).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// SETNX stores "1" until the decision is known
+	if string(record) == "1" {
+		return nil, nil
+	}
+
+	return record, nil
+}
+
+func (r *RedisStore) SaveIdempotencyRecord(ctx context.Context, key string, record []byte) error {
+	// XX: only overwrite a live marker, never resurrect an expired or cleared key
+	err := r.client.SetArgs(ctx, idempotencyRedisKey(key), record, redis.SetArgs{Mode: "XX", KeepTTL: true}).Err()
+	if err == redis.Nil {
+		return nil
+	}
+	return err
+}
+
+func (r *RedisStore) RefundTokens(ctx context.Context, reqs []RateLimitReq) error {
+	if len(reqs) == 0 {
+		return nil
+	}
+
+	keys := make([]string, 0, len(reqs))
+	args := make([]any, 0, len(reqs)*2)
+	for _, req := range reqs {
+		keys = append(keys, fmt.Sprintf("janus:quota:%s:tokens", req.Key))
+		args = append(args, req.Capacity, req.Cost)
+	}
+
+	return r.run(ctx, "refund_tokens", refundTokensScript, keys, args...).Err()
 }
 
 func (r *RedisStore) Flush(ctx context.Context) error {
 	return r.client.FlushDB(ctx).Err()
 }
+
+// globEscaper escapes what SCAN MATCH would read as a pattern
+var globEscaper = strings.NewReplacer(`\`, `\\`, "*", `\*`, "?", `\?`, "[", `\[`, "]", `\]`)
+
+func (r *RedisStore) ResetQuotas(ctx context.Context, ownerID string) error {
+	// Quota keys start with OwnerKey, which holds no ":", so no other owner's keys match
+	pattern := "janus:quota:" + globEscaper.Replace(OwnerKey(ownerID)) + ":*"
+	iter := r.client.Scan(ctx, 0, pattern, 500).Iterator()
+
+	var keys []string
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+		if len(keys) == 500 {
+			if err := r.client.Unlink(ctx, keys...).Err(); err != nil {
+				return err
+			}
+			keys = keys[:0]
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return err
+	}
+	if len(keys) > 0 {
+		if err := r.client.Unlink(ctx, keys...).Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
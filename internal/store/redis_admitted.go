@@ -0,0 +1,26 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// STRING holding the encoded job
+func admittedKey(ownerID, jobID string) string {
+	return fmt.Sprintf("janus:admitted:%s:%s", OwnerKey(ownerID), jobID)
+}
+
+func (r *RedisStore) RememberAdmitted(ctx context.Context, ownerID, jobID string, job []byte, ttl time.Duration) error {
+	return r.client.Set(ctx, admittedKey(ownerID, jobID), job, ttl).Err()
+}
+
+func (r *RedisStore) TakeAdmitted(ctx context.Context, ownerID, jobID string) ([]byte, error) {
+	job, err := r.client.GetDel(ctx, admittedKey(ownerID, jobID)).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	return job, err
+}
@@ -0,0 +1,40 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestTakeAdmittedOnce(t *testing.T) {
+	ctx := context.Background()
+	s, mr := newTestStore(t)
+
+	if err := s.RememberAdmitted(ctx, "owner-a", "job-1", []byte("job"), time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	if job, err := s.TakeAdmitted(ctx, "owner-b", "job-1"); err != nil || job != nil {
+		t.Fatalf("another owner took %q, %v", job, err)
+	}
+	if err := s.RememberAdmitted(ctx, "a", "b:c", []byte("job"), time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if job, err := s.TakeAdmitted(ctx, "a:b", "c"); err != nil || job != nil {
+		t.Fatalf("owner and job IDs ran together, took %q, %v", job, err)
+	}
+	if job, err := s.TakeAdmitted(ctx, "owner-a", "job-1"); err != nil || string(job) != "job" {
+		t.Fatalf("took %q, %v", job, err)
+	}
+	if job, err := s.TakeAdmitted(ctx, "owner-a", "job-1"); err != nil || job != nil {
+		t.Fatalf("taken twice: %q, %v", job, err)
+	}
+
+	if err := s.RememberAdmitted(ctx, "owner-a", "job-2", []byte("job"), time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	mr.FastForward(time.Minute + time.Second)
+	if job, _ := s.TakeAdmitted(ctx, "owner-a", "job-2"); job != nil {
+		t.Fatal("took a job past its ttl")
+	}
+}
@@ -0,0 +1,128 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ZSET job_id -> dead at (unix ms)
+func deadLetterIndexKey(ownerID string) string {
+	return fmt.Sprintf("janus:dlq:%s", OwnerKey(ownerID))
+}
+
+// HASH job_id -> entry
+func deadLetterEntriesKey(ownerID string) string {
+	return fmt.Sprintf("janus:dlq:entries:%s", OwnerKey(ownerID))
+}
+
+func (r *RedisStore) AddDeadLetter(ctx context.Context, item DeadLetterItem) error {
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, deadLetterEntriesKey(item.OwnerID), item.JobID, item.Entry)
+		pipe.ZAdd(ctx, deadLetterIndexKey(item.OwnerID), redis.Z{Score: float64(item.DeadAt.UnixMilli()), Member: item.JobID})
+		return nil
+	})
+	return err
+}
+
+func (r *RedisStore) ListDeadLetters(ctx context.Context, ownerID string, offset, limit int) ([]DeadLetterItem, int64, error) {
+	indexKey := deadLetterIndexKey(ownerID)
+
+	total, err := r.client.ZCard(ctx, indexKey).Result()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	ids, err := r.client.ZRevRangeWithScores(ctx, indexKey, int64(offset), int64(offset+limit-1)).Result()
+	if err != nil || len(ids) == 0 {
+		return nil, total, err
+	}
+
+	fields := make([]string, len(ids))
+	for i, z := range ids {
+		fields[i] = z.Member.(string)
+	}
+
+	entries, err := r.client.HMGet(ctx, deadLetterEntriesKey(ownerID), fields...).Result()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	items := make([]DeadLetterItem, 0, len(ids))
+	for i, z := range ids {
+		entry, ok := entries[i].(string)
+		if !ok {
+			// Removed between the two reads
+			continue
+		}
+		items = append(items, DeadLetterItem{
+			JobID:   fields[i],
+			OwnerID: ownerID,
+			DeadAt:  time.UnixMilli(int64(z.Score)),
+			Entry:   []byte(entry),
+		})
+	}
+
+	return items, total, nil
+}
+
+func (r *RedisStore) GetDeadLetter(ctx context.Context, ownerID, jobID string) (*DeadLetterItem, error) {
+	entry, err := r.client.HGet(ctx, deadLetterEntriesKey(ownerID), jobID).Result()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	deadAt, err := r.client.ZScore(ctx, deadLetterIndexKey(ownerID), jobID).Result()
+	if err != nil && err != redis.Nil {
+		return nil, err
+	}
+
+	return &DeadLetterItem{
+		JobID:   jobID,
+		OwnerID: ownerID,
+		DeadAt:  time.UnixMilli(int64(deadAt)),
+		Entry:   []byte(entry),
+	}, nil
+}
+
+func (r *RedisStore) RemoveDeadLetters(ctx context.Context, ownerID string, jobIDs []string) (int, error) {
+	if len(jobIDs) == 0 {
+		return 0, nil
+	}
+
+	members := make([]any, len(jobIDs))
+	for i, id := range jobIDs {
+		members[i] = id
+	}
+
+	var removed *redis.IntCmd
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		removed = pipe.ZRem(ctx, deadLetterIndexKey(ownerID), members...)
+		pipe.HDel(ctx, deadLetterEntriesKey(ownerID), jobIDs...)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return int(removed.Val()), nil
+}
+
+func (r *RedisStore) PurgeDeadLetters(ctx context.Context, ownerID string) (int, error) {
+	var count *redis.IntCmd
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		count = pipe.ZCard(ctx, deadLetterIndexKey(ownerID))
+		pipe.Del(ctx, deadLetterIndexKey(ownerID), deadLetterEntriesKey(ownerID))
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return int(count.Val()), nil
+}
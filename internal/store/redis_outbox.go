@@ -0,0 +1,112 @@
+package store
+
+import (
+	"context"
+	_ "embed"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+//go:embed outbox_claim.lua
+var outboxClaimScriptContent string
+var outboxClaimScript = redis.NewScript(outboxClaimScriptContent)
+
+//go:embed outbox_remove.lua
+var outboxRemoveScriptContent string
+var outboxRemoveScript = redis.NewScript(outboxRemoveScriptContent)
+
+const (
+	outboxKey            = "janus:outbox"              // ZSET job_id -> due at (unix ms)
+	outboxEntriesPrefix  = "janus:outbox:job:"         // LIST of entries per job, oldest first
+	outboxDeadLettersKey = "janus:outbox:dead_letters" // LIST of entries given up on, newest first
+	outboxDeadLettersMax = 10000
+)
+
+func outboxEntriesKey(jobID string) string {
+	return outboxEntriesPrefix + jobID
+}
+
+func (r *RedisStore) AddToOutbox(ctx context.Context, entries []OutboxEntry, dueAt time.Time) error {
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, e := range entries {
+			pipe.RPush(ctx, outboxEntriesKey(e.JobID), e.Entry)
+			pipe.ZAddNX(ctx, outboxKey, redis.Z{Score: float64(dueAt.UnixMilli()), Member: e.JobID})
+		}
+		return nil
+	})
+	return err
+}
+
+func (r *RedisStore) InOutbox(ctx context.Context, jobIDs []string) (map[string]bool, error) {
+	if len(jobIDs) == 0 {
+		return nil, nil
+	}
+
+	// Missing members score 0, due times never do
+	scores, err := r.client.ZMScore(ctx, outboxKey, jobIDs...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	held := make(map[string]bool)
+	for i, score := range scores {
+		if score != 0 {
+			held[jobIDs[i]] = true
+		}
+	}
+	return held, nil
+}
+
+func (r *RedisStore) ClaimOutbox(ctx context.Context, now time.Time, limit int, visibility time.Duration) ([]OutboxJob, error) {
+	res, err := r.run(ctx, "outbox_claim", outboxClaimScript,
+		[]string{outboxKey},
+		now.UnixMilli(), limit, now.Add(visibility).UnixMilli(), outboxEntriesPrefix,
+	).StringSlice()
+	if err != nil {
+		return nil, err
+	}
+
+	var jobs []OutboxJob
+	for i := 0; i+1 < len(res); {
+		n, err := strconv.Atoi(res[i+1])
+		if err != nil || i+2+n > len(res) {
+			return nil, fmt.Errorf("malformed outbox claim reply")
+		}
+
+		job := OutboxJob{JobID: res[i], Entries: make([][]byte, n)}
+		for j := range n {
+			job.Entries[j] = []byte(res[i+2+j])
+		}
+		jobs = append(jobs, job)
+		i += 2 + n
+	}
+
+	return jobs, nil
+}
+
+func (r *RedisStore) RemoveFromOutbox(ctx context.Context, jobID string, n int, dueAt time.Time) error {
+	return r.ReplaceInOutbox(ctx, jobID, n, nil, nil, dueAt)
+}
+
+func (r *RedisStore) ReplaceInOutbox(ctx context.Context, jobID string, n int, keep, dead [][]byte, dueAt time.Time) error {
+	args := make([]any, 0, 5+len(keep)+len(dead))
+	args = append(args, jobID, n, dueAt.UnixMilli(), len(keep), outboxDeadLettersMax)
+	for _, entry := range keep {
+		args = append(args, entry)
+	}
+	for _, entry := range dead {
+		args = append(args, entry)
+	}
+
+	return r.run(ctx, "outbox_remove", outboxRemoveScript,
+		[]string{outboxKey, outboxEntriesKey(jobID), outboxDeadLettersKey},
+		args...,
+	).Err()
+}
+
+func (r *RedisStore) OutboxLen(ctx context.Context) (int64, error) {
+	return r.client.ZCard(ctx, outboxKey).Result()
+}
@@ -0,0 +1,340 @@
+package store
+
+import (
+	"context"
+	_ "embed"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+//go:embed ready_lease.lua
+var readyLeaseScriptContent string
+var readyLeaseScript = redis.NewScript(readyLeaseScriptContent)
+
+//go:embed ready_extend.lua
+var readyExtendScriptContent string
+var readyExtendScript = redis.NewScript(readyExtendScriptContent)
+
+//go:embed ready_finish.lua
+var readyFinishScriptContent string
+var readyFinishScript = redis.NewScript(readyFinishScriptContent)
+
+//go:embed ready_fail.lua
+var readyFailScriptContent string
+var readyFailScript = redis.NewScript(readyFailScriptContent)
+
+//go:embed ready_reap.lua
+var readyReapScriptContent string
+var readyReapScript = redis.NewScript(readyReapScriptContent)
+
+//go:embed ready_remove.lua
+var readyRemoveScriptContent string
+var readyRemoveScript = redis.NewScript(readyRemoveScriptContent)
+
+const (
+	leaseOwnersKey = "janus:lease_owners" // SET of owners holding leases, walked by the reaper
+
+	// Leasing scans past jobs filtered out by tenant or dependency, up to this many
+	leaseScanLimit = 1000
+
+	// Attempt histories of jobs that never finish are dropped after this long
+	attemptsTTL = 7 * 24 * time.Hour
+)
+
+// ZSET job_id -> available at (unix ms)
+func readyQueueKey(ownerID string) string {
+	return fmt.Sprintf("janus:ready:%s", ownerID)
+}
+
+// ZSET lease_id -> deadline (unix ms)
+func leasesKey(ownerID string) string {
+	return fmt.Sprintf("janus:leases:%s", ownerID)
+}
+
+// HASH of job and lease fields, see ready_lease.lua
+func readyJobsKey(ownerID string) string {
+	return fmt.Sprintf("janus:ready_jobs:%s", ownerID)
+}
+
+func (r *RedisStore) Enqueue(ctx context.Context, job ReadyJob) error {
+	deps := ","
+	if len(job.Dependencies) > 0 {
+		deps = "," + strings.Join(job.Dependencies, ",") + ","
+	}
+
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, readyJobsKey(job.OwnerID),
+			"job:"+job.JobID, job.Job,
+			"tenant:"+job.JobID, job.TenantID,
+			"deps:"+job.JobID, deps,
+			"max:"+job.JobID, job.MaxAttempts,
+		)
+		pipe.ZAdd(ctx, readyQueueKey(job.OwnerID), redis.Z{Score: float64(job.AvailableAt.UnixMilli()), Member: job.JobID})
+		return nil
+	})
+	return err
+}
+
+func (r *RedisStore) Lease(ctx context.Context, ownerID string, filter LeaseFilter, leaseIDs []string, ttl, maxLease time.Duration) ([]Lease, error) {
+	if len(leaseIDs) == 0 {
+		return nil, nil
+	}
+
+	now := time.Now()
+
+	args := []any{ownerID, now.UnixMilli(), ttl.Milliseconds(), maxLease.Milliseconds(), filter.TenantID, filter.Dependency, leaseScanLimit}
+	for _, id := range leaseIDs {
+		args = append(args, id)
+	}
+
+	res, err := r.run(ctx, "ready_lease", readyLeaseScript,
+		[]string{readyQueueKey(ownerID), leasesKey(ownerID), readyJobsKey(ownerID), leaseOwnersKey},
+		args...,
+	).Slice()
+	if err != nil {
+		return nil, err
+	}
+
+	deadline := now.Add(ttl)
+	var maxDeadline time.Time
+	if maxLease > 0 {
+		maxDeadline = time.UnixMilli(now.UnixMilli() + maxLease.Milliseconds())
+		if deadline.After(maxDeadline) {
+			deadline = maxDeadline
+		}
+	}
+
+	leases := make([]Lease, 0, len(res)/4)
+	for i := 0; i+3 < len(res); i += 4 {
+		leases = append(leases, Lease{
+			LeaseID:     asString(res[i]),
+			JobID:       asString(res[i+1]),
+			OwnerID:     ownerID,
+			Attempt:     int(asInt(res[i+2])),
+			Deadline:    deadline,
+			MaxDeadline: maxDeadline,
+			Job:         []byte(asString(res[i+3])),
+		})
+	}
+
+	return leases, nil
+}
+
+func (r *RedisStore) ExtendLease(ctx context.Context, ownerID, leaseID string, extend time.Duration) (*Lease, bool, error) {
+	res, err := r.run(ctx, "ready_extend", readyExtendScript,
+		[]string{leasesKey(ownerID), readyJobsKey(ownerID)},
+		leaseID, time.Now().UnixMilli(), extend.Milliseconds(),
+	).Int64Slice()
+	if err != nil {
+		return nil, false, err
+	}
+	if len(res) < 3 || res[0] < 0 {
+		return nil, false, ErrLeaseNotFound
+	}
+
+	l := &Lease{LeaseID: leaseID, OwnerID: ownerID, Deadline: time.UnixMilli(res[0])}
+	if res[2] > 0 {
+		l.MaxDeadline = time.UnixMilli(res[2])
+	}
+
+	return l, res[1] == 1, nil
+}
+
+func (r *RedisStore) GetLease(ctx context.Context, ownerID, leaseID string) (*Lease, error) {
+	jobsKey := readyJobsKey(ownerID)
+
+	jobID, err := r.client.HGet(ctx, jobsKey, "lease:"+leaseID).Result()
+	if err == redis.Nil {
+		return nil, ErrLeaseNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	deadline, err := r.client.ZScore(ctx, leasesKey(ownerID), leaseID).Result()
+	if err == redis.Nil {
+		return nil, ErrLeaseNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	fields, err := r.client.HMGet(ctx, jobsKey, "attempts:"+jobID, "job:"+jobID).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Lease{
+		LeaseID:  leaseID,
+		JobID:    jobID,
+		OwnerID:  ownerID,
+		Attempt:  int(asInt(fields[0])),
+		Deadline: time.UnixMilli(int64(deadline)),
+		Job:      []byte(asString(fields[1])),
+	}, nil
+}
+
+func (r *RedisStore) CompleteLease(ctx context.Context, ownerID, leaseID string) (*Lease, error) {
+	res, err := r.run(ctx, "ready_finish", readyFinishScript,
+		[]string{readyQueueKey(ownerID), leasesKey(ownerID), readyJobsKey(ownerID), leaseOwnersKey},
+		ownerID, leaseID,
+	).Slice()
+	if err != nil {
+		return nil, err
+	}
+
+	return finishedLease(ownerID, leaseID, res)
+}
+
+func (r *RedisStore) FailLease(ctx context.Context, ownerID, leaseID, jobID string, attempt []byte, retryAt time.Time) (*Lease, error) {
+	var availableAt int64
+	if !retryAt.IsZero() {
+		availableAt = max(retryAt.UnixMilli(), 1)
+	}
+
+	res, err := r.run(ctx, "ready_fail", readyFailScript,
+		[]string{readyQueueKey(ownerID), leasesKey(ownerID), readyJobsKey(ownerID), leaseOwnersKey, attemptsKey(ownerID, jobID)},
+		ownerID, leaseID, jobID, availableAt, attempt, attemptsTTL.Milliseconds(),
+	).Slice()
+	if err != nil {
+		return nil, err
+	}
+
+	return finishedLease(ownerID, leaseID, res)
+}
+
+// finishedLease reads the [job_id, attempt, job] reply of a script that ended a lease
+func finishedLease(ownerID, leaseID string, res []any) (*Lease, error) {
+	if len(res) < 3 {
+		return nil, ErrLeaseNotFound
+	}
+
+	return &Lease{
+		LeaseID: leaseID,
+		JobID:   asString(res[0]),
+		OwnerID: ownerID,
+		Attempt: int(asInt(res[1])),
+		Job:     []byte(asString(res[2])),
+	}, nil
+}
+
+func (r *RedisStore) LeaseOwners(ctx context.Context) ([]string, error) {
+	return r.client.SMembers(ctx, leaseOwnersKey).Result()
+}
+
+func (r *RedisStore) ReapExpired(ctx context.Context, ownerID string, limit int, hold time.Duration) ([]Lease, error) {
+	now := time.Now()
+	res, err := r.run(ctx, "ready_reap", readyReapScript,
+		[]string{readyQueueKey(ownerID), leasesKey(ownerID), readyJobsKey(ownerID), leaseOwnersKey},
+		ownerID, now.UnixMilli(), limit, now.Add(hold).UnixMilli(),
+	).Slice()
+	if err != nil {
+		return nil, err
+	}
+
+	leases := make([]Lease, 0, len(res)/5)
+	for i := 0; i+4 < len(res); i += 5 {
+		leases = append(leases, Lease{
+			LeaseID: asString(res[i]),
+			JobID:   asString(res[i+1]),
+			OwnerID: ownerID,
+			Attempt: int(asInt(res[i+2])),
+			Dead:    asInt(res[i+3]) == 1,
+			Job:     []byte(asString(res[i+4])),
+		})
+	}
+
+	return leases, nil
+}
+
+func (r *RedisStore) DelayReady(ctx context.Context, ownerID, jobID string, at time.Time) error {
+	return r.client.ZAddXX(ctx, readyQueueKey(ownerID), redis.Z{Score: float64(at.UnixMilli()), Member: jobID}).Err()
+}
+
+func (r *RedisStore) RemoveReady(ctx context.Context, ownerID, jobID string) (*Lease, error) {
+	res, err := r.run(ctx, "ready_remove", readyRemoveScript,
+		[]string{readyQueueKey(ownerID), leasesKey(ownerID), readyJobsKey(ownerID), leaseOwnersKey},
+		ownerID, jobID,
+	).Slice()
+	if err != nil {
+		return nil, err
+	}
+	if len(res) < 3 {
+		return nil, nil
+	}
+
+	return &Lease{
+		LeaseID: asString(res[0]),
+		JobID:   jobID,
+		OwnerID: ownerID,
+		Attempt: int(asInt(res[1])),
+		Job:     []byte(asString(res[2])),
+	}, nil
+}
+
+// LIST of attempt entries, oldest first
+func attemptsKey(ownerID, jobID string) string {
+	return fmt.Sprintf("janus:attempts:%s:%s", OwnerKey(ownerID), jobID)
+}
+
+func (r *RedisStore) AddAttempt(ctx context.Context, ownerID, jobID string, entry []byte) ([][]byte, error) {
+	key := attemptsKey(ownerID, jobID)
+
+	var history *redis.StringSliceCmd
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.RPush(ctx, key, entry)
+		pipe.Expire(ctx, key, attemptsTTL)
+		history = pipe.LRange(ctx, key, 0, -1)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([][]byte, len(history.Val()))
+	for i, e := range history.Val() {
+		entries[i] = []byte(e)
+	}
+
+	return entries, nil
+}
+
+func (r *RedisStore) Attempts(ctx context.Context, ownerID, jobID string) ([][]byte, error) {
+	history, err := r.client.LRange(ctx, attemptsKey(ownerID, jobID), 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([][]byte, len(history))
+	for i, e := range history {
+		entries[i] = []byte(e)
+	}
+
+	return entries, nil
+}
+
+func (r *RedisStore) ClearAttempts(ctx context.Context, ownerID, jobID string) error {
+	return r.client.Del(ctx, attemptsKey(ownerID, jobID)).Err()
+}
+
+// asString reads a Lua string reply, nil (Lua false) becomes ""
+func asString(v any) string {
+	s, _ := v.(string)
+	return s
+}
+
+// asInt reads a Lua integer reply, or a numeric string from HGET
+func asInt(v any) int64 {
+	switch n := v.(type) {
+	case int64:
+		return n
+	case string:
+		var i int64
+		fmt.Sscan(n, &i)
+		return i
+	}
+	return 0
+}
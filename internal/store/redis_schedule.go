@@ -0,0 +1,99 @@
+package store
+
+import (
+	"context"
+	_ "embed"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+//go:embed schedule_claim.lua
+var scheduleClaimScriptContent string
+var scheduleClaimScript = redis.NewScript(scheduleClaimScriptContent)
+
+//go:embed schedule_remove.lua
+var scheduleRemoveScriptContent string
+var scheduleRemoveScript = redis.NewScript(scheduleRemoveScriptContent)
+
+const (
+	scheduleKey        = "janus:scheduled"         // ZSET owner:job_id -> run at (unix ms)
+	scheduleEntriesKey = "janus:scheduled:entries" // HASH owner:job_id -> entry
+	scheduleClaimsKey  = "janus:scheduled:claims"  // ZSET owner:job_id -> claimed until (unix ms)
+)
+
+func (r *RedisStore) Schedule(ctx context.Context, job ScheduledJob) error {
+	member := ownedMember(job.OwnerID, job.JobID)
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, scheduleEntriesKey, member, job.Entry)
+		pipe.ZAdd(ctx, scheduleKey, redis.Z{Score: float64(job.RunAt.UnixMilli()), Member: member})
+		pipe.ZRem(ctx, scheduleClaimsKey, member)
+		return nil
+	})
+	return err
+}
+
+func (r *RedisStore) ClaimDue(ctx context.Context, now time.Time, limit int, visibility time.Duration) ([]ScheduledJob, error) {
+	res, err := r.run(ctx, "schedule_claim", scheduleClaimScript,
+		[]string{scheduleKey, scheduleEntriesKey, scheduleClaimsKey},
+		now.UnixMilli(), limit, now.Add(visibility).UnixMilli(),
+	).StringSlice()
+	if err != nil {
+		return nil, err
+	}
+
+	jobs := make([]ScheduledJob, 0, len(res)/2)
+	for i := 0; i+1 < len(res); i += 2 {
+		ownerID, jobID := splitOwnedMember(res[i])
+		jobs = append(jobs, ScheduledJob{JobID: jobID, OwnerID: ownerID, Entry: []byte(res[i+1]), RunAt: now})
+	}
+
+	return jobs, nil
+}
+
+func (r *RedisStore) Unschedule(ctx context.Context, ownerID, jobID string) (bool, error) {
+	return r.removeScheduled(ctx, ownedMember(ownerID, jobID), time.Time{})
+}
+
+func (r *RedisStore) WithdrawScheduled(ctx context.Context, ownerID, jobID string) (bool, error) {
+	return r.removeScheduled(ctx, ownedMember(ownerID, jobID), time.Now())
+}
+
+// removeScheduled removes the job unless, with now set, a claim on it is live
+func (r *RedisStore) removeScheduled(ctx context.Context, member string, now time.Time) (bool, error) {
+	var nowMs int64
+	if !now.IsZero() {
+		nowMs = now.UnixMilli()
+	}
+
+	res, err := r.run(ctx, "schedule_remove", scheduleRemoveScript,
+		[]string{scheduleKey, scheduleEntriesKey, scheduleClaimsKey},
+		member, nowMs,
+	).Int()
+	if err != nil {
+		return false, err
+	}
+	if res < 0 {
+		return false, ErrClaimed
+	}
+	return res == 1, nil
+}
+
+func (r *RedisStore) GetScheduled(ctx context.Context, ownerID, jobID string) (*ScheduledJob, error) {
+	member := ownedMember(ownerID, jobID)
+	var entry *redis.StringCmd
+	var runAt *redis.FloatCmd
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		entry = pipe.HGet(ctx, scheduleEntriesKey, member)
+		runAt = pipe.ZScore(ctx, scheduleKey, member)
+		return nil
+	})
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &ScheduledJob{JobID: jobID, OwnerID: ownerID, Entry: []byte(entry.Val()), RunAt: time.UnixMilli(int64(runAt.Val()))}, nil
+}
@@ -0,0 +1,95 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestClaimDue(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newTestStore(t)
+	now := time.Now()
+
+	for id, runAt := range map[string]time.Time{"due": now.Add(-time.Second), "later": now.Add(time.Hour)} {
+		if err := s.Schedule(ctx, ScheduledJob{JobID: id, OwnerID: "owner", Entry: []byte(id), RunAt: runAt}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	jobs, err := s.ClaimDue(ctx, now, 10, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(jobs) != 1 || jobs[0].JobID != "due" || jobs[0].OwnerID != "owner" || string(jobs[0].Entry) != "due" {
+		t.Fatalf("claimed %+v, want only the due job", jobs)
+	}
+
+	if again, _ := s.ClaimDue(ctx, now, 10, time.Minute); len(again) != 0 {
+		t.Fatalf("claimed %+v again while the claim is live", again)
+	}
+
+	// The claimer never finished, the job comes due again once the claim runs out
+	again, err := s.ClaimDue(ctx, now.Add(time.Minute+time.Second), 10, time.Minute)
+	if err != nil || len(again) != 1 || again[0].JobID != "due" {
+		t.Fatalf("after the claim ran out: %+v, %v", again, err)
+	}
+}
+
+func TestWithdrawScheduled(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newTestStore(t)
+	now := time.Now()
+
+	for _, id := range []string{"job-1", "job-2"} {
+		if err := s.Schedule(ctx, ScheduledJob{JobID: id, OwnerID: "owner", Entry: []byte(id), RunAt: now.Add(-time.Second)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if removed, err := s.WithdrawScheduled(ctx, "owner", "job-1"); err != nil || !removed {
+		t.Fatalf("unclaimed job: removed=%v err=%v", removed, err)
+	}
+	if job, _ := s.GetScheduled(ctx, "owner", "job-1"); job != nil {
+		t.Fatal("withdrawn job is still scheduled")
+	}
+
+	if _, err := s.ClaimDue(ctx, now, 10, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.WithdrawScheduled(ctx, "owner", "job-2"); !errors.Is(err, ErrClaimed) {
+		t.Fatalf("claimed job got %v, want ErrClaimed", err)
+	}
+
+	// The claimer itself removes it regardless
+	if removed, err := s.Unschedule(ctx, "owner", "job-2"); err != nil || !removed {
+		t.Fatalf("Unschedule: removed=%v err=%v", removed, err)
+	}
+	if removed, err := s.WithdrawScheduled(ctx, "owner", "job-2"); err != nil || removed {
+		t.Fatalf("gone job: removed=%v err=%v", removed, err)
+	}
+}
+
+func TestScheduledJobsAreScopedToOwner(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newTestStore(t)
+	runAt := time.Now().Add(time.Hour)
+
+	for _, owner := range []string{"a", "a:b"} {
+		if err := s.Schedule(ctx, ScheduledJob{JobID: "job-1", OwnerID: owner, Entry: []byte(owner), RunAt: runAt}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if removed, err := s.WithdrawScheduled(ctx, "a", "job-1"); err != nil || !removed {
+		t.Fatalf("WithdrawScheduled: removed=%v err=%v", removed, err)
+	}
+	if job, _ := s.GetScheduled(ctx, "a", "job-1"); job != nil {
+		t.Fatal("withdrawn job is still scheduled")
+	}
+
+	other, err := s.GetScheduled(ctx, "a:b", "job-1")
+	if err != nil || other == nil || string(other.Entry) != "a:b" {
+		t.Fatalf("the other owner's job got %+v, %v", other, err)
+	}
+}
@@ -0,0 +1,120 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+// newTestStore returns a RedisStore on an in-memory Redis that lives as long as the test
+func newTestStore(t *testing.T) (*RedisStore, *miniredis.Miniredis) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	s := NewRedisStore(mr.Addr())
+	t.Cleanup(func() { s.client.Close() })
+	return s, mr
+}
+
+func TestCheckAndMarkAdmitted(t *testing.T) {
+	ctx := context.Background()
+	s, mr := newTestStore(t)
+
+	seen, err := s.CheckAndMarkAdmitted(ctx, "owner-a:job-1", time.Minute)
+	if err != nil || seen {
+		t.Fatalf("first submission: seen=%v err=%v, want unseen", seen, err)
+	}
+
+	seen, err = s.CheckAndMarkAdmitted(ctx, "owner-a:job-1", time.Minute)
+	if err != nil || !seen {
+		t.Fatalf("second submission: seen=%v err=%v, want seen", seen, err)
+	}
+
+	seen, err = s.CheckAndMarkAdmitted(ctx, "owner-b:job-1", time.Minute)
+	if err != nil || seen {
+		t.Fatalf("other owner: seen=%v err=%v, want unseen", seen, err)
+	}
+
+	mr.FastForward(time.Minute + time.Second)
+	seen, err = s.CheckAndMarkAdmitted(ctx, "owner-a:job-1", time.Minute)
+	if err != nil || seen {
+		t.Fatalf("after the window: seen=%v err=%v, want unseen", seen, err)
+	}
+}
+
+func TestIdempotencyRecord(t *testing.T) {
+	ctx := context.Background()
+	s, mr := newTestStore(t)
+
+	if _, err := s.CheckAndMarkAdmitted(ctx, "owner-a:job-1", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	record, err := s.GetIdempotencyRecord(ctx, "owner-a:job-1")
+	if err != nil || record != nil {
+		t.Fatalf("marker only: record=%q err=%v, want nil", record, err)
+	}
+
+	if err := s.SaveIdempotencyRecord(ctx, "owner-a:job-1", []byte(`{"status":"accepted"}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	record, err = s.GetIdempotencyRecord(ctx, "owner-a:job-1")
+	if err != nil || string(record) != `{"status":"accepted"}` {
+		t.Fatalf("record=%q err=%v", record, err)
+	}
+	if ttl := mr.TTL(idempotencyRedisKey("owner-a:job-1")); ttl <= 0 || ttl > time.Minute {
+		t.Fatalf("record TTL %v, want the marker's window", ttl)
+	}
+
+	if err := s.ClearIdempotency(ctx, "owner-a:job-1"); err != nil {
+		t.Fatal(err)
+	}
+	if mr.Exists(idempotencyRedisKey("owner-a:job-1")) {
+		t.Fatal("key still exists after ClearIdempotency")
+	}
+}
+
+func TestResetQuotasOnlyTouchesOwner(t *testing.T) {
+	ctx := context.Background()
+	s, mr := newTestStore(t)
+
+	keys := map[string]bool{ // key -> kept after resetting owner-a
+		"janus:quota:owner-a:global_request_quota:tokens":  false,
+		"janus:quota:owner-a:tenant:t1:ts":                 false,
+		"janus:quota:owner-ab:global_request_quota:tokens": true,
+		"janus:quota:owner-*:global_request_quota:tokens":  true,
+		"janus:quota:owner-a%3Ab:tenant:t1:ts":             true, // owner-a:b
+		"janus:idempotency:owner-a:job-1":                  true,
+	}
+	for k := range keys {
+		mr.Set(k, "1")
+	}
+
+	// A glob in the owner ID matches only itself
+	if err := s.ResetQuotas(ctx, "owner-*"); err != nil {
+		t.Fatal(err)
+	}
+	if !mr.Exists("janus:quota:owner-a:tenant:t1:ts") || mr.Exists("janus:quota:owner-*:global_request_quota:tokens") {
+		t.Fatal("resetting owner-* touched another owner's quota or missed its own")
+	}
+	keys["janus:quota:owner-*:global_request_quota:tokens"] = false
+
+	if err := s.ResetQuotas(ctx, "owner-a:b"); err != nil {
+		t.Fatal(err)
+	}
+	if mr.Exists("janus:quota:owner-a%3Ab:tenant:t1:ts") || !mr.Exists("janus:quota:owner-a:tenant:t1:ts") {
+		t.Fatal("resetting owner-a:b touched owner-a's quota or missed its own")
+	}
+	keys["janus:quota:owner-a%3Ab:tenant:t1:ts"] = false
+
+	if err := s.ResetQuotas(ctx, "owner-a"); err != nil {
+		t.Fatal(err)
+	}
+	for k, kept := range keys {
+		if mr.Exists(k) != kept {
+			t.Errorf("%s: exists=%v, want %v", k, mr.Exists(k), kept)
+		}
+	}
+}
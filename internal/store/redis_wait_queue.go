@@ -0,0 +1,149 @@
+package store
+
+import (
+	"context"
+	_ "embed"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+//go:embed wait_defer.lua
+var waitDeferScriptContent string
+var waitDeferScript = redis.NewScript(waitDeferScriptContent)
+
+//go:embed wait_claim.lua
+var waitClaimScriptContent string
+var waitClaimScript = redis.NewScript(waitClaimScriptContent)
+
+//go:embed wait_release.lua
+var waitReleaseScriptContent string
+var waitReleaseScript = redis.NewScript(waitReleaseScriptContent)
+
+const (
+	deferredEntriesKey  = "janus:deferred:entries"   // HASH owner:job_id -> entry
+	deferredTenantOfKey = "janus:deferred:tenant_of" // HASH owner:job_id -> tenant_key
+	deferredExpiryKey   = "janus:deferred:expiry"    // ZSET owner:job_id -> expires_at (unix ms)
+	deferredTenantsKey  = "janus:deferred:tenants"   // HASH tenant_key -> weight
+	deferredClaimsKey   = "janus:deferred:claims"    // ZSET owner:job_id -> claimed until (unix ms)
+)
+
+// ZSET owner:job_id -> rank
+func deferredTenantQueueKey(tenantKey string) string {
+	return fmt.Sprintf("janus:deferred:tenant:%s", tenantKey)
+}
+
+func (r *RedisStore) Defer(ctx context.Context, job WaitingJob) error {
+	return r.run(ctx, "wait_defer", waitDeferScript,
+		[]string{deferredEntriesKey, deferredTenantOfKey, deferredExpiryKey, deferredTenantsKey, deferredTenantQueueKey(job.TenantKey)},
+		ownedMember(job.OwnerID, job.JobID), job.Entry, job.TenantKey, job.Rank, job.ExpiresAt.UnixMilli(), job.Weight,
+	).Err()
+}
+
+func (r *RedisStore) ActiveTenants(ctx context.Context) (map[string]int, error) {
+	raw, err := r.client.HGetAll(ctx, deferredTenantsKey).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	tenants := make(map[string]int, len(raw))
+	for tenantKey, w := range raw {
+		weight, err := strconv.Atoi(w)
+		if err != nil || weight <= 0 {
+			weight = 1
+		}
+		tenants[tenantKey] = weight
+	}
+
+	return tenants, nil
+}
+
+func (r *RedisStore) ClaimNext(ctx context.Context, tenantKey string, visibility time.Duration) (*WaitingJob, error) {
+	jobs, err := r.claimWaiting(ctx, deferredTenantQueueKey(tenantKey), "+inf", 1, visibility)
+	if err != nil || len(jobs) == 0 {
+		return nil, err
+	}
+	return &jobs[0], nil
+}
+
+func (r *RedisStore) ClaimExpired(ctx context.Context, now time.Time, limit int, visibility time.Duration) ([]WaitingJob, error) {
+	return r.claimWaiting(ctx, deferredExpiryKey, strconv.FormatInt(now.UnixMilli(), 10), limit, visibility)
+}
+
+func (r *RedisStore) claimWaiting(ctx context.Context, source, maxScore string, limit int, visibility time.Duration) ([]WaitingJob, error) {
+	now := time.Now()
+
+	res, err := r.run(ctx, "wait_claim", waitClaimScript,
+		[]string{source, deferredEntriesKey, deferredTenantOfKey, deferredClaimsKey},
+		now.UnixMilli(), maxScore, limit, now.Add(visibility).UnixMilli(),
+	).StringSlice()
+	if err != nil {
+		return nil, err
+	}
+
+	jobs := make([]WaitingJob, 0, len(res)/3)
+	for i := 0; i+2 < len(res); i += 3 {
+		ownerID, jobID := splitOwnedMember(res[i])
+		jobs = append(jobs, WaitingJob{JobID: jobID, OwnerID: ownerID, TenantKey: res[i+1], Entry: []byte(res[i+2])})
+	}
+
+	return jobs, nil
+}
+
+func (r *RedisStore) Requeue(ctx context.Context, job WaitingJob) error {
+	member := ownedMember(job.OwnerID, job.JobID)
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, deferredEntriesKey, member, job.Entry)
+		pipe.ZRem(ctx, deferredClaimsKey, member)
+		return nil
+	})
+	return err
+}
+
+func (r *RedisStore) Release(ctx context.Context, job WaitingJob) (bool, error) {
+	return r.removeWaiting(ctx, job, time.Time{})
+}
+
+func (r *RedisStore) WithdrawWaiting(ctx context.Context, job WaitingJob) (bool, error) {
+	return r.removeWaiting(ctx, job, time.Now())
+}
+
+// removeWaiting removes the job unless, with now set, a claim on it is live
+func (r *RedisStore) removeWaiting(ctx context.Context, job WaitingJob, now time.Time) (bool, error) {
+	var nowMs int64
+	if !now.IsZero() {
+		nowMs = now.UnixMilli()
+	}
+
+	res, err := r.run(ctx, "wait_release", waitReleaseScript,
+		[]string{deferredEntriesKey, deferredTenantOfKey, deferredExpiryKey, deferredTenantsKey, deferredTenantQueueKey(job.TenantKey), deferredClaimsKey},
+		ownedMember(job.OwnerID, job.JobID), job.TenantKey, nowMs,
+	).Int()
+	if err != nil {
+		return false, err
+	}
+	if res < 0 {
+		return false, ErrClaimed
+	}
+	return res == 1, nil
+}
+
+func (r *RedisStore) GetWaiting(ctx context.Context, ownerID, jobID string) (*WaitingJob, error) {
+	member := ownedMember(ownerID, jobID)
+	var entry, tenantKey *redis.StringCmd
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		entry = pipe.HGet(ctx, deferredEntriesKey, member)
+		tenantKey = pipe.HGet(ctx, deferredTenantOfKey, member)
+		return nil
+	})
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &WaitingJob{JobID: jobID, OwnerID: ownerID, TenantKey: tenantKey.Val(), Entry: []byte(entry.Val())}, nil
+}
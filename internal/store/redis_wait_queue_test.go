@@ -0,0 +1,134 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func deferTestJob(t *testing.T, s *RedisStore, id, tenant string, rank float64, weight int) WaitingJob {
+	t.Helper()
+	job := WaitingJob{
+		JobID:     id,
+		OwnerID:   "owner",
+		TenantKey: tenant,
+		Entry:     []byte(id),
+		Rank:      rank,
+		Weight:    weight,
+		ExpiresAt: time.Now().Add(time.Hour),
+	}
+	if err := s.Defer(context.Background(), job); err != nil {
+		t.Fatal(err)
+	}
+	return job
+}
+
+func TestClaimNextFollowsRank(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newTestStore(t)
+
+	deferTestJob(t, s, "late", "owner:a", 300, 2)
+	deferTestJob(t, s, "urgent", "owner:a", 100, 2)
+	deferTestJob(t, s, "other", "owner:b", 50, 1)
+
+	tenants, err := s.ActiveTenants(ctx)
+	if err != nil || tenants["owner:a"] != 2 || tenants["owner:b"] != 1 {
+		t.Fatalf("tenants %v, %v", tenants, err)
+	}
+
+	first, err := s.ClaimNext(ctx, "owner:a", time.Minute)
+	if err != nil || first == nil || first.JobID != "urgent" || string(first.Entry) != "urgent" {
+		t.Fatalf("first claim %+v, %v, want urgent", first, err)
+	}
+
+	// The claimed head is hidden, the next one comes up
+	second, err := s.ClaimNext(ctx, "owner:a", time.Minute)
+	if err != nil || second == nil || second.JobID != "late" {
+		t.Fatalf("second claim %+v, %v, want late", second, err)
+	}
+
+	none, err := s.ClaimNext(ctx, "owner:a", time.Minute)
+	if err != nil || none != nil {
+		t.Fatalf("third claim %+v, %v, want nothing", none, err)
+	}
+}
+
+func TestClaimRunsOut(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newTestStore(t)
+	deferTestJob(t, s, "job-1", "owner:a", 1, 1)
+
+	if job, _ := s.ClaimNext(ctx, "owner:a", time.Millisecond); job == nil {
+		t.Fatal("nothing claimed")
+	}
+	time.Sleep(5 * time.Millisecond)
+
+	job, err := s.ClaimNext(ctx, "owner:a", time.Minute)
+	if err != nil || job == nil || job.JobID != "job-1" {
+		t.Fatalf("after the claim ran out got %+v, %v", job, err)
+	}
+}
+
+func TestRequeueDropsClaim(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newTestStore(t)
+	deferTestJob(t, s, "job-1", "owner:a", 1, 1)
+
+	job, _ := s.ClaimNext(ctx, "owner:a", time.Minute)
+	job.Entry = []byte("updated")
+	if err := s.Requeue(ctx, *job); err != nil {
+		t.Fatal(err)
+	}
+
+	again, err := s.ClaimNext(ctx, "owner:a", time.Minute)
+	if err != nil || again == nil || string(again.Entry) != "updated" {
+		t.Fatalf("after requeue got %+v, %v", again, err)
+	}
+}
+
+func TestWithdrawWaiting(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newTestStore(t)
+
+	free := deferTestJob(t, s, "free", "owner:a", 100, 1)
+	if removed, err := s.WithdrawWaiting(ctx, free); err != nil || !removed {
+		t.Fatalf("unclaimed job: removed=%v err=%v", removed, err)
+	}
+
+	claimed := deferTestJob(t, s, "claimed", "owner:a", 100, 1)
+	if _, err := s.ClaimNext(ctx, "owner:a", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.WithdrawWaiting(ctx, claimed); !errors.Is(err, ErrClaimed) {
+		t.Fatalf("claimed job got %v, want ErrClaimed", err)
+	}
+	if removed, err := s.Release(ctx, claimed); err != nil || !removed {
+		t.Fatalf("Release: removed=%v err=%v", removed, err)
+	}
+}
+
+func TestWaitingJobsAreScopedToOwner(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newTestStore(t)
+
+	for _, owner := range []string{"a", "a:b"} {
+		job := WaitingJob{JobID: "job-1", OwnerID: owner, TenantKey: OwnerKey(owner) + ":t", Entry: []byte(owner), ExpiresAt: time.Now().Add(time.Hour)}
+		if err := s.Defer(ctx, job); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	first, err := s.GetWaiting(ctx, "a", "job-1")
+	if err != nil || first == nil || string(first.Entry) != "a" {
+		t.Fatalf("owner a's job %+v, %v", first, err)
+	}
+	if removed, err := s.WithdrawWaiting(ctx, *first); err != nil || !removed {
+		t.Fatalf("WithdrawWaiting: removed=%v err=%v", removed, err)
+	}
+
+	other, err := s.ClaimNext(ctx, OwnerKey("a:b")+":t", time.Minute)
+	if err != nil || other == nil || other.OwnerID != "a:b" || other.JobID != "job-1" || string(other.Entry) != "a:b" {
+		t.Fatalf("the other owner's job got %+v, %v", other, err)
+	}
+}
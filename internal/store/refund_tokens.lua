@@ -0,0 +1,16 @@
+-- Gives tokens back to buckets, never beyond capacity.
+
+-- KEYS: [tokens_key_1, tokens_key_2, ...]
+-- ARGV: [cap1, cost1, cap2, cost2, ...]
+
+for i = 1, #KEYS do
+    local tokens = tonumber(redis.call("get", KEYS[i]))
+    -- A missing bucket starts full on its next use
+    if tokens ~= nil then
+        local capacity = tonumber(ARGV[(i - 1) * 2 + 1])
+        local cost = tonumber(ARGV[(i - 1) * 2 + 2])
+        redis.call("set", KEYS[i], math.min(capacity, tokens + cost))
+    end
+end
+
+return 1
@@ -0,0 +1,27 @@
+-- Claims due scheduled jobs without removing them.
+-- A claim pushes the job's run time to the claim deadline, so a crashed claimer's jobs come due again.
+-- The claim is also kept in claims_zset, where withdrawals check for it.
+
+-- KEYS: [schedule_zset, entries_hash, claims_zset]
+-- ARGV: [now_ms, limit, claim_until_ms]
+-- Returns: [member_1, entry_1, member_2, ...], members are owner:job_id
+
+local due = redis.call("zrangebyscore", KEYS[1], "-inf", ARGV[1], "LIMIT", 0, tonumber(ARGV[2]))
+
+local result = {}
+
+for _, id in ipairs(due) do
+    local entry = redis.call("hget", KEYS[2], id)
+    if entry then
+        redis.call("zadd", KEYS[1], ARGV[3], id)
+        redis.call("zadd", KEYS[3], ARGV[3], id)
+        result[#result + 1] = id
+        result[#result + 1] = entry
+    else
+        -- Entry already removed, drop the orphaned index
+        redis.call("zrem", KEYS[1], id)
+        redis.call("zrem", KEYS[3], id)
+    end
+end
+
+return result
@@ -0,0 +1,28 @@
+-- Removes a scheduled job. With now_ms set the job is only removed if no claim
+-- on it is live, so a withdrawal cannot take a job the scheduler is releasing.
+
+-- KEYS: [schedule_zset, entries_hash, claims_zset]
+-- ARGV: [member (owner:job_id), now_ms (0 = ignore claims)]
+-- Returns: 1 removed, 0 not scheduled, -1 claimed
+
+local job_id = ARGV[1]
+local now_ms = tonumber(ARGV[2])
+
+if redis.call("hexists", KEYS[2], job_id) == 0 then
+    redis.call("zrem", KEYS[1], job_id)
+    redis.call("zrem", KEYS[3], job_id)
+    return 0
+end
+
+if now_ms > 0 then
+    local claim_until = tonumber(redis.call("zscore", KEYS[3], job_id))
+    if claim_until and claim_until > now_ms then
+        return -1
+    end
+end
+
+redis.call("zrem", KEYS[1], job_id)
+redis.call("hdel", KEYS[2], job_id)
+redis.call("zrem", KEYS[3], job_id)
+
+return 1
@@ -0,0 +1,35 @@
+package store
+
+import (
+	"context"
+	"testing"
+)
+
+func TestLoadScriptsOnlyLoadsMissing(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newTestStore(t)
+
+	missing, err := s.MissingScripts(ctx)
+	if err != nil || len(missing) != len(scripts) {
+		t.Fatalf("fresh Redis: %d of %d scripts missing, %v", len(missing), len(scripts), err)
+	}
+
+	total, loaded, err := s.LoadScripts(ctx)
+	if err != nil || total != len(scripts) || loaded != len(scripts) {
+		t.Fatalf("LoadScripts = %d, %d, %v", total, loaded, err)
+	}
+	if missing, _ := s.MissingScripts(ctx); len(missing) != 0 {
+		t.Fatalf("still missing %v after loading", missing)
+	}
+
+	if _, loaded, _ := s.LoadScripts(ctx); loaded != 0 {
+		t.Fatalf("loaded %d scripts again", loaded)
+	}
+
+	if err := s.client.ScriptFlush(ctx).Err(); err != nil {
+		t.Fatal(err)
+	}
+	if missing, _ := s.MissingScripts(ctx); len(missing) != len(scripts) {
+		t.Fatalf("after SCRIPT FLUSH %d missing, want all", len(missing))
+	}
+}
@@ -2,6 +2,8 @@ package store
 
 import (
 	"context"
+	"errors"
+	"strings"
 	"time"
 )
 
@@ -10,8 +12,9 @@ type StateStore interface {
 	// Ping checks the connection to the store
 	Ping(ctx context.Context) error
 
-	// CheckAndMarkAdmitted return true if the jobID was already seen within the window
-	CheckAndMarkAdmitted(ctx context.Context, jobID string, window time.Duration) (bool, error)
+	// CheckAndMarkAdmitted return true if the idempotency key was already seen within the window.
+	// Keys are built by the caller and must be scoped to the job's owner.
+	CheckAndMarkAdmitted(ctx context.Context, key string, window time.Duration) (bool, error)
 
 	// If a job requiring a certain external dependency is submitted, can it run or not based on how many jobs already queued for that service per second.
 
@@ -20,12 +23,24 @@ type StateStore interface {
 	//Flush the datastore - USE WITH CAUTION
 	Flush(ctx context.Context) error
 
+	// ResetQuotas drops the owner's token buckets so new limits apply immediately.
+	// Unlike Flush it keeps idempotency keys, queued jobs and other owners' buckets.
+	ResetQuotas(ctx context.Context, ownerID string) error
+
 	//AllowRequestTokenBucket checks usage against a refillable quota (Token Bucket)
 
 	AllowRequestTokenBucket(ctx context.Context, key string, capacity int, refillRate float64, cost int) (bool, error)
 
 	AllowRequestAtomic(ctx context.Context, reqs []RateLimitReq) (bool, error)
 
+	// AllowBatchPartial evaluates an ordered batch in one round trip, admitting every job
+	// that fits and skipping the rest. Idempotency is checked and recorded in the same call.
+	AllowBatchPartial(ctx context.Context, jobs []BatchAdmissionReq) ([]BatchAdmissionResult, error)
+
+	// AllowBatchPrefix is AllowBatchPartial that stops at the first job that does not fit,
+	// admitting the longest in-order prefix. Jobs after it are returned as Skipped.
+	AllowBatchPrefix(ctx context.Context, jobs []BatchAdmissionReq) ([]BatchAdmissionResult, error)
+
 	// AllowBurstSmoothing checks if enough time has passed since the last request (Standalone)
 	AllowBurstSmoothing(ctx context.Context, key string, minIntervalSeconds float64) (bool, error)
 
@@ -33,7 +48,19 @@ type StateStore interface {
 	// Returns true if the job was just qurantined
 
 	// ClearIdempotency removes the idempotency key (used for retries)
-	ClearIdempotency(ctx context.Context, jobID string) error
+	ClearIdempotency(ctx context.Context, key string) error
+
+	// GetIdempotencyRecord returns the decision stored under the idempotency key.
+	// Returns nil if the key is missing or still holds the bare admission marker.
+	GetIdempotencyRecord(ctx context.Context, key string) ([]byte, error)
+
+	// SaveIdempotencyRecord replaces the admission marker with the original decision,
+	// keeping the TTL set by CheckAndMarkAdmitted
+	SaveIdempotencyRecord(ctx context.Context, key string, record []byte) error
+
+	// RefundTokens gives each request's cost back to its token bucket, capped at capacity.
+	// Buckets that no longer exist are already full and are left alone.
+	RefundTokens(ctx context.Context, reqs []RateLimitReq) error
 }
 
 type RateLimitReq struct {
@@ -44,3 +71,267 @@ type RateLimitReq struct {
 	MinInterval float64
 	WarmupMs    int64
 }
+
+// BatchAdmissionReq is one job of a batch evaluated by AllowBatchPartial
+type BatchAdmissionReq struct {
+	IdempotencyKey    string
+	IdempotencyWindow time.Duration
+	Record            []byte // stored under IdempotencyKey if the job is admitted
+	Reqs              []RateLimitReq
+}
+
+type BatchAdmissionResult struct {
+	Admitted  bool
+	Duplicate bool
+	Skipped   bool   // not evaluated because an earlier job ended the prefix
+	Prior     []byte // idempotency value left by the original submission, for duplicates
+}
+
+// WaitQueue durably holds deferred jobs until they are re-evaluated.
+// Jobs are queued per tenant so they can be released fairly across tenants.
+type WaitQueue interface {
+	// Defer adds the job to its tenant's queue and registers the tenant's weight
+	Defer(ctx context.Context, job WaitingJob) error
+
+	// ActiveTenants returns every tenant with waiting jobs and its weight
+	ActiveTenants(ctx context.Context) (map[string]int, error)
+
+	// ClaimNext returns the lowest ranked unclaimed job of a tenant, or nil if there is none.
+	// A claimed job stays queued but is hidden for visibility, so jobs claimed
+	// by an instance that dies are picked up again.
+	ClaimNext(ctx context.Context, tenantKey string, visibility time.Duration) (*WaitingJob, error)
+
+	// ClaimExpired claims up to limit jobs whose ExpiresAt has passed
+	ClaimExpired(ctx context.Context, now time.Time, limit int, visibility time.Duration) ([]WaitingJob, error)
+
+	// Requeue stores the job's updated entry and drops its claim
+	Requeue(ctx context.Context, job WaitingJob) error
+
+	// Release removes a job its caller claimed from the wait queue. It returns false
+	// if the job was no longer waiting, withdrawn or released by someone else.
+	Release(ctx context.Context, job WaitingJob) (bool, error)
+
+	// WithdrawWaiting removes the job unless it is claimed, returning ErrClaimed if it is
+	// and false if it was not waiting
+	WithdrawWaiting(ctx context.Context, job WaitingJob) (bool, error)
+
+	// GetWaiting returns the owner's job's queue entry, or nil if the job is not waiting
+	GetWaiting(ctx context.Context, ownerID, jobID string) (*WaitingJob, error)
+}
+
+type WaitingJob struct {
+	JobID     string
+	OwnerID   string // job IDs are only unique per owner
+	TenantKey string // owner scoped tenant, the unit of fair scheduling
+	Entry     []byte
+	Rank      float64 // lower is released first within the tenant
+	Weight    int     // tenant's share of released capacity
+	ExpiresAt time.Time
+}
+
+// ScheduleQueue durably holds jobs submitted with a future not_before
+type ScheduleQueue interface {
+	// Schedule stores the job until RunAt, replacing an earlier entry for the same job
+	Schedule(ctx context.Context, job ScheduledJob) error
+
+	// ClaimDue hides up to limit jobs whose RunAt has passed for the visibility period
+	// and returns them. Claimed jobs come due again unless Unschedule is called.
+	ClaimDue(ctx context.Context, now time.Time, limit int, visibility time.Duration) ([]ScheduledJob, error)
+
+	// Unschedule removes a job its caller claimed once it has been handled. It returns
+	// false if the job was no longer scheduled, withdrawn or handled by someone else.
+	Unschedule(ctx context.Context, ownerID, jobID string) (bool, error)
+
+	// WithdrawScheduled removes the job unless it is claimed, returning ErrClaimed if it is
+	// and false if it was not scheduled
+	WithdrawScheduled(ctx context.Context, ownerID, jobID string) (bool, error)
+
+	// GetScheduled returns the owner's job's entry, or nil if the job is not scheduled
+	GetScheduled(ctx context.Context, ownerID, jobID string) (*ScheduledJob, error)
+}
+
+type ScheduledJob struct {
+	JobID   string
+	OwnerID string // job IDs are only unique per owner
+	Entry   []byte // opaque to the store
+	RunAt   time.Time
+}
+
+// ReadyQueue durably holds admitted jobs per owner until a worker leases them
+type ReadyQueue interface {
+	// Enqueue makes the job leasable from AvailableAt
+	Enqueue(ctx context.Context, job ReadyJob) error
+
+	// Lease hands out up to len(leaseIDs) available jobs matching the filter, oldest first.
+	// Each lease expires after ttl unless extended, and never lives past maxLease (0 = no bound).
+	Lease(ctx context.Context, ownerID string, filter LeaseFilter, leaseIDs []string, ttl, maxLease time.Duration) ([]Lease, error)
+
+	// ExtendLease pushes the lease deadline to now plus extend, capped at the lease's ttl
+	// (which extend 0 stands for) and its MaxDeadline. Deadlines only move forward.
+	// The returned Lease carries the deadlines, started is true on the lease's first extension.
+	ExtendLease(ctx context.Context, ownerID, leaseID string, extend time.Duration) (l *Lease, started bool, err error)
+
+	// GetLease returns a live lease
+	GetLease(ctx context.Context, ownerID, leaseID string) (*Lease, error)
+
+	// CompleteLease ends the lease and removes the job from the queue
+	CompleteLease(ctx context.Context, ownerID, leaseID string) (*Lease, error)
+
+	// FailLease ends the lease if it still holds jobID and appends attempt to the job's
+	// history in the same step. The job is leasable again from retryAt, or removed from
+	// the queue if retryAt is zero. A lease already ended by the reaper or another
+	// report returns ErrLeaseNotFound and changes nothing.
+	FailLease(ctx context.Context, ownerID, leaseID, jobID string, attempt []byte, retryAt time.Time) (*Lease, error)
+
+	// LeaseOwners returns the owners that currently hold leases
+	LeaseOwners(ctx context.Context) ([]string, error)
+
+	// ReapExpired ends up to limit leases whose deadline has passed. Jobs with attempts
+	// left are leasable again after hold, the others are removed and returned with Dead set.
+	ReapExpired(ctx context.Context, ownerID string, limit int, hold time.Duration) ([]Lease, error)
+
+	// DelayReady moves a queued job that is not leased to be leasable from at
+	DelayReady(ctx context.Context, ownerID, jobID string, at time.Time) error
+
+	// AddAttempt appends a failed attempt to the job's history and returns the whole history
+	AddAttempt(ctx context.Context, ownerID, jobID string, entry []byte) ([][]byte, error)
+
+	// Attempts returns the job's failure history, oldest first
+	Attempts(ctx context.Context, ownerID, jobID string) ([][]byte, error)
+
+	// ClearAttempts drops the job's history once it has left the queue
+	ClearAttempts(ctx context.Context, ownerID, jobID string) error
+
+	// RemoveReady takes the job out of the queue, ending its lease if it holds one.
+	// The returned Lease has LeaseID set only if the job was leased, and Attempt
+	// counts the executions so far. Returns nil if the job is not queued.
+	RemoveReady(ctx context.Context, ownerID, jobID string) (*Lease, error)
+}
+
+// ErrClaimed is returned when withdrawing a job that is being released
+var ErrClaimed = errors.New("job is claimed")
+
+// ErrLeaseNotFound is returned for leases that expired, finished or never existed
+var ErrLeaseNotFound = errors.New("lease not found")
+
+var (
+	ownerEscaper   = strings.NewReplacer("%", "%25", ":", "%3A")
+	ownerUnescaper = strings.NewReplacer("%3A", ":", "%25", "%")
+)
+
+// OwnerKey is the owner ID as it appears in keys and members joined with ":".
+// The ID is escaped so it contains no ":", and one owner's keys never start with another's.
+func OwnerKey(ownerID string) string {
+	return ownerEscaper.Replace(ownerID)
+}
+
+// ownedMember names a job in queues shared by every owner
+func ownedMember(ownerID, jobID string) string {
+	return OwnerKey(ownerID) + ":" + jobID
+}
+
+// splitOwnedMember is the inverse of ownedMember
+func splitOwnedMember(member string) (ownerID, jobID string) {
+	owner, jobID, _ := strings.Cut(member, ":")
+	return ownerUnescaper.Replace(owner), jobID
+}
+
+type ReadyJob struct {
+	JobID        string
+	OwnerID      string
+	TenantID     string
+	Dependencies []string
+	MaxAttempts  int
+	Job          []byte // spec.EncodeJob
+	AvailableAt  time.Time
+}
+
+// LeaseFilter narrows which jobs a worker receives. Empty fields match everything.
+type LeaseFilter struct {
+	TenantID   string
+	Dependency string
+}
+
+type Lease struct {
+	LeaseID  string
+	JobID    string
+	OwnerID  string
+	Attempt  int // 1 on the first execution
+	Deadline time.Time
+	Dead     bool // set by ReapExpired when no attempts are left
+	Job      []byte
+
+	MaxDeadline time.Time // zero when heartbeats can extend the lease indefinitely
+}
+
+// AdmittedStore remembers accepted jobs that were not dispatched for a while, so
+// the owner can still cancel them and get their tokens back
+type AdmittedStore interface {
+	// RememberAdmitted keeps the job for ttl, replacing an earlier entry for the same job
+	RememberAdmitted(ctx context.Context, ownerID, jobID string, job []byte, ttl time.Duration) error
+
+	// TakeAdmitted removes and returns the job, nil if it is not remembered (anymore).
+	// Of concurrent calls for the same job only one gets it.
+	TakeAdmitted(ctx context.Context, ownerID, jobID string) ([]byte, error)
+}
+
+// DeadLetterStore keeps jobs that will not run again, per owner, until they are redriven or purged
+type DeadLetterStore interface {
+	// AddDeadLetter stores the item, replacing an earlier one for the same job
+	AddDeadLetter(ctx context.Context, item DeadLetterItem) error
+
+	// ListDeadLetters returns up to limit items from offset, newest first, and the total count
+	ListDeadLetters(ctx context.Context, ownerID string, offset, limit int) ([]DeadLetterItem, int64, error)
+
+	// GetDeadLetter returns nil if the job is not dead-lettered
+	GetDeadLetter(ctx context.Context, ownerID, jobID string) (*DeadLetterItem, error)
+
+	// RemoveDeadLetters deletes the given jobs and returns how many existed
+	RemoveDeadLetters(ctx context.Context, ownerID string, jobIDs []string) (int, error)
+
+	// PurgeDeadLetters deletes every item of the owner and returns how many there were
+	PurgeDeadLetters(ctx context.Context, ownerID string) (int, error)
+}
+
+type DeadLetterItem struct {
+	JobID   string
+	OwnerID string
+	DeadAt  time.Time
+	Entry   []byte // opaque to the store
+}
+
+// OutboxStore durably holds, per job, the decisions the database could not take
+// until they are relayed. A job is queued once however many of its decisions are held.
+type OutboxStore interface {
+	// AddToOutbox appends each entry to its job's outbox in order. Jobs not in the
+	// outbox yet are due at dueAt, the others keep their due time.
+	AddToOutbox(ctx context.Context, entries []OutboxEntry, dueAt time.Time) error
+
+	// InOutbox returns which of the jobs have entries in the outbox
+	InOutbox(ctx context.Context, jobIDs []string) (map[string]bool, error)
+
+	// ClaimOutbox returns up to limit jobs due by now with all their entries, oldest first.
+	// Claimed jobs come due again after visibility unless their entries are removed.
+	ClaimOutbox(ctx context.Context, now time.Time, limit int, visibility time.Duration) ([]OutboxJob, error)
+
+	// RemoveFromOutbox drops the job's first n entries, and the job once none are left.
+	// A job left with entries comes due at dueAt.
+	RemoveFromOutbox(ctx context.Context, jobID string, n int, dueAt time.Time) error
+
+	// ReplaceInOutbox drops the job's first n entries like RemoveFromOutbox, but puts keep
+	// back in their place and moves dead to the outbox's capped dead letters.
+	ReplaceInOutbox(ctx context.Context, jobID string, n int, keep, dead [][]byte, dueAt time.Time) error
+
+	// OutboxLen is the number of jobs in the outbox
+	OutboxLen(ctx context.Context) (int64, error)
+}
+
+type OutboxEntry struct {
+	JobID string
+	Entry []byte // opaque to the store
+}
+
+type OutboxJob struct {
+	JobID   string
+	Entries [][]byte
+}
@@ -0,0 +1,39 @@
+-- Claims jobs from a wait queue without removing them.
+-- A claim hides the job until it runs out, so a crashed claimer's jobs reappear.
+-- Used for the head of a tenant queue (by rank) and for expired jobs (by expiry).
+
+-- KEYS: [source_zset, entries_hash, tenant_of_hash, claims_zset]
+-- ARGV: [now_ms, max_score, limit, claim_until_ms]
+-- Returns: [member_1, tenant_key_1, entry_1, member_2, ...], members are owner:job_id
+
+local now_ms = tonumber(ARGV[1])
+local limit = tonumber(ARGV[3])
+
+-- Scan past claimed jobs, bounded so a large backlog stays cheap
+local candidates = redis.call("zrangebyscore", KEYS[1], "-inf", ARGV[2], "LIMIT", 0, limit + 100)
+
+local result = {}
+local claimed = 0
+
+for _, id in ipairs(candidates) do
+    if claimed >= limit then
+        break
+    end
+
+    local claim_until = tonumber(redis.call("zscore", KEYS[4], id))
+    if claim_until == nil or claim_until <= now_ms then
+        local entry = redis.call("hget", KEYS[2], id)
+        if entry then
+            redis.call("zadd", KEYS[4], ARGV[4], id)
+            result[#result + 1] = id
+            result[#result + 1] = redis.call("hget", KEYS[3], id) or ""
+            result[#result + 1] = entry
+            claimed = claimed + 1
+        else
+            -- Entry already released, drop the orphaned index
+            redis.call("zrem", KEYS[1], id)
+        end
+    end
+end
+
+return result
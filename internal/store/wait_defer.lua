@@ -0,0 +1,14 @@
+-- Adds a deferred job to its tenant queue.
+
+-- KEYS: [entries_hash, tenant_of_hash, expiry_zset, tenants_hash, tenant_queue_zset]
+-- ARGV: [member (owner:job_id), entry, tenant_key, rank, expires_at_ms, weight]
+
+local job_id = ARGV[1]
+
+redis.call("hset", KEYS[1], job_id, ARGV[2])
+redis.call("hset", KEYS[2], job_id, ARGV[3])
+redis.call("zadd", KEYS[3], ARGV[5], job_id)
+redis.call("hset", KEYS[4], ARGV[3], ARGV[6])
+redis.call("zadd", KEYS[5], ARGV[4], job_id)
+
+return 1
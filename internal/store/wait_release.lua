@@ -0,0 +1,33 @@
+-- Removes a job from the wait queue, dropping its tenant once the tenant queue is empty.
+-- With now_ms set the job is only removed if no claim on it is live, so a withdrawal
+-- cannot take a job the releaser is working on.
+
+-- KEYS: [entries_hash, tenant_of_hash, expiry_zset, tenants_hash, tenant_queue_zset, claims_zset]
+-- ARGV: [member (owner:job_id), tenant_key, now_ms (0 = ignore claims)]
+-- Returns: 1 removed, 0 not waiting, -1 claimed
+
+local job_id = ARGV[1]
+local now_ms = tonumber(ARGV[3])
+
+if redis.call("hexists", KEYS[1], job_id) == 0 then
+    return 0
+end
+
+if now_ms > 0 then
+    local claim_until = tonumber(redis.call("zscore", KEYS[6], job_id))
+    if claim_until and claim_until > now_ms then
+        return -1
+    end
+end
+
+redis.call("hdel", KEYS[1], job_id)
+redis.call("hdel", KEYS[2], job_id)
+redis.call("zrem", KEYS[3], job_id)
+redis.call("zrem", KEYS[5], job_id)
+redis.call("zrem", KEYS[6], job_id)
+
+if redis.call("zcard", KEYS[5]) == 0 then
+    redis.call("hdel", KEYS[4], ARGV[2])
+end
+
+return 1
@@ -0,0 +1,102 @@
+// Package tracing sets up OpenTelemetry for Janus and holds the helpers its spans are made with
+package tracing
+
+import (
+	"context"
+	"os"
+
+	"github.com/satyamraj1643/janus/spec"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("github.com/satyamraj1643/janus")
+
+// Init installs the W3C trace context propagator and, when OTEL_EXPORTER_OTLP_ENDPOINT
+// is set (e.g. http://localhost:4318), exports spans over OTLP/HTTP.
+// Without an endpoint incoming trace IDs are still propagated, no spans are recorded.
+// The returned function flushes pending spans.
+func Init(ctx context.Context) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	if os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") == "" && os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	exporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES override the defaults
+	res, err := resource.New(ctx,
+		resource.WithAttributes(attribute.String("service.name", "janus")),
+		resource.WithFromEnv(),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+
+	return provider.Shutdown, nil
+}
+
+// Start begins a span as a child of the one in ctx
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return tracer.Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End marks the span failed when err is set and ends it
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// Stamp attaches the trace of ctx to a decision that has none yet, so the
+// DB writer can link its save to the originating request
+func Stamp(ctx context.Context, decision *spec.JobDecision) {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() || decision.TraceID != "" {
+		return
+	}
+
+	carrier := propagation.MapCarrier{}
+	propagation.TraceContext{}.Inject(ctx, carrier)
+
+	decision.TraceID = sc.TraceID().String()
+	decision.TraceParent = carrier.Get("traceparent")
+}
+
+// StartLinked begins a span in a new trace, linked to the requests the decisions came from
+func StartLinked(ctx context.Context, name string, decisions []*spec.JobDecision, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	var links []trace.Link
+	for _, decision := range decisions {
+		if decision.TraceParent == "" {
+			continue
+		}
+		origin := propagation.TraceContext{}.Extract(ctx, propagation.MapCarrier{"traceparent": decision.TraceParent})
+		if sc := trace.SpanContextFromContext(origin); sc.IsValid() {
+			links = append(links, trace.Link{SpanContext: sc})
+		}
+	}
+
+	opts := []trace.SpanStartOption{trace.WithNewRoot(), trace.WithAttributes(attrs...), trace.WithLinks(links...)}
+	return tracer.Start(ctx, name, opts...)
+}
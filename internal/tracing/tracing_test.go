@@ -0,0 +1,83 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+
+	"github.com/satyamraj1643/janus/spec"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// recorder keeps the spans the package tracer ends
+var recorder = tracetest.NewSpanRecorder()
+
+func init() {
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+}
+
+func TestStamp(t *testing.T) {
+	ctx, span := Start(context.Background(), "request")
+	defer span.End()
+
+	decision := &spec.JobDecision{JobID: "job-1"}
+	Stamp(ctx, decision)
+
+	sc := span.SpanContext()
+	want := "00-" + sc.TraceID().String() + "-" + sc.SpanID().String() + "-01"
+	if decision.TraceID != sc.TraceID().String() || decision.TraceParent != want {
+		t.Fatalf("stamped %q / %q, want %s", decision.TraceID, decision.TraceParent, want)
+	}
+
+	// A decision keeps the trace it was first stamped with
+	other, otherSpan := Start(context.Background(), "other")
+	defer otherSpan.End()
+	Stamp(other, decision)
+	if decision.TraceParent != want {
+		t.Fatalf("restamped with %q", decision.TraceParent)
+	}
+
+	untraced := &spec.JobDecision{}
+	Stamp(context.Background(), untraced)
+	if untraced.TraceID != "" || untraced.TraceParent != "" {
+		t.Fatalf("stamped %+v without a trace", untraced)
+	}
+}
+
+func TestTraceParentSurvivesEncoding(t *testing.T) {
+	ctx, span := Start(context.Background(), "request")
+	span.End()
+
+	decision := &spec.JobDecision{JobID: "job-1", Job: spec.Job{ID: "job-1", OwnerID: "owner-a"}}
+	Stamp(ctx, decision)
+
+	raw, err := spec.EncodeDecision(decision)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := spec.DecodeDecision(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded.TraceParent != decision.TraceParent || decoded.TraceID != decision.TraceID || decoded.Job.OwnerID != "owner-a" {
+		t.Fatalf("decoded %+v, want the trace and owner kept", decoded)
+	}
+
+	_, save := StartLinked(context.Background(), "save", []*spec.JobDecision{decoded, {JobID: "untraced"}})
+	save.End()
+
+	ended := recorder.Ended()
+	last := ended[len(ended)-1]
+	if last.Name() != "save" || last.Parent().IsValid() {
+		t.Fatalf("span %q with parent %v, want a new root", last.Name(), last.Parent())
+	}
+	links := last.Links()
+	if len(links) != 1 || links[0].SpanContext.TraceID() != span.SpanContext().TraceID() {
+		t.Fatalf("links %+v, want the originating request", links)
+	}
+	if !links[0].SpanContext.Equal(trace.SpanContextFromContext(ctx).WithRemote(true)) {
+		t.Fatalf("linked %+v, want the request's span", links[0].SpanContext)
+	}
+}
@@ -2,55 +2,128 @@ package listener
 
 import (
 	"context"
-	"log"
+	"log/slog"
+	"time"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/satyamraj1643/janus/db"
 	configStore "github.com/satyamraj1643/janus/globalStore"
+	"github.com/satyamraj1643/janus/internal/logging"
+	"github.com/satyamraj1643/janus/internal/metrics"
 	"github.com/satyamraj1643/janus/internal/store"
 )
 
-func StartConfigListener(dbURL string, s store.StateStore) {
-	go func() {
-		ctx := context.Background()
+const maxReconnectDelay = 30 * time.Second
 
-		conn, err := pgx.Connect(ctx, dbURL)
-		if err != nil {
-			log.Fatal("Failed to connect for LISTEN:", err)
-		}
+// StartConfigListener keeps the config cache in step with janus_config_update
+// notifications until ctx is done; the returned channel is closed once its connection is.
+func StartConfigListener(ctx context.Context, dbURL string, s store.StateStore) <-chan struct{} {
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
 
-		_, err = conn.Exec(ctx, "LISTEN janus_config_update")
+		conn, err := listen(ctx, dbURL)
 		if err != nil {
-			log.Fatal("LISTEN failed:", err)
+			logging.Fatal("LISTEN failed", "err", err)
 		}
 
-		log.Println("Listening for janus_config_update")
+		setState(true, nil)
+		slog.Info("listening for janus_config_update")
 
 		for {
-			notification, err := conn.WaitForNotification(ctx)
-			if err != nil {
-				log.Println("Notification error:", err)
-				continue
+			err := handleNotifications(ctx, conn, s)
+			conn.Close(context.Background())
+
+			if ctx.Err() != nil {
+				setState(false, ctx.Err())
+				slog.Info("config listener stopped")
+				return
 			}
 
-			userID := notification.Payload
-			log.Println("Config update for user:", userID)
+			setState(false, err)
+			slog.Error("config listener: notification error", "err", err)
 
-			cfg, cfgID, ok, err := db.GetActiveJanusConfig(userID)
-			if err != nil || !ok {
-				configStore.Delete(userID)
-				// If config is deleted/inactive, we definitely want to clear old quotas?
-				// Maybe safe to flush here too, but critical when new config is loaded.
-				continue
+			if conn = reconnect(ctx, dbURL); conn == nil {
+				slog.Info("config listener stopped")
+				return
 			}
 
+			// Updates sent while disconnected were missed, reload configs from the DB
+			configStore.Clear()
+		}
+	}()
+
+	return done
+}
+
+func listen(ctx context.Context, dbURL string) (*pgx.Conn, error) {
+	conn, err := pgx.Connect(ctx, dbURL)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := conn.Exec(ctx, "LISTEN janus_config_update"); err != nil {
+		conn.Close(ctx)
+		return nil, err
+	}
+
+	return conn, nil
+}
+
+// reconnect retries listen with exponential backoff until it succeeds,
+// nil once ctx is done
+func reconnect(ctx context.Context, dbURL string) *pgx.Conn {
+	delay := time.Second
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(delay):
+		}
+
+		conn, err := listen(ctx, dbURL)
+		if err == nil {
+			metrics.ListenerReconnects.Inc()
+			setState(true, nil)
+			slog.Info("listening for janus_config_update again")
+			return conn
+		}
+
+		setState(false, err)
+		slog.Warn("LISTEN reconnect failed", "retry_in", delay, "err", err)
+		delay = min(delay*2, maxReconnectDelay)
+	}
+}
+
+// handleNotifications applies config updates until the connection fails
+func handleNotifications(ctx context.Context, conn *pgx.Conn, s store.StateStore) error {
+	for {
+		notification, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+
+		userID := notification.Payload
+		slog.Debug("config update received")
+
+		cfg, cfgID, ok, err := db.GetActiveJanusConfig(userID)
+		if err != nil {
+			// Read again on the next lookup, quotas are kept as the change is unknown
+			configStore.Delete(userID)
+			continue
+		}
+		if !ok {
+			configStore.Delete(userID)
+		} else {
 			configStore.Set(userID, cfg, cfgID)
+		}
 
-			// FLUSH Redis to ensure new limits take effect immediately
-			log.Printf("Flushing Redis state for fresh start for user %s (actually global flush)", userID)
-			if err := s.Flush(ctx); err != nil {
-				log.Printf("Failed to flush redis: %v", err)
-			}
+		// Reset the user's quota state so new limits take effect immediately.
+		// Idempotency keys, deferred jobs and other users' quotas survive the reset.
+		slog.Info("config changed, resetting Redis quota state", "user_id", userID)
+		if err := s.ResetQuotas(ctx, userID); err != nil {
+			slog.Error("resetting Redis quotas failed", "err", err)
 		}
-	}()
+	}
 }
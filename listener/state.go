@@ -0,0 +1,31 @@
+package listener
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// State is how the config listener's LISTEN connection is doing
+type State struct {
+	Connected bool      `json:"connected"`
+	Since     time.Time `json:"since"` // when it entered this state
+	LastError string    `json:"last_error,omitempty"`
+}
+
+var state atomic.Pointer[State]
+
+// CurrentState reports the listener's connection, not connected until it has started
+func CurrentState() State {
+	if s := state.Load(); s != nil {
+		return *s
+	}
+	return State{}
+}
+
+func setState(connected bool, err error) {
+	s := &State{Connected: connected, Since: time.Now()}
+	if err != nil {
+		s.LastError = err.Error()
+	}
+	state.Store(s)
+}
@@ -0,0 +1,15 @@
+package middleware
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/satyamraj1643/janus/internal/audit"
+)
+
+// Audited marks when the request arrived, audit records of its decisions report their latency from then
+func Audited(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(audit.WithStart(r.Context(), time.Now())))
+	})
+}
@@ -34,3 +34,9 @@ func GetActiveContext(ctx context.Context) (json.RawMessage, string, string, boo
 
 	return nil, "", "", false
 }
+
+// GetUserID returns the authenticated user ID set by ServiceRunningOnly or UserOnly
+func GetUserID(ctx context.Context) string {
+	userID, _ := ctx.Value(activeUserIDKey).(string)
+	return userID
+}
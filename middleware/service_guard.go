@@ -3,21 +3,25 @@ package middleware
 import (
 	"context"
 	"encoding/json"
-	"log"
 	"net/http"
 
 	db "github.com/satyamraj1643/janus/db"
 	configStore "github.com/satyamraj1643/janus/globalStore"
+	"github.com/satyamraj1643/janus/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 func ServiceRunningOnly(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 
+		// Ends before the handler runs, which becomes its sibling
+		_, span := tracing.Start(r.Context(), "ServiceRunningOnly")
+
 		userID := r.Header.Get("X-User-ID")
-		log.Printf("userid: %s", userID)
 		//userID := "2dad64a8-3f87-4d6e-9b4c-1cfa5917fd4b"
 
 		if userID == "" {
+			span.End()
 			http.Error(w, "Missing user id", http.StatusBadRequest)
 			return
 		}
@@ -25,17 +29,20 @@ func ServiceRunningOnly(next http.Handler) http.Handler {
 		running, errService := db.IsServiceRunning(userID)
 
 		if errService != nil {
+			tracing.End(span, errService)
 			http.Error(w, "Internal error", http.StatusInternalServerError)
 			return
 		}
 
 		if !running {
+			span.End()
 			http.Error(w, "Service is paused, please enable it from dashboard, then try running the service..", http.StatusForbidden)
 			return
 		}
 
 		// 1. Try cache
 		cached, found := configStore.Get(userID)
+		span.SetAttributes(attribute.Bool("janus.config_cached", found))
 
 		var activeConfig json.RawMessage
 		var activeConfigID string
@@ -50,6 +57,7 @@ func ServiceRunningOnly(next http.Handler) http.Handler {
 			var errConfig error
 			activeConfig, activeConfigID, activeConfigExists, errConfig = db.GetActiveJanusConfig(userID)
 			if errConfig != nil {
+				tracing.End(span, errConfig)
 				http.Error(w, "Internal error", http.StatusInternalServerError)
 				return
 			}
@@ -60,6 +68,8 @@ func ServiceRunningOnly(next http.Handler) http.Handler {
 			}
 		}
 
+		span.End()
+
 		if !activeConfigExists {
 			http.Error(w, "No active Janus config found, please create one from dashboard", http.StatusForbidden)
 			return
@@ -70,7 +80,6 @@ func ServiceRunningOnly(next http.Handler) http.Handler {
 		ctx = context.WithValue(ctx, activeUserIDKey, userID)
 		r = r.WithContext(ctx)
 
-		log.Printf("Forwarding request to respective handler ---")
 		next.ServeHTTP(w, r)
 	})
 }
@@ -0,0 +1,22 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+)
+
+// TokenOnly requires an Authorization: Bearer header carrying token.
+// Use it in front of routes that change what Janus enforces.
+func TokenOnly(token string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,23 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+)
+
+// UserOnly requires the X-User-ID header but, unlike ServiceRunningOnly,
+// lets paused users and users without an active config through.
+// Use it for read-only routes.
+func UserOnly(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		userID := r.Header.Get("X-User-ID")
+		if userID == "" {
+			http.Error(w, "Missing user id", http.StatusBadRequest)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), activeUserIDKey, userID)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
@@ -0,0 +1,124 @@
+// Package permit issues and verifies the execution permits Janus attaches to accepted jobs.
+//
+// A permit is an Ed25519 signed statement that Janus admitted a job, for which
+// dependencies and how many of their tokens, until when. Jobs run by workers get
+// a permit per lease, bound to the lease's attempt and deadline. Workers and dependency proxies hold
+// only the public key, so they can check permits offline but cannot mint them:
+//
+//	claims, err := permit.Verify(publicKey, token, time.Now())
+//	if err != nil {
+//		// refuse the job
+//	}
+//	if err := claims.Covers("payment_api", 1); err != nil {
+//		// refuse the call
+//	}
+package permit
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var (
+	ErrMalformed    = errors.New("permit: malformed")
+	ErrBadSignature = errors.New("permit: bad signature")
+	ErrExpired      = errors.New("permit: expired")
+	ErrNotCovered   = errors.New("permit: dependency not covered")
+)
+
+// Claims is what a permit vouches for
+type Claims struct {
+	JobID        string         `json:"job_id"`
+	OwnerID      string         `json:"owner_id"`
+	TenantID     string         `json:"tenant_id"`
+	Dependencies map[string]int `json:"dependencies,omitempty"` // dependency -> tokens admitted
+	LeaseID      string         `json:"lease_id,omitempty"`     // set for leased jobs
+	Attempt      int            `json:"attempt,omitempty"`      // the lease's attempt, from 1
+	IssuedAt     int64          `json:"iat"`                    // unix seconds
+	ExpiresAt    int64          `json:"exp"`                    // unix seconds
+}
+
+// Covers reports whether the permit admits cost tokens of the dependency
+func (c *Claims) Covers(dependency string, cost int) error {
+	if admitted, ok := c.Dependencies[dependency]; ok && cost <= admitted {
+		return nil
+	}
+	return fmt.Errorf("%w: %s x%d", ErrNotCovered, dependency, cost)
+}
+
+// Signer issues permits, Janus holds the only one
+type Signer struct {
+	key ed25519.PrivateKey
+}
+
+// NewSigner builds a signer from a 32 byte Ed25519 seed
+func NewSigner(seed []byte) (*Signer, error) {
+	if len(seed) != ed25519.SeedSize {
+		return nil, fmt.Errorf("permit: seed must be %d bytes, got %d", ed25519.SeedSize, len(seed))
+	}
+	return &Signer{key: ed25519.NewKeyFromSeed(seed)}, nil
+}
+
+// PublicKey is what verifiers need
+func (s *Signer) PublicKey() ed25519.PublicKey {
+	return s.key.Public().(ed25519.PublicKey)
+}
+
+// Sign returns the permit for the claims, "<payload>.<signature>" both base64url encoded
+func (s *Signer) Sign(c Claims) (string, error) {
+	payload, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+
+	sig := ed25519.Sign(s.key, payload)
+
+	return base64.RawURLEncoding.EncodeToString(payload) + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+// Verify checks the permit's signature and expiry at now and returns its claims
+func Verify(publicKey ed25519.PublicKey, token string, now time.Time) (*Claims, error) {
+	encodedPayload, encodedSig, ok := strings.Cut(token, ".")
+	if !ok {
+		return nil, ErrMalformed
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encodedPayload)
+	if err != nil {
+		return nil, ErrMalformed
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(encodedSig)
+	if err != nil {
+		return nil, ErrMalformed
+	}
+
+	if len(publicKey) != ed25519.PublicKeySize || !ed25519.Verify(publicKey, payload, sig) {
+		return nil, ErrBadSignature
+	}
+
+	var c Claims
+	if err := json.Unmarshal(payload, &c); err != nil {
+		return nil, ErrMalformed
+	}
+
+	if now.Unix() >= c.ExpiresAt {
+		return nil, ErrExpired
+	}
+
+	return &c, nil
+}
+
+// ParsePublicKey decodes a base64 (standard encoding) Ed25519 public key,
+// the form Janus publishes it in
+func ParsePublicKey(s string) (ed25519.PublicKey, error) {
+	key, err := base64.StdEncoding.DecodeString(s)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("permit: invalid public key")
+	}
+	return ed25519.PublicKey(key), nil
+}
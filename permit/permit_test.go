@@ -0,0 +1,96 @@
+package permit
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestSigner(t *testing.T, seed byte) *Signer {
+	t.Helper()
+	s, err := NewSigner(bytes.Repeat([]byte{seed}, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestSignAndVerify(t *testing.T) {
+	s := newTestSigner(t, 1)
+	now := time.Now()
+
+	token, err := s.Sign(Claims{
+		JobID:        "job-1",
+		OwnerID:      "owner-a",
+		Dependencies: map[string]int{"payment_api": 2},
+		LeaseID:      "lease-1",
+		Attempt:      1,
+		IssuedAt:     now.Unix(),
+		ExpiresAt:    now.Add(time.Minute).Unix(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claims, err := Verify(s.PublicKey(), token, now)
+	if err != nil || claims.JobID != "job-1" || claims.LeaseID != "lease-1" || claims.Attempt != 1 {
+		t.Fatalf("got %+v, %v", claims, err)
+	}
+
+	if err := claims.Covers("payment_api", 2); err != nil {
+		t.Errorf("admitted cost: %v", err)
+	}
+	if err := claims.Covers("payment_api", 3); !errors.Is(err, ErrNotCovered) {
+		t.Errorf("cost above admitted: %v, want ErrNotCovered", err)
+	}
+	if err := claims.Covers("email_api", 1); !errors.Is(err, ErrNotCovered) {
+		t.Errorf("other dependency: %v, want ErrNotCovered", err)
+	}
+
+	if _, err := Verify(s.PublicKey(), token, now.Add(time.Minute)); !errors.Is(err, ErrExpired) {
+		t.Errorf("at expiry: %v, want ErrExpired", err)
+	}
+}
+
+func TestVerifyRejectsForgeries(t *testing.T) {
+	s := newTestSigner(t, 1)
+	now := time.Now()
+	token, err := s.Sign(Claims{JobID: "job-1", ExpiresAt: now.Add(time.Minute).Unix()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, sig, _ := strings.Cut(token, ".")
+
+	tampered := base64.RawURLEncoding.EncodeToString([]byte(`{"job_id":"job-2","exp":9999999999}`)) + "." + sig
+	if _, err := Verify(s.PublicKey(), tampered, now); !errors.Is(err, ErrBadSignature) {
+		t.Errorf("changed claims: %v, want ErrBadSignature", err)
+	}
+
+	if _, err := Verify(newTestSigner(t, 2).PublicKey(), token, now); !errors.Is(err, ErrBadSignature) {
+		t.Errorf("other key: %v, want ErrBadSignature", err)
+	}
+
+	for _, malformed := range []string{"", payload, payload + ".!!", "!!." + sig} {
+		if _, err := Verify(s.PublicKey(), malformed, now); !errors.Is(err, ErrMalformed) {
+			t.Errorf("%q: %v, want ErrMalformed", malformed, err)
+		}
+	}
+}
+
+func TestKeys(t *testing.T) {
+	if _, err := NewSigner(make([]byte, 16)); err == nil {
+		t.Error("accepted a 16 byte seed")
+	}
+
+	s := newTestSigner(t, 1)
+	key, err := ParsePublicKey(base64.StdEncoding.EncodeToString(s.PublicKey()))
+	if err != nil || !key.Equal(s.PublicKey()) {
+		t.Errorf("round trip: %v", err)
+	}
+	if _, err := ParsePublicKey("c2hvcnQ="); err == nil {
+		t.Error("accepted a short key")
+	}
+}
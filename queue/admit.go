@@ -1,23 +1,21 @@
 package queue
 
 import (
-	"errors"
+	"context"
+	"encoding/json"
+	"log/slog"
+
+	"github.com/satyamraj1643/janus/internal/audit"
+	"github.com/satyamraj1643/janus/internal/metrics"
+	"github.com/satyamraj1643/janus/internal/tracing"
 
 	"github.com/satyamraj1643/janus/spec"
 )
 
-var ErrQueueFull = errors.New("queue full")
-
 func Admit(job spec.Job) error {
-	select {
-	case JobQueue <- job:
-		return nil
-	default:
-		return ErrQueueFull
-	}
+	return JobQueue.TryPublish(context.Background(), job)
 }
 
-
 // for atomic batch addition
 func AdmitBatch(jobs []spec.Job) error {
 	if len(jobs) == 0 {
@@ -26,15 +24,58 @@ func AdmitBatch(jobs []spec.Job) error {
 
 	// Capacity check
 
-	if RemainingCapacity() < len(jobs) {
+	if remaining := RemainingCapacity(); remaining >= 0 && remaining < len(jobs) {
 		return ErrQueueFull
 	}
 
 	// Commit
 
 	for _, job := range jobs {
-		JobQueue <- job
+		if err := JobQueue.Publish(context.Background(), job); err != nil {
+			return err
+		}
 	}
 
 	return nil
-}
\ No newline at end of file
+}
+
+// Record hands a decision to the DB writer, waiting while ResultQueue is full
+// unless it spills to disk instead. A decision that cannot be queued is logged and lost.
+// The trace in ctx, if any, is attached to the decision.
+func Record(ctx context.Context, decision *spec.JobDecision) {
+	tracing.Stamp(ctx, decision)
+	countDecision(decision)
+	audit.Decision(ctx, decision)
+
+	if err := ResultQueue.Publish(context.Background(), decision); err != nil {
+		slog.Error("decision not recorded", "job_id", decision.JobID, "status", decision.Status, "err", err)
+	}
+}
+
+func countDecision(decision *spec.JobDecision) {
+	owner := decision.Job.OwnerID
+	if len(decision.Job.Dependencies) == 0 {
+		metrics.Decisions.WithLabelValues(decision.Status, decision.Reason, owner, "").Inc()
+		return
+	}
+
+	configured := configuredDependencies(decision.Job.Config)
+	for dep := range decision.Job.Dependencies {
+		if _, ok := configured[dep]; !ok {
+			// Producers name dependencies freely, only the configured ones get a series
+			dep = metrics.OtherDependency
+		}
+		metrics.Decisions.WithLabelValues(decision.Status, decision.Reason, owner, dep).Inc()
+	}
+}
+
+// configuredDependencies returns the dependencies the job's policy snapshot configures
+func configuredDependencies(config json.RawMessage) map[string]json.RawMessage {
+	var p struct {
+		Dependencies map[string]json.RawMessage `json:"dependencies"`
+	}
+	if len(config) > 0 {
+		_ = json.Unmarshal(config, &p)
+	}
+	return p.Dependencies
+}
@@ -0,0 +1,42 @@
+package queue
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/satyamraj1643/janus/internal/metrics"
+	"github.com/satyamraj1643/janus/spec"
+)
+
+func TestCountDecisionBucketsUnknownDependencies(t *testing.T) {
+	cfg := json.RawMessage(`{"dependencies": {"payment_api": {"rate": 10}}}`)
+	decision := &spec.JobDecision{
+		Status: "accepted",
+		Job: spec.Job{
+			OwnerID:      "owner-count",
+			Config:       cfg,
+			Dependencies: map[string]int{"payment_api": 1, "made_up_1": 1, "made_up_2": 1},
+		},
+	}
+
+	series := [][]string{
+		{"accepted", "", "owner-count", "payment_api"},
+		{"accepted", "", "owner-count", metrics.OtherDependency},
+		{"accepted", "", "owner-count", "made_up_1"},
+		{"rejected", "rate_limit_exceeded", "owner-count", ""},
+	}
+	before := make([]float64, len(series))
+	for i, labels := range series {
+		before[i] = testutil.ToFloat64(metrics.Decisions.WithLabelValues(labels...))
+	}
+
+	countDecision(decision)
+	countDecision(&spec.JobDecision{Status: "rejected", Reason: "rate_limit_exceeded", Job: spec.Job{OwnerID: "owner-count"}})
+
+	for i, want := range []float64{1, 2, 0, 1} {
+		if got := testutil.ToFloat64(metrics.Decisions.WithLabelValues(series[i]...)) - before[i]; got != want {
+			t.Errorf("%v: counted %v, want %v", series[i], got, want)
+		}
+	}
+}
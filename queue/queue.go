@@ -0,0 +1,44 @@
+package queue
+
+import (
+	"context"
+	"errors"
+)
+
+var (
+	ErrQueueFull = errors.New("queue full")
+	ErrClosed    = errors.New("queue closed")
+)
+
+// Queue carries values from producers to consumers.
+// Consumers Ack a message once it is handled. A durable queue hands
+// messages that were never acknowledged to another consumer later on,
+// the in-memory one drops them.
+type Queue[T any] interface {
+	// Publish adds v, waiting for room until ctx is done
+	Publish(ctx context.Context, v T) error
+
+	// TryPublish adds v or returns ErrQueueFull without waiting
+	TryPublish(ctx context.Context, v T) error
+
+	// Receive blocks until a message is available or ctx is done
+	Receive(ctx context.Context) (Message[T], error)
+
+	// Ack marks a received message as handled
+	Ack(ctx context.Context, msg Message[T]) error
+
+	// Close stops the queue taking values, Publish returns ErrClosed from then on.
+	// Receive hands out what this instance still holds, then returns ErrClosed.
+	Close() error
+}
+
+type Message[T any] struct {
+	ID    string // stream entry ID, empty for the in-memory queue
+	Value T
+}
+
+// Codec turns values into bytes for queues that leave the process
+type Codec[T any] struct {
+	Encode func(T) ([]byte, error)
+	Decode func([]byte) (T, error)
+}
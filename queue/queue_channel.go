@@ -1,10 +1,145 @@
 package queue
 
-import "github.com/satyamraj1643/janus/spec"
+import (
+	"context"
+	"sync"
 
-var JobQueue = make(chan spec.Job, 1024)
-var ResultQueue = make(chan *spec.JobDecision, 1024)
+	"github.com/satyamraj1643/janus/spec"
+)
 
+// JobQueue holds admitted jobs for execution, ResultQueue holds decisions for the DB writer.
+// Both are in-memory until main swaps in durable queues.
+var JobQueue Queue[spec.Job] = NewChannelQueue[spec.Job](1024)
+var ResultQueue Queue[*spec.JobDecision] = NewChannelQueue[*spec.JobDecision](1024)
+
+// ChannelQueue is an in-memory Queue. Its contents are lost on restart.
+type ChannelQueue[T any] struct {
+	ch chan T
+
+	mu     sync.RWMutex // held for reading while publishing, so Close waits for publishers
+	closed bool
+}
+
+func NewChannelQueue[T any](size int) *ChannelQueue[T] {
+	return &ChannelQueue[T]{ch: make(chan T, size)}
+}
+
+func (q *ChannelQueue[T]) Publish(ctx context.Context, v T) error {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	if q.closed {
+		return ErrClosed
+	}
+
+	select {
+	case q.ch <- v:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (q *ChannelQueue[T]) TryPublish(ctx context.Context, v T) error {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	if q.closed {
+		return ErrClosed
+	}
+
+	select {
+	case q.ch <- v:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+func (q *ChannelQueue[T]) Receive(ctx context.Context) (Message[T], error) {
+	select {
+	case v, ok := <-q.ch:
+		if !ok {
+			return Message[T]{}, ErrClosed
+		}
+		return Message[T]{Value: v}, nil
+	case <-ctx.Done():
+		return Message[T]{}, ctx.Err()
+	}
+}
+
+// Ack is a no-op, a received value has already left the channel
+func (q *ChannelQueue[T]) Ack(ctx context.Context, msg Message[T]) error {
+	return nil
+}
+
+// Close closes the channel, values already in it are still received
+func (q *ChannelQueue[T]) Close() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if !q.closed {
+		q.closed = true
+		close(q.ch)
+	}
+	return nil
+}
+
+// Remaining is the number of values that fit before the queue is full
+func (q *ChannelQueue[T]) Remaining() int {
+	return cap(q.ch) - len(q.ch)
+}
+
+// Len is the number of values waiting to be received
+func (q *ChannelQueue[T]) Len(ctx context.Context) (int64, error) {
+	return int64(len(q.ch)), nil
+}
+
+// Cap is how many values the queue holds when full
+func (q *ChannelQueue[T]) Cap() int64 {
+	return int64(cap(q.ch))
+}
+
+// RemainingCapacity is how many jobs JobQueue can take without blocking,
+// -1 when it is not bounded in memory
 func RemainingCapacity() int {
-	return cap(JobQueue) - len(JobQueue)
-}
\ No newline at end of file
+	if q, ok := JobQueue.(interface{ Remaining() int }); ok {
+		return q.Remaining()
+	}
+	return -1
+}
+
+// ResultDepth is how many decisions wait for the DB writer, -1 when the queue cannot tell
+func ResultDepth(ctx context.Context) int64 {
+	q, ok := ResultQueue.(interface {
+		Len(ctx context.Context) (int64, error)
+	})
+	if !ok {
+		return -1
+	}
+
+	n, err := q.Len(ctx)
+	if err != nil {
+		return -1
+	}
+	return n
+}
+
+// ResultSpilled is how many decisions wait on disk for the DB writer,
+// -1 when ResultQueue does not spill
+func ResultSpilled() int64 {
+	if q, ok := ResultQueue.(interface{ Spilled() int64 }); ok {
+		return q.Spilled()
+	}
+	return -1
+}
+
+// ResultCapacity is how many decisions ResultQueue holds before it blocks, or for a
+// stream its MaxLen; -1 when it is unbounded or cannot tell
+func ResultCapacity() int64 {
+	q, ok := ResultQueue.(interface{ Cap() int64 })
+	if !ok || q.Cap() <= 0 {
+		return -1
+	}
+	return q.Cap()
+}
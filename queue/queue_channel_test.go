@@ -0,0 +1,42 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestChannelQueueDrainsAfterClose(t *testing.T) {
+	ctx := context.Background()
+	q := NewChannelQueue[int](2)
+
+	for v := range 2 {
+		if err := q.TryPublish(ctx, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := q.TryPublish(ctx, 2); !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("full queue got %v, want ErrQueueFull", err)
+	}
+	if n, _ := q.Len(ctx); n != 2 || q.Remaining() != 0 || q.Cap() != 2 {
+		t.Fatalf("Len %d, Remaining %d, Cap %d", n, q.Remaining(), q.Cap())
+	}
+
+	q.Close()
+	q.Close()
+
+	if err := q.Publish(ctx, 3); !errors.Is(err, ErrClosed) {
+		t.Fatalf("Publish after Close got %v, want ErrClosed", err)
+	}
+
+	// Values queued before Close are still received
+	for want := range 2 {
+		msg, err := q.Receive(ctx)
+		if err != nil || msg.Value != want {
+			t.Fatalf("got %+v, %v, want %d", msg, err, want)
+		}
+	}
+	if _, err := q.Receive(ctx); !errors.Is(err, ErrClosed) {
+		t.Fatalf("drained queue got %v, want ErrClosed", err)
+	}
+}
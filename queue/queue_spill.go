@@ -0,0 +1,333 @@
+package queue
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	spillSegmentExt = ".seg"
+	spillHeaderSize = 8 // record length and CRC-32, big endian
+)
+
+type SpillConfig struct {
+	Dir         string // segment files, kept across restarts
+	Size        int    // values held in memory before spilling
+	SegmentSize int64  // a new segment is started once the current one is this large
+}
+
+// SpillQueue is an in-memory Queue that never blocks its publishers. Once its channel
+// is full values are appended to segment files in Dir instead, and replayed into the
+// channel, oldest first, as the consumers catch up. While anything is on disk new
+// values are spilled too, so values are received in the order they were published.
+//
+// Segments are removed once replayed. Segments left by a previous run are replayed
+// on start, a segment replayed only in part is replayed again from its start.
+type SpillQueue[T any] struct {
+	ch    chan T
+	cfg   SpillConfig
+	codec Codec[T]
+
+	mu       sync.Mutex
+	wake     *sync.Cond // signalled when there is something to replay or the queue closes
+	closed   bool
+	segments []uint64 // on disk, oldest first; the last is the one appended to
+	spilled  int64    // values on disk not replayed yet
+	inflight bool     // a value read from disk is on its way to the channel
+	w        *os.File // open segment being appended to
+	wsize    int64
+	rf       *os.File // open segment being replayed, segments[0]
+	r        *bufio.Reader
+}
+
+// NewSpillQueue creates Dir if needed and starts replaying the segments already in it
+func NewSpillQueue[T any](cfg SpillConfig, codec Codec[T]) (*SpillQueue[T], error) {
+	if err := os.MkdirAll(cfg.Dir, 0o750); err != nil {
+		return nil, err
+	}
+
+	q := &SpillQueue[T]{ch: make(chan T, cfg.Size), cfg: cfg, codec: codec}
+	q.wake = sync.NewCond(&q.mu)
+
+	entries, err := os.ReadDir(cfg.Dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		n, err := strconv.ParseUint(strings.TrimSuffix(e.Name(), spillSegmentExt), 10, 64)
+		if err != nil || !strings.HasSuffix(e.Name(), spillSegmentExt) {
+			continue
+		}
+
+		count, err := countRecords(q.segmentPath(n))
+		if err != nil {
+			return nil, err
+		}
+		q.segments = append(q.segments, n)
+		q.spilled += count
+	}
+	slices.Sort(q.segments)
+
+	if q.spilled > 0 {
+		slog.Info("queue: replaying spilled values", "dir", cfg.Dir, "segments", len(q.segments), "count", q.spilled)
+	}
+
+	go q.replay()
+	return q, nil
+}
+
+// Publish never waits, values that do not fit in memory are spilled to disk
+func (q *SpillQueue[T]) Publish(ctx context.Context, v T) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed {
+		return ErrClosed
+	}
+
+	if len(q.segments) == 0 && !q.inflight {
+		select {
+		case q.ch <- v:
+			return nil
+		default:
+		}
+	}
+
+	data, err := q.codec.Encode(v)
+	if err != nil {
+		return err
+	}
+	if err := q.append(data); err != nil {
+		return fmt.Errorf("spilling to %s: %w", q.cfg.Dir, err)
+	}
+
+	q.spilled++
+	q.wake.Signal()
+	return nil
+}
+
+// TryPublish is Publish, the queue spills instead of filling up
+func (q *SpillQueue[T]) TryPublish(ctx context.Context, v T) error {
+	return q.Publish(ctx, v)
+}
+
+func (q *SpillQueue[T]) Receive(ctx context.Context) (Message[T], error) {
+	select {
+	case v, ok := <-q.ch:
+		if !ok {
+			return Message[T]{}, ErrClosed
+		}
+		return Message[T]{Value: v}, nil
+	case <-ctx.Done():
+		return Message[T]{}, ctx.Err()
+	}
+}
+
+// Ack is a no-op, a received value has already left the queue
+func (q *SpillQueue[T]) Ack(ctx context.Context, msg Message[T]) error {
+	return nil
+}
+
+// Close stops the queue taking values. What was spilled is still replayed,
+// Receive returns ErrClosed once that is done and the channel is drained.
+func (q *SpillQueue[T]) Close() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.closed = true
+	q.wake.Broadcast()
+	return nil
+}
+
+// Len is the number of values waiting to be received, in memory and on disk
+func (q *SpillQueue[T]) Len(ctx context.Context) (int64, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return int64(len(q.ch)) + q.spilled, nil
+}
+
+// Spilled is the number of values on disk waiting to be replayed
+func (q *SpillQueue[T]) Spilled() int64 {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return q.spilled
+}
+
+// replay moves spilled values into the channel until the queue is closed and
+// nothing is left on disk, then closes the channel
+func (q *SpillQueue[T]) replay() {
+	for {
+		q.mu.Lock()
+		for len(q.segments) == 0 && !q.closed {
+			q.wake.Wait()
+		}
+		if len(q.segments) == 0 {
+			close(q.ch)
+			q.mu.Unlock()
+			return
+		}
+
+		data, ok := q.next()
+		q.inflight = ok
+		q.mu.Unlock()
+		if !ok {
+			continue
+		}
+
+		// Publishers spill until inflight is cleared, so this is the only sender
+		if v, err := q.codec.Decode(data); err != nil {
+			slog.Error("queue: dropping undecodable spilled value", "dir", q.cfg.Dir, "err", err)
+		} else {
+			q.ch <- v
+		}
+
+		q.mu.Lock()
+		q.inflight = false
+		q.mu.Unlock()
+	}
+}
+
+// next reads the next spilled record, false when a segment ended instead.
+// A finished segment is removed, once the last one is the queue stops spilling.
+// Called with mu held.
+func (q *SpillQueue[T]) next() ([]byte, bool) {
+	seg := q.segments[0]
+
+	if q.r == nil {
+		f, err := os.Open(q.segmentPath(seg))
+		if err != nil {
+			slog.Error("queue: skipping unreadable spill segment", "segment", q.segmentPath(seg), "err", err)
+			q.dropSegment()
+			return nil, false
+		}
+		q.rf = f
+		q.r = bufio.NewReader(f)
+	}
+
+	data, err := readRecord(q.r)
+	if err == nil {
+		q.spilled--
+		return data, true
+	}
+	if !errors.Is(err, io.EOF) {
+		// A torn write from a crash, the rest of the segment cannot be framed
+		slog.Warn("queue: discarding the rest of a spill segment", "segment", q.segmentPath(seg), "err", err)
+	}
+
+	q.dropSegment()
+	return nil, false
+}
+
+// dropSegment closes and removes segments[0]. Called with mu held.
+func (q *SpillQueue[T]) dropSegment() {
+	seg := q.segments[0]
+
+	if q.rf != nil {
+		q.rf.Close()
+		q.rf, q.r = nil, nil
+	}
+	if len(q.segments) == 1 && q.w != nil {
+		q.w.Close()
+		q.w, q.wsize = nil, 0
+	}
+
+	if err := os.Remove(q.segmentPath(seg)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		slog.Error("queue: removing spill segment failed", "segment", q.segmentPath(seg), "err", err)
+	}
+	q.segments = q.segments[1:]
+
+	if len(q.segments) == 0 {
+		if q.spilled != 0 {
+			slog.Warn("queue: spilled values lost", "dir", q.cfg.Dir, "count", q.spilled)
+		}
+		q.spilled = 0
+	}
+}
+
+// append writes one record to the open segment, starting a new one when there is
+// none or it is full. Called with mu held.
+func (q *SpillQueue[T]) append(data []byte) error {
+	if q.w == nil || q.wsize >= q.cfg.SegmentSize {
+		if q.w != nil {
+			q.w.Close()
+		}
+
+		var seg uint64 = 1
+		if len(q.segments) > 0 {
+			seg = q.segments[len(q.segments)-1] + 1
+		}
+
+		f, err := os.OpenFile(q.segmentPath(seg), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o640)
+		if err != nil {
+			q.w = nil
+			return err
+		}
+		q.w, q.wsize = f, 0
+		q.segments = append(q.segments, seg)
+	}
+
+	record := make([]byte, spillHeaderSize+len(data))
+	binary.BigEndian.PutUint32(record[0:4], uint32(len(data)))
+	binary.BigEndian.PutUint32(record[4:8], crc32.ChecksumIEEE(data))
+	copy(record[spillHeaderSize:], data)
+
+	n, err := q.w.Write(record)
+	q.wsize += int64(n)
+	return err
+}
+
+func (q *SpillQueue[T]) segmentPath(seg uint64) string {
+	return filepath.Join(q.cfg.Dir, fmt.Sprintf("%020d%s", seg, spillSegmentExt))
+}
+
+// readRecord reads one framed record, io.EOF only at a record boundary
+func readRecord(r io.Reader) ([]byte, error) {
+	var header [spillHeaderSize]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, err
+	}
+
+	data := make([]byte, binary.BigEndian.Uint32(header[0:4]))
+	if _, err := io.ReadFull(r, data); err != nil {
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	if crc32.ChecksumIEEE(data) != binary.BigEndian.Uint32(header[4:8]) {
+		return nil, errors.New("checksum mismatch")
+	}
+	return data, nil
+}
+
+// countRecords counts the intact records of a segment
+func countRecords(path string) (int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	var n int64
+	for {
+		if _, err := readRecord(r); err != nil {
+			return n, nil
+		}
+		n++
+	}
+}
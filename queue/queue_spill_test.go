@@ -0,0 +1,168 @@
+package queue
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTestSpill(t *testing.T, dir string, size int) *SpillQueue[int] {
+	t.Helper()
+	q, err := NewSpillQueue(SpillConfig{Dir: dir, Size: size, SegmentSize: 32}, intCodec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return q
+}
+
+// record frames data the way SpillQueue writes it
+func record(data []byte) []byte {
+	header := make([]byte, spillHeaderSize)
+	binary.BigEndian.PutUint32(header[0:4], uint32(len(data)))
+	binary.BigEndian.PutUint32(header[4:8], crc32.ChecksumIEEE(data))
+	return append(header, data...)
+}
+
+func segments(t *testing.T, dir string) []string {
+	t.Helper()
+	files, err := filepath.Glob(filepath.Join(dir, "*"+spillSegmentExt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+func receiveSpilled(t *testing.T, q *SpillQueue[int], want ...int) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	for _, w := range want {
+		msg, err := q.Receive(ctx)
+		if err != nil || msg.Value != w {
+			t.Fatalf("got %+v, %v, want %d", msg, err, w)
+		}
+	}
+}
+
+func TestSpillQueueKeepsOrderAcrossSegments(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	q := newTestSpill(t, dir, 2)
+
+	for v := range 20 {
+		if err := q.Publish(ctx, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n, _ := q.Len(ctx); n != 20 || q.Spilled() == 0 {
+		t.Fatalf("Len %d, Spilled %d, want 20 with some on disk", n, q.Spilled())
+	}
+	if len(segments(t, dir)) < 2 {
+		t.Fatal("want the spill split over segments")
+	}
+
+	want := make([]int, 20)
+	for i := range want {
+		want[i] = i
+	}
+	receiveSpilled(t, q, want...)
+
+	q.Close()
+	if _, err := q.Receive(ctx); !errors.Is(err, ErrClosed) {
+		t.Fatalf("drained queue got %v, want ErrClosed", err)
+	}
+	if left := segments(t, dir); len(left) != 0 {
+		t.Fatalf("segments %v left after replay", left)
+	}
+}
+
+func TestSpillQueueReplaysSegmentsOnStart(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	q := newTestSpill(t, dir, 1)
+
+	for v := range 5 {
+		if err := q.Publish(ctx, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A copy of the segments as a crash would have left them, 0 was only in memory
+	restarted := t.TempDir()
+	for _, f := range segments(t, dir) {
+		data, err := os.ReadFile(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(restarted, filepath.Base(f)), data, 0o640); err != nil {
+			t.Fatal(err)
+		}
+	}
+	receiveSpilled(t, q, 0, 1, 2, 3, 4)
+
+	r := newTestSpill(t, restarted, 1)
+	if n, _ := r.Len(ctx); n != 4 {
+		t.Fatalf("Len %d on start, want the 4 spilled values", n)
+	}
+	receiveSpilled(t, r, 1, 2, 3, 4)
+}
+
+func TestSpillQueueDiscardsTornRecords(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	corrupt := record([]byte("3"))
+	corrupt[len(corrupt)-1] = '4' // CRC no longer matches
+
+	var seg bytes.Buffer
+	seg.Write(record([]byte("1")))
+	seg.Write(record([]byte("2")))
+	seg.Write(corrupt)
+	seg.Write(record([]byte("5")))
+	if err := os.WriteFile(filepath.Join(dir, "00000000000000000001"+spillSegmentExt), seg.Bytes(), 0o640); err != nil {
+		t.Fatal(err)
+	}
+
+	torn := record([]byte("7"))
+	if err := os.WriteFile(filepath.Join(dir, "00000000000000000002"+spillSegmentExt), append(record([]byte("6")), torn[:5]...), 0o640); err != nil {
+		t.Fatal(err)
+	}
+
+	q := newTestSpill(t, dir, 1)
+	if n, _ := q.Len(ctx); n != 3 {
+		t.Fatalf("Len %d, want the 3 intact records before each tear", n)
+	}
+	receiveSpilled(t, q, 1, 2, 6)
+
+	q.Close()
+	if _, err := q.Receive(ctx); !errors.Is(err, ErrClosed) {
+		t.Fatalf("got %v, want ErrClosed once the readable records are replayed", err)
+	}
+}
+
+func TestReadRecord(t *testing.T) {
+	r := bytes.NewReader(record([]byte("hello")))
+	if data, err := readRecord(r); err != nil || string(data) != "hello" {
+		t.Fatalf("got %q, %v", data, err)
+	}
+	if _, err := readRecord(r); err != io.EOF {
+		t.Fatalf("at the end got %v, want io.EOF", err)
+	}
+
+	full := record([]byte("hello"))
+	if _, err := readRecord(bytes.NewReader(full[:len(full)-2])); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("truncated got %v, want io.ErrUnexpectedEOF", err)
+	}
+	full[spillHeaderSize] = 'j'
+	if _, err := readRecord(bytes.NewReader(full)); err == nil {
+		t.Fatal("accepted a record whose checksum does not match")
+	}
+}
@@ -0,0 +1,258 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	streamValueField = "v"
+	streamReadCount  = 32
+	streamTrimEvery  = 1000 // publishes between two trims
+)
+
+type StreamConfig struct {
+	Stream    string        // stream key
+	Group     string        // consumer group shared by every Janus instance
+	Consumer  string        // unique per instance, names the pending entry owner
+	MaxLen    int64         // stream length past which acknowledged entries are trimmed, 0 never trims
+	ClaimIdle time.Duration // pending entries idle this long are taken over from their consumer
+	Block     time.Duration // how long one read waits for new entries
+}
+
+// StreamQueue is a Queue on a Redis stream with a consumer group.
+// Entries stay pending until acknowledged, so messages received by an instance
+// that died are reclaimed by the others once they have been idle for ClaimIdle.
+type StreamQueue[T any] struct {
+	client redis.UniversalClient
+	cfg    StreamConfig
+	codec  Codec[T]
+
+	mu          sync.Mutex       // guards buffered and lastReclaim, never held across Redis calls
+	buffered    []redis.XMessage // read but not yet handed out
+	lastReclaim time.Time
+	closed      atomic.Bool
+	published   atomic.Int64
+}
+
+// NewStreamQueue creates the consumer group (and the stream) if they do not exist
+func NewStreamQueue[T any](ctx context.Context, client redis.UniversalClient, cfg StreamConfig, codec Codec[T]) (*StreamQueue[T], error) {
+	err := client.XGroupCreateMkStream(ctx, cfg.Stream, cfg.Group, "0").Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return nil, err
+	}
+
+	return &StreamQueue[T]{client: client, cfg: cfg, codec: codec}, nil
+}
+
+func (q *StreamQueue[T]) Publish(ctx context.Context, v T) error {
+	if q.closed.Load() {
+		return ErrClosed
+	}
+
+	data, err := q.codec.Encode(v)
+	if err != nil {
+		return err
+	}
+
+	err = q.client.XAdd(ctx, &redis.XAddArgs{
+		Stream: q.cfg.Stream,
+		Values: []any{streamValueField, data},
+	}).Err()
+	if err != nil {
+		return err
+	}
+
+	if q.cfg.MaxLen > 0 && q.published.Add(1)%streamTrimEvery == 0 {
+		if err := q.trim(ctx); err != nil {
+			slog.Warn("queue: trimming stream failed", "stream", q.cfg.Stream, "err", err)
+		}
+	}
+	return nil
+}
+
+// trim drops acknowledged entries once the stream is longer than MaxLen.
+// Everything older than the group's oldest pending entry, or than its last
+// delivered one if none is pending, has been acknowledged. Entries pending or
+// never read are kept however long the stream grows.
+func (q *StreamQueue[T]) trim(ctx context.Context) error {
+	n, err := q.client.XLen(ctx, q.cfg.Stream).Result()
+	if err != nil || n <= q.cfg.MaxLen {
+		return err
+	}
+
+	pending, err := q.client.XPending(ctx, q.cfg.Stream, q.cfg.Group).Result()
+	if err != nil {
+		return err
+	}
+
+	floor := pending.Lower
+	if pending.Count == 0 {
+		groups, err := q.client.XInfoGroups(ctx, q.cfg.Stream).Result()
+		if err != nil {
+			return err
+		}
+		for _, g := range groups {
+			if g.Name == q.cfg.Group {
+				floor = g.LastDeliveredID
+			}
+		}
+	}
+	if floor == "" || floor == "0-0" {
+		return nil
+	}
+
+	return q.client.XTrimMinIDApprox(ctx, q.cfg.Stream, floor, 0).Err()
+}
+
+// TryPublish is Publish, a stream does not fill up
+func (q *StreamQueue[T]) TryPublish(ctx context.Context, v T) error {
+	return q.Publish(ctx, v)
+}
+
+func (q *StreamQueue[T]) Receive(ctx context.Context) (Message[T], error) {
+	for {
+		raw, err := q.next(ctx)
+		if err != nil {
+			return Message[T]{}, err
+		}
+
+		data, ok := raw.Values[streamValueField].(string)
+		if !ok {
+			// Trimmed while pending, nothing left to deliver
+			_ = q.client.XAck(ctx, q.cfg.Stream, q.cfg.Group, raw.ID).Err()
+			continue
+		}
+
+		v, err := q.codec.Decode([]byte(data))
+		if err != nil {
+			// Would fail on every consumer, drop it instead of reclaiming it forever
+			slog.Error("queue: dropping undecodable entry", "stream", q.cfg.Stream, "entry", raw.ID, "err", err)
+			_ = q.client.XAck(ctx, q.cfg.Stream, q.cfg.Group, raw.ID).Err()
+			continue
+		}
+
+		return Message[T]{ID: raw.ID, Value: v}, nil
+	}
+}
+
+// Close stops reading new entries. A read in progress still waits out its Block, as
+// go-redis does not interrupt a blocked command. Entries already read are still handed
+// out, the rest stay in the stream for the other instances.
+func (q *StreamQueue[T]) Close() error {
+	q.closed.Store(true)
+	return nil
+}
+
+func (q *StreamQueue[T]) Ack(ctx context.Context, msg Message[T]) error {
+	return q.client.XAck(ctx, q.cfg.Stream, q.cfg.Group, msg.ID).Err()
+}
+
+// next returns the next entry for this consumer, reclaiming stale pending entries
+// before reading new ones every ClaimIdle/2
+func (q *StreamQueue[T]) next(ctx context.Context) (redis.XMessage, error) {
+	for {
+		q.mu.Lock()
+		if len(q.buffered) > 0 {
+			msg := q.buffered[0]
+			q.buffered = q.buffered[1:]
+			q.mu.Unlock()
+			return msg, nil
+		}
+		reclaim := time.Since(q.lastReclaim) >= q.cfg.ClaimIdle/2
+		if reclaim {
+			q.lastReclaim = time.Now()
+		}
+		q.mu.Unlock()
+
+		if err := ctx.Err(); err != nil {
+			return redis.XMessage{}, err
+		}
+		if q.closed.Load() {
+			return redis.XMessage{}, ErrClosed
+		}
+
+		msgs, err := q.read(ctx, reclaim)
+		if err != nil {
+			if q.closed.Load() {
+				return redis.XMessage{}, ErrClosed
+			}
+			return redis.XMessage{}, err
+		}
+
+		q.mu.Lock()
+		q.buffered = append(q.buffered, msgs...)
+		q.mu.Unlock()
+	}
+}
+
+// read takes over stale pending entries if reclaim is set and there are any,
+// otherwise waits up to Block for new ones
+func (q *StreamQueue[T]) read(ctx context.Context, reclaim bool) ([]redis.XMessage, error) {
+	if reclaim {
+		claimed, _, err := q.client.XAutoClaim(ctx, &redis.XAutoClaimArgs{
+			Stream:   q.cfg.Stream,
+			Group:    q.cfg.Group,
+			Consumer: q.cfg.Consumer,
+			MinIdle:  q.cfg.ClaimIdle,
+			Start:    "0-0",
+			Count:    streamReadCount,
+		}).Result()
+		if err != nil {
+			return nil, err
+		}
+		if len(claimed) > 0 {
+			slog.Info("queue: reclaimed pending entries", "stream", q.cfg.Stream, "count", len(claimed))
+			return claimed, nil
+		}
+	}
+
+	streams, err := q.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    q.cfg.Group,
+		Consumer: q.cfg.Consumer,
+		Streams:  []string{q.cfg.Stream, ">"},
+		Count:    streamReadCount,
+		Block:    q.cfg.Block,
+	}).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var msgs []redis.XMessage
+	for _, s := range streams {
+		msgs = append(msgs, s.Messages...)
+	}
+	return msgs, nil
+}
+
+// Len is the number of entries the group has not acknowledged yet,
+// pending ones plus those no consumer has read
+func (q *StreamQueue[T]) Len(ctx context.Context) (int64, error) {
+	groups, err := q.client.XInfoGroups(ctx, q.cfg.Stream).Result()
+	if err != nil {
+		return 0, err
+	}
+
+	for _, g := range groups {
+		if g.Name == q.cfg.Group {
+			// Lag is -1 when Redis cannot tell after trimming
+			return g.Pending + max(g.Lag, 0), nil
+		}
+	}
+	return 0, nil
+}
+
+// Cap is MaxLen. Only acknowledged entries are trimmed, so a backlog can grow past it.
+func (q *StreamQueue[T]) Cap() int64 {
+	return q.cfg.MaxLen
+}
@@ -0,0 +1,168 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+var intCodec = Codec[int]{
+	Encode: func(v int) ([]byte, error) { return []byte(strconv.Itoa(v)), nil },
+	Decode: func(b []byte) (int, error) { return strconv.Atoi(string(b)) },
+}
+
+// newTestStream returns a queue for consumer on a stream every call in the test shares
+func newTestStream(t *testing.T, client redis.UniversalClient, consumer string, maxLen int64) *StreamQueue[int] {
+	t.Helper()
+	q, err := NewStreamQueue(context.Background(), client, StreamConfig{
+		Stream:    "janus:test",
+		Group:     "writers",
+		Consumer:  consumer,
+		MaxLen:    maxLen,
+		ClaimIdle: 20 * time.Millisecond,
+		Block:     50 * time.Millisecond,
+	}, intCodec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { q.Close() })
+	return q
+}
+
+func newTestClient(t *testing.T) redis.UniversalClient {
+	t.Helper()
+	client := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+func receive(t *testing.T, q *StreamQueue[int]) Message[int] {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	msg, err := q.Receive(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return msg
+}
+
+func TestStreamQueueDeliversInOrder(t *testing.T) {
+	ctx := context.Background()
+	q := newTestStream(t, newTestClient(t), "a", 0)
+
+	for v := range 3 {
+		if err := q.Publish(ctx, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for want := range 3 {
+		msg := receive(t, q)
+		if msg.Value != want || msg.ID == "" {
+			t.Fatalf("got %+v, want %d", msg, want)
+		}
+		if err := q.Ack(ctx, msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	pending, err := q.client.XPending(ctx, "janus:test", "writers").Result()
+	if err != nil || pending.Count != 0 {
+		t.Fatalf("pending after acking everything = %+v, %v", pending, err)
+	}
+}
+
+func TestStreamQueueReclaimsUnacknowledged(t *testing.T) {
+	ctx := context.Background()
+	client := newTestClient(t)
+	crashed := newTestStream(t, client, "a", 0)
+	survivor := newTestStream(t, client, "b", 0)
+
+	if err := crashed.Publish(ctx, 7); err != nil {
+		t.Fatal(err)
+	}
+	lost := receive(t, crashed) // never acknowledged
+
+	time.Sleep(30 * time.Millisecond)
+
+	msg := receive(t, survivor)
+	if msg.ID != lost.ID || msg.Value != 7 {
+		t.Fatalf("survivor got %+v, want the entry %s taken over", msg, lost.ID)
+	}
+}
+
+func TestStreamQueueCloseEndsReceive(t *testing.T) {
+	q := newTestStream(t, newTestClient(t), "a", 0)
+
+	errs := make(chan error, 1)
+	go func() {
+		_, err := q.Receive(context.Background())
+		errs <- err
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+	q.Close()
+
+	select {
+	case err := <-errs:
+		if !errors.Is(err, ErrClosed) {
+			t.Fatalf("got %v, want ErrClosed", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Receive still blocked after Close and a full Block")
+	}
+
+	if err := q.Publish(context.Background(), 1); !errors.Is(err, ErrClosed) {
+		t.Fatalf("Publish after Close got %v, want ErrClosed", err)
+	}
+}
+
+func TestStreamQueueTrimKeepsUnacknowledged(t *testing.T) {
+	ctx := context.Background()
+	client := newTestClient(t)
+	q := newTestStream(t, client, "a", 2)
+
+	for v := range 6 {
+		if err := q.Publish(ctx, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 0-4 acknowledged, 5 pending, 6-9 never read
+	var pending Message[int]
+	for v := range 6 {
+		msg := receive(t, q)
+		if v == 5 {
+			pending = msg
+		} else if err := q.Ack(ctx, msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for v := 6; v < 10; v++ {
+		if err := q.Publish(ctx, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := q.trim(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := client.XRange(ctx, "janus:test", "-", "+").Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) >= 10 {
+		t.Fatalf("%d entries left, want acknowledged ones trimmed", len(entries))
+	}
+	if len(entries) < 5 || entries[len(entries)-5].ID != pending.ID {
+		t.Fatalf("trimmed past the pending entry %s: %v", pending.ID, entries)
+	}
+}
@@ -0,0 +1,88 @@
+package spec
+
+import "encoding/json"
+
+// storedJob is the durable form of a Job. Unlike the API form it keeps the
+// metadata Janus attaches on ingestion, so a job read back from Redis can be
+// re-evaluated and persisted exactly like the original request.
+type storedJob struct {
+	Job
+	OwnerID        string          `json:"owner_id"`
+	Source         JobSource       `json:"source"`
+	BatchName      string          `json:"batch_name"`
+	BatchID        string          `json:"batch_id"`
+	Config         json.RawMessage `json:"config,omitempty"`
+	GlobalConfigID string          `json:"global_config_id"`
+	IdempotencyKey string          `json:"idempotency_key,omitempty"`
+	Permit         string          `json:"permit,omitempty"`
+}
+
+// EncodeJob serializes a job including its ingestion metadata
+func EncodeJob(job Job) ([]byte, error) {
+	return json.Marshal(storedJob{
+		Job:            job,
+		OwnerID:        job.OwnerID,
+		Source:         job.Source,
+		BatchName:      job.BatchName,
+		BatchID:        job.BatchID,
+		Config:         job.Config,
+		GlobalConfigID: job.GlobalConfigID,
+		IdempotencyKey: job.IdempotencyKey,
+		Permit:         job.Permit,
+	})
+}
+
+// DecodeJob is the inverse of EncodeJob
+func DecodeJob(data []byte) (Job, error) {
+	var s storedJob
+	if err := json.Unmarshal(data, &s); err != nil {
+		return Job{}, err
+	}
+
+	job := s.Job
+	job.OwnerID = s.OwnerID
+	job.Source = s.Source
+	job.BatchName = s.BatchName
+	job.BatchID = s.BatchID
+	job.Config = s.Config
+	job.GlobalConfigID = s.GlobalConfigID
+	job.IdempotencyKey = s.IdempotencyKey
+	job.Permit = s.Permit
+
+	return job, nil
+}
+
+// storedDecision is the durable form of a JobDecision, its job keeps the ingestion metadata
+type storedDecision struct {
+	JobDecision
+	Job         json.RawMessage `json:"job"` // EncodeJob
+	TraceParent string          `json:"trace_parent,omitempty"`
+}
+
+// EncodeDecision serializes a decision including its job's ingestion metadata
+func EncodeDecision(decision *JobDecision) ([]byte, error) {
+	job, err := EncodeJob(decision.Job)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(storedDecision{JobDecision: *decision, Job: job, TraceParent: decision.TraceParent})
+}
+
+// DecodeDecision is the inverse of EncodeDecision
+func DecodeDecision(data []byte) (*JobDecision, error) {
+	var s storedDecision
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, err
+	}
+
+	job, err := DecodeJob(s.Job)
+	if err != nil {
+		return nil, err
+	}
+
+	decision := s.JobDecision
+	decision.Job = job
+	decision.TraceParent = s.TraceParent
+	return &decision, nil
+}
@@ -0,0 +1,42 @@
+package spec
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestDecisionKeepsIngestionMetadata(t *testing.T) {
+	decision := &JobDecision{
+		JobID:       "job-1",
+		Status:      "accepted",
+		TraceID:     "4bf92f3577b34da6a3ce929d0e0e4736",
+		TraceParent: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		Job: Job{
+			ID:             "job-1",
+			OwnerID:        "owner-a",
+			BatchID:        "batch-1",
+			Config:         json.RawMessage(`{"a":1}`),
+			GlobalConfigID: "cfg-1",
+			IdempotencyKey: "key-1",
+			Permit:         "permit",
+		},
+	}
+
+	raw, err := EncodeDecision(decision)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := DecodeDecision(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got.TraceParent != decision.TraceParent || got.TraceID != decision.TraceID {
+		t.Errorf("trace %q / %q lost", got.TraceID, got.TraceParent)
+	}
+	j := got.Job
+	if j.OwnerID != "owner-a" || j.BatchID != "batch-1" || string(j.Config) != `{"a":1}` ||
+		j.GlobalConfigID != "cfg-1" || j.IdempotencyKey != "key-1" || j.Permit != "permit" {
+		t.Errorf("job metadata lost: %+v", j)
+	}
+}
@@ -6,8 +6,8 @@ import (
 )
 
 //Job represents a single execution request submitted to Janus
-// It carries identity, classification, scope and business payload
-// It does NOT carry execution or scheduling semantics
+// It carries identity, classification, scope and business payload,
+// and optionally the window in which it may be admitted
 
 type Job struct {
 	ID           string         `json:"job_id"`
@@ -16,7 +16,9 @@ type Job struct {
 	Dependencies map[string]int `json:"dependencies"`
 	Payload      map[string]any `json:"payload"`
 
-    
+	// Scheduling (optional)
+	NotBefore *time.Time `json:"not_before,omitempty"` // hold the job until then, then run admission
+	Deadline  *time.Time `json:"deadline,omitempty"`   // expire the job if it is not admitted by then
 
 	// metadata (NOT user-provided)
 	OwnerID      string         `json:"-"` // Janus User ID (authenticated)
@@ -25,6 +27,8 @@ type Job struct {
 	BatchID        string          `json:"-"`
 	Config         json.RawMessage `json:"-"` // user's active Janus config
 	GlobalConfigID string          `json:"-"` // ID of the active config
+	IdempotencyKey string          `json:"-"` // Idempotency-Key header, overrides job_id for dedup
+	Permit         string          `json:"-"` // signed on acceptance, see package permit
 }
 
 type JobDecision struct {
@@ -34,10 +38,33 @@ type JobDecision struct {
 	BatchName string `json:"batch_name"`
 
 	// Decision
-	Status    string    `json:"status"` // accepted | rejected
+	Status    string    `json:"status"` // see internal/lifecycle
 	Reason    string    `json:"reason,omitempty"`
 	Timestamp time.Time `json:"timestamp"`
 
+	// Attempt is the execution the decision is about, 0 before the job is first leased
+	Attempt int `json:"attempt,omitempty"`
+
+	// Actor is who caused the decision, janus when empty
+	Actor string `json:"actor,omitempty"`
+
+	// RetryAfterMs hints when a quota rejection is likely to pass on resubmission
+	RetryAfterMs int64 `json:"retry_after_ms,omitempty"`
+
+	// Replayed is set when this decision was returned from the idempotency
+	// record of an earlier submission instead of being evaluated again
+	Replayed bool `json:"replayed,omitempty"`
+
+	// Dispatched is set when the admitted job was queued for workers to lease
+	Dispatched bool `json:"dispatched,omitempty"`
+
+	// Permit proves to workers and dependency proxies that the job was accepted, see package permit
+	Permit string `json:"permit,omitempty"`
+
+	// TraceID is the trace of the request that produced the decision
+	TraceID     string `json:"trace_id,omitempty"`
+	TraceParent string `json:"-"` // W3C traceparent of that request, links the DB save to it
+
 	// Full payload
 	Job    Job             `json:"job"`
 	Config json.RawMessage `json:"config"`
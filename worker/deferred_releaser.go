@@ -0,0 +1,163 @@
+package worker
+
+import (
+	"context"
+	"log/slog"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/satyamraj1643/janus/internal/admission"
+	"github.com/satyamraj1643/janus/queue"
+	"github.com/satyamraj1643/janus/spec"
+)
+
+const (
+	deferredClaimVisibility = 30 * time.Second
+	deferredExpireBatch     = 100
+	deferredMaxPerTick      = 1000
+)
+
+// StartDeferredReleaser re-evaluates deferred jobs every interval.
+// Jobs that now fit are released, jobs that waited too long expire.
+// Both outcomes are sent to the DB writer, where clients read them back.
+// It stops once ctx is done, after finishing the current round; the returned channel is closed then.
+func StartDeferredReleaser(ctx context.Context, ac *admission.AdmissionController, interval time.Duration) <-chan struct{} {
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		slog.Info("DeferredReleaser started", "interval", interval)
+
+		r := &fairReleaser{ac: ac, deficits: make(map[string]int)}
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				slog.Info("DeferredReleaser stopped")
+				return
+			case <-ticker.C:
+				r.tick(context.Background())
+			}
+		}
+	}()
+
+	return done
+}
+
+// fairReleaser releases deferred jobs across tenants by weighted deficit round robin.
+// Every round each waiting tenant earns its weight in deficit and spends one per
+// released job, so freed capacity is shared in proportion to the weights however
+// deep any single tenant's backlog is. Within a tenant, jobs leave in rank order
+// (priority with aging).
+type fairReleaser struct {
+	ac       *admission.AdmissionController
+	deficits map[string]int // per tenant key, only kept for this instance
+	offset   int            // rotates which tenant is served first
+}
+
+func (r *fairReleaser) tick(ctx context.Context) {
+	r.expire(ctx)
+
+	tenants, err := r.ac.Waiting.ActiveTenants(ctx)
+	if err != nil {
+		slog.Error("DeferredReleaser: listing tenants failed", "err", err)
+		return
+	}
+
+	// Forget tenants whose queue drained
+	for key := range r.deficits {
+		if _, ok := tenants[key]; !ok {
+			delete(r.deficits, key)
+		}
+	}
+
+	if len(tenants) == 0 {
+		return
+	}
+
+	order := slices.Sorted(maps.Keys(tenants))
+	r.offset = (r.offset + 1) % len(order)
+	order = append(order[r.offset:], order[:r.offset]...)
+
+	blocked := make(map[string]bool) // no capacity or nothing claimable this tick
+	released := 0
+
+	for released < deferredMaxPerTick {
+		progress := false
+
+		for _, key := range order {
+			if blocked[key] {
+				continue
+			}
+
+			weight := tenants[key]
+			r.deficits[key] += weight
+
+			for r.deficits[key] > 0 && released < deferredMaxPerTick {
+				waiting, err := r.ac.Waiting.ClaimNext(ctx, key, deferredClaimVisibility)
+				if err != nil || waiting == nil {
+					if err != nil {
+						slog.Error("DeferredReleaser: claim failed", "tenant_key", key, "err", err)
+					}
+					// An empty queue does not bank credit
+					r.deficits[key] = 0
+					blocked[key] = true
+					break
+				}
+
+				decision, err := r.ac.ReevaluateDeferred(ctx, *waiting)
+				if err != nil {
+					slog.Error("DeferredReleaser: re-evaluation failed", "job_id", waiting.JobID, "err", err)
+				}
+				if decision == nil {
+					// Still waiting for capacity
+					blocked[key] = true
+					break
+				}
+
+				publishReleased(ctx, decision)
+				r.deficits[key]--
+				released++
+				progress = true
+			}
+
+			// A blocked tenant keeps at most one round of credit
+			r.deficits[key] = min(r.deficits[key], weight)
+		}
+
+		if !progress {
+			return
+		}
+	}
+}
+
+// expire moves jobs past their max wait out of the queue, whatever their position
+func (r *fairReleaser) expire(ctx context.Context) {
+	expired, err := r.ac.Waiting.ClaimExpired(ctx, time.Now(), deferredExpireBatch, deferredClaimVisibility)
+	if err != nil {
+		slog.Error("DeferredReleaser: claiming expired jobs failed", "err", err)
+		return
+	}
+
+	for _, waiting := range expired {
+		decision, err := r.ac.ReevaluateDeferred(ctx, waiting)
+		if err != nil {
+			slog.Error("DeferredReleaser: re-evaluation failed", "job_id", waiting.JobID, "err", err)
+		}
+		if decision != nil {
+			publishReleased(ctx, decision)
+		}
+	}
+}
+
+func publishReleased(ctx context.Context, decision *spec.JobDecision) {
+	slog.Debug("DeferredReleaser: released job", "job_id", decision.JobID, "status", decision.Status)
+
+	// Dispatched jobs wait in the ready queue for a worker to lease them,
+	// the others are only recorded, as when accepted on submission
+	queue.Record(ctx, decision)
+}
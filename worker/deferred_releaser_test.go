@@ -0,0 +1,66 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/satyamraj1643/janus/internal/admission"
+	"github.com/satyamraj1643/janus/internal/store"
+	"github.com/satyamraj1643/janus/spec"
+)
+
+func TestFairReleaserSharesCapacityByWeight(t *testing.T) {
+	ctx := context.Background()
+	s := store.NewRedisStore(miniredis.RunT(t).Addr())
+	ac := admission.NewAdmissionController(s)
+	ac.Waiting = s
+
+	cfg, _ := json.Marshal(map[string]any{
+		"global_execution_limit": map[string]any{"max_jobs": 4, "window_ms": 60000, "max_concurrent_per_tenant": 100},
+		"default_job_policy": map[string]any{
+			"idempotency_window_ms": 60000,
+			"defer":                 map[string]any{"max_wait_ms": 60000, "tenant_weights": map[string]int{"a": 3, "b": 1}},
+		},
+	})
+	submit := func(tenant string, n int) {
+		for i := range n {
+			job := spec.Job{ID: fmt.Sprintf("%s-%d", tenant, i), TenantID: tenant, OwnerID: "owner", Config: cfg}
+			if _, err := ac.Check(ctx, job); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	submit("c", 4) // uses up the quota
+	submit("a", 8)
+	submit("b", 8)
+
+	// Frees room for four jobs
+	global := store.RateLimitReq{Key: "owner:global_request_quota", Capacity: 4, RefillRate: 4.0 / 60, Cost: 4}
+	if err := s.RefundTokens(ctx, []store.RateLimitReq{global}); err != nil {
+		t.Fatal(err)
+	}
+
+	r := &fairReleaser{ac: ac, deficits: make(map[string]int)}
+	r.tick(ctx)
+
+	released := map[string]int{}
+	for _, tenant := range []string{"a", "b"} {
+		for i := range 8 {
+			waiting, err := s.GetWaiting(ctx, "owner", fmt.Sprintf("%s-%d", tenant, i))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if waiting == nil {
+				released[tenant]++
+			}
+		}
+	}
+
+	if released["a"] != 3 || released["b"] != 1 {
+		t.Fatalf("released %v, want 3 of a and 1 of b", released)
+	}
+}
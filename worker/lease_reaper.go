@@ -0,0 +1,46 @@
+package worker
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/satyamraj1643/janus/internal/lease"
+	"github.com/satyamraj1643/janus/queue"
+)
+
+// StartLeaseReaper reclaims leases whose worker stopped heartbeating.
+// Jobs with attempts left become leasable again as retrying, the rest are dead.
+// It stops once ctx is done, after finishing the current round; the returned channel is closed then.
+func StartLeaseReaper(ctx context.Context, m *lease.Manager, interval time.Duration) <-chan struct{} {
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		slog.Info("LeaseReaper started", "interval", interval)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				slog.Info("LeaseReaper stopped")
+				return
+			case <-ticker.C:
+			}
+
+			decisions, err := m.Reap(context.Background())
+			if err != nil {
+				slog.Error("LeaseReaper: reaping failed", "err", err)
+			}
+
+			for _, decision := range decisions {
+				slog.Info("LeaseReaper: lease expired", "job_id", decision.JobID, "status", decision.Status)
+				queue.Record(context.Background(), decision)
+			}
+		}
+	}()
+
+	return done
+}
@@ -0,0 +1,129 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/satyamraj1643/janus/internal/lifecycle"
+	"github.com/satyamraj1643/janus/internal/metrics"
+	"github.com/satyamraj1643/janus/internal/outbox"
+	"github.com/satyamraj1643/janus/spec"
+)
+
+const (
+	outboxClaimBatch = 100
+	outboxRetryAfter = 30 * time.Second // a job whose save failed is retried after this, doubled per failed attempt
+	outboxMaxPerTick = 1000
+)
+
+// StartOutboxRelay saves the decisions held in the outbox once the database takes them again.
+// Every instance relays from the same outbox, claims keep them from saving a job twice at once.
+// It stops once ctx is done, after finishing the current round; the returned channel is closed then.
+func StartOutboxRelay(ctx context.Context, ob *outbox.Outbox, interval time.Duration) <-chan struct{} {
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		slog.Info("OutboxRelay started", "interval", interval)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				slog.Info("OutboxRelay stopped")
+				return
+			case <-ticker.C:
+				relay(context.Background(), ob)
+			}
+		}
+	}()
+
+	return done
+}
+
+func relay(ctx context.Context, ob *outbox.Outbox) {
+	for relayed := 0; relayed < outboxMaxPerTick; {
+		jobs, err := ob.Claim(ctx, outboxClaimBatch, outboxRetryAfter)
+		if err != nil {
+			slog.Error("OutboxRelay: claiming jobs failed", "err", err)
+			return
+		}
+		if len(jobs) == 0 {
+			return
+		}
+
+		var decisions []*spec.JobDecision
+		for _, job := range jobs {
+			decisions = append(decisions, job.Decisions...)
+		}
+
+		errs, err := saveJobs(ctx, decisions)
+		if err == nil {
+			for _, job := range jobs {
+				n := len(job.Decisions)
+				relayJob(ctx, ob, job, errs[:n])
+				errs = errs[n:]
+			}
+		} else if len(jobs) == 1 {
+			failJob(ctx, ob, jobs[0], err)
+			return
+		} else {
+			// Saved job by job, so one that cannot be saved does not hold back the rest
+			for _, job := range jobs {
+				jobErrs, err := saveJobs(ctx, job.Decisions)
+				if err != nil {
+					failJob(ctx, ob, job, err)
+					continue
+				}
+				relayJob(ctx, ob, job, jobErrs)
+			}
+		}
+
+		relayed += len(jobs)
+	}
+}
+
+// relayJob removes a job whose decisions were saved, errs holds their lifecycle errors.
+// Decisions of a job that is not stored yet are held again until the one creating it is saved.
+func relayJob(ctx context.Context, ob *outbox.Outbox, job outbox.Job, errs []error) {
+	var early []int // indexes into job.Decisions
+	for i, err := range errs {
+		var illegal *lifecycle.TransitionError
+		if errors.As(err, &illegal) && illegal.From == "" {
+			early = append(early, i)
+			continue
+		}
+		if errors.As(err, &illegal) {
+			// Never valid, redelivering it would not change that
+			metrics.OutboxRelayed.WithLabelValues("illegal_transition").Inc()
+			slog.Warn("OutboxRelay: dropping decision", "job_id", job.JobID, "status", job.Decisions[i].Status, "err", err)
+			continue
+		}
+		metrics.OutboxRelayed.WithLabelValues("saved").Inc()
+	}
+
+	var err error
+	if len(early) > 0 {
+		slog.Debug("OutboxRelay: job not stored yet, holding its decisions", "job_id", job.JobID, "count", len(early))
+		err = ob.Retry(ctx, job, early, unknownJobDelay)
+	} else {
+		err = ob.Done(ctx, job)
+	}
+	if err != nil {
+		// Claimed and saved again later, the lifecycle check refuses what is already stored
+		slog.Error("OutboxRelay: removing relayed job failed", "job_id", job.JobID, "err", err)
+	}
+}
+
+// failJob holds a job the database could not save for another attempt later
+func failJob(ctx context.Context, ob *outbox.Outbox, job outbox.Job, err error) {
+	slog.Warn("OutboxRelay: saving job failed, retrying later", "job_id", job.JobID, "attempts", job.Attempts()+1, "err", err)
+	if err := ob.Fail(ctx, job, outboxRetryAfter); err != nil {
+		// Comes due again once its claim runs out, this attempt not counted
+		slog.Error("OutboxRelay: holding failed job failed", "job_id", job.JobID, "err", err)
+	}
+}
@@ -0,0 +1,72 @@
+package worker
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/satyamraj1643/janus/internal/admission"
+	"github.com/satyamraj1643/janus/queue"
+)
+
+const (
+	scheduledClaimVisibility = 30 * time.Second
+	scheduledClaimBatch      = 100
+	scheduledMaxPerTick      = 1000
+)
+
+// StartScheduler runs admission for scheduled jobs once their not_before passes.
+// Every outcome is sent to the DB writer; admitted jobs with execution.dispatch are
+// queued for workers by admission itself.
+// It stops once ctx is done, after finishing the current round; the returned channel is closed then.
+func StartScheduler(ctx context.Context, ac *admission.AdmissionController, interval time.Duration) <-chan struct{} {
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		slog.Info("Scheduler started", "interval", interval)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				slog.Info("Scheduler stopped")
+				return
+			case <-ticker.C:
+				releaseDue(context.Background(), ac)
+			}
+		}
+	}()
+
+	return done
+}
+
+func releaseDue(ctx context.Context, ac *admission.AdmissionController) {
+	for released := 0; released < scheduledMaxPerTick; {
+		due, err := ac.Scheduled.ClaimDue(ctx, time.Now(), scheduledClaimBatch, scheduledClaimVisibility)
+		if err != nil {
+			slog.Error("Scheduler: claiming due jobs failed", "err", err)
+			return
+		}
+
+		for _, scheduled := range due {
+			decision, err := ac.ReleaseScheduled(ctx, scheduled)
+			if err != nil {
+				slog.Error("Scheduler: release failed", "job_id", scheduled.JobID, "err", err)
+			}
+			if decision == nil {
+				continue
+			}
+
+			slog.Debug("Scheduler: released job", "job_id", decision.JobID, "status", decision.Status)
+			queue.Record(ctx, decision)
+		}
+
+		released += len(due)
+		if len(due) < scheduledClaimBatch {
+			return
+		}
+	}
+}
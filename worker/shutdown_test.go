@@ -0,0 +1,54 @@
+package worker
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/satyamraj1643/janus/queue"
+	"github.com/satyamraj1643/janus/spec"
+)
+
+// waitDone fails the test unless done is closed within a second
+func waitDone(t *testing.T, name string, done <-chan struct{}) {
+	t.Helper()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("%s did not stop", name)
+	}
+}
+
+func TestBackgroundLoopsStopWithContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	// An hour apart they never tick, so nothing they would work on is needed
+	loops := map[string]<-chan struct{}{
+		"scheduler":         StartScheduler(ctx, nil, time.Hour),
+		"deferred releaser": StartDeferredReleaser(ctx, nil, time.Hour),
+		"lease reaper":      StartLeaseReaper(ctx, nil, time.Hour),
+		"outbox relay":      StartOutboxRelay(ctx, nil, time.Hour),
+	}
+
+	cancel()
+	for name, done := range loops {
+		waitDone(t, name, done)
+	}
+}
+
+func TestDBWriterStopsOnceResultQueueCloses(t *testing.T) {
+	prev := queue.ResultQueue
+	queue.ResultQueue = queue.NewChannelQueue[*spec.JobDecision](8)
+	t.Cleanup(func() { queue.ResultQueue = prev })
+
+	done := StartDBWriter(WriterConfig{Writers: 2, BatchSize: 8, FlushInterval: time.Millisecond})
+
+	select {
+	case <-done:
+		t.Fatal("writers stopped while the queue is open")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	queue.ResultQueue.Close()
+	waitDone(t, "DB writer", done)
+}
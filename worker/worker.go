@@ -1,32 +1,263 @@
 package worker
 
 import (
-	"log"
+	"context"
+	"errors"
+	"hash/fnv"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
 
 	"github.com/satyamraj1643/janus/db"
+	"github.com/satyamraj1643/janus/internal/lifecycle"
+	"github.com/satyamraj1643/janus/internal/metrics"
+	"github.com/satyamraj1643/janus/internal/outbox"
+	"github.com/satyamraj1643/janus/internal/tracing"
 	"github.com/satyamraj1643/janus/queue"
+	"github.com/satyamraj1643/janus/spec"
+	"go.opentelemetry.io/otel/attribute"
 )
 
-func StartDBWriter(n int) {
-	for i := range n {
-		go func(id int) {
-			log.Printf("DBWriter %d started", id)
-
-			for decision := range queue.ResultQueue {
-				log.Printf("DBWriter %d: saving job %s to DB", id, decision.JobID)
-				log.Printf("  → Status:    %s", decision.Status)
-				log.Printf("  → BatchName: %s", decision.BatchName)
-				log.Printf("  → BatchID:   %s", decision.BatchID)
-				log.Printf("  → Reason:    %s", decision.Reason)
-				log.Printf("  → TenantID:  %s", decision.Job.TenantID)
-				log.Printf("  → Priority:  %d", decision.Job.Priority)
-				log.Printf("  → Timestamp: %v", decision.Timestamp)
-
-				// Write to DB here
-				if err := db.SaveJob(decision); err != nil {
-					log.Printf("Error saving job %s: %v", decision.JobID, err)
+// A decision can reach the writer before the one that created its job, when both are
+// recorded at nearly the same time (a job leased right as it is accepted).
+// Those are held in the outbox and relayed after this delay, once the job is stored.
+const unknownJobDelay = time.Second
+
+// WriterConfig configures the DB writer
+type WriterConfig struct {
+	Writers       int            // decisions are split by job over this many writers
+	BatchSize     int            // a writer saves once it holds this many decisions...
+	FlushInterval time.Duration  // ...or this long after the first one arrived
+	Outbox        *outbox.Outbox // optional, holds decisions while the database cannot take them
+}
+
+// StartDBWriter saves decisions with cfg.Writers writers. Decisions are split by job so
+// one job's transitions are saved in the order they were recorded.
+// Once queue.ResultQueue is closed the writers save what is left in it and stop;
+// the returned channel is closed then.
+func StartDBWriter(cfg WriterConfig) <-chan struct{} {
+	var writers sync.WaitGroup
+	partitions := make([]chan queue.Message[*spec.JobDecision], cfg.Writers)
+	for i := range partitions {
+		partitions[i] = make(chan queue.Message[*spec.JobDecision], cfg.BatchSize)
+		writers.Add(1)
+		go func() {
+			defer writers.Done()
+			writeDecisions(i, partitions[i], cfg)
+		}()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		writers.Wait()
+		close(done)
+	}()
+
+	go func() {
+		ctx := context.Background()
+
+		for {
+			msg, err := queue.ResultQueue.Receive(ctx)
+			if errors.Is(err, queue.ErrClosed) {
+				for _, p := range partitions {
+					close(p)
 				}
+				return
+			}
+			if err != nil {
+				slog.Error("DBWriter: receive failed", "err", err)
+				time.Sleep(time.Second)
+				continue
+			}
+
+			h := fnv.New32a()
+			h.Write([]byte(msg.Value.JobID))
+			partitions[h.Sum32()%uint32(cfg.Writers)] <- msg
+		}
+	}()
+
+	return done
+}
+
+// writeDecisions collects decisions and saves them a flush at a time
+func writeDecisions(id int, msgs <-chan queue.Message[*spec.JobDecision], cfg WriterConfig) {
+	slog.Info("DBWriter started", "writer", id)
+
+	ctx := context.Background()
+	pending := make([]queue.Message[*spec.JobDecision], 0, cfg.BatchSize)
+
+	timer := time.NewTimer(cfg.FlushInterval)
+	timer.Stop()
+
+	for {
+		select {
+		case msg, ok := <-msgs:
+			if !ok {
+				flush(ctx, id, cfg.Outbox, pending)
+				slog.Info("DBWriter stopped", "writer", id)
+				return
+			}
+
+			pending = append(pending, msg)
+			if len(pending) == 1 {
+				timer.Reset(cfg.FlushInterval)
+			}
+			if len(pending) < cfg.BatchSize {
+				continue
+			}
+			timer.Stop()
+
+		case <-timer.C:
+		}
+
+		flush(ctx, id, cfg.Outbox, pending)
+		pending = pending[:0]
+	}
+}
+
+// flush saves msgs in one transaction and acknowledges them. If that transaction fails
+// they are saved one at a time, so one bad decision does not hold back the rest, and
+// those that still fail are held in cfg.Outbox for the relay to save later.
+// Decisions of jobs the outbox already holds join them there, to be saved in order,
+// and those of jobs not stored yet wait there for the decision creating the job.
+func flush(ctx context.Context, id int, ob *outbox.Outbox, msgs []queue.Message[*spec.JobDecision]) {
+	if len(msgs) == 0 {
+		return
+	}
+
+	decisions := make([]*spec.JobDecision, len(msgs))
+	for i, msg := range msgs {
+		decisions[i] = msg.Value
+	}
+	slog.Debug("DBWriter: saving decisions", "writer", id, "count", len(decisions))
+
+	var held map[string]bool
+	if ob != nil {
+		var err error
+		if held, err = ob.Holding(ctx, decisions); err != nil {
+			slog.Error("DBWriter: checking the outbox failed", "writer", id, "err", err)
+		}
+	}
+
+	var save, hold []int // indexes into msgs
+	for i, decision := range decisions {
+		if held[decision.JobID] {
+			hold = append(hold, i)
+		} else {
+			save = append(save, i)
+		}
+	}
+
+	errs := make([]error, len(msgs))
+	if len(save) > 0 {
+		batch := make([]*spec.JobDecision, len(save))
+		for j, i := range save {
+			batch[j] = decisions[i]
+		}
+
+		saveErrs, err := saveJobs(ctx, batch)
+		switch {
+		case err == nil:
+			for j, i := range save {
+				errs[i] = saveErrs[j]
 			}
-		}(i)
+		default:
+			slog.Warn("DBWriter: saving flush failed, saving decisions one at a time", "writer", id, "count", len(batch), "err", err)
+			for _, i := range save {
+				errs[i] = saveJob(ctx, decisions[i])
+			}
+		}
+	}
+
+	var early []int // decisions of jobs not stored yet, indexes into msgs
+	for _, i := range save {
+		decision := decisions[i]
+		err := errs[i]
+
+		var illegal *lifecycle.TransitionError
+		notStored := errors.As(err, &illegal) && illegal.From == ""
+
+		switch {
+		case notStored && ob != nil:
+			early = append(early, i)
+			continue
+		case notStored:
+			// Left unacknowledged, a durable queue redelivers it once the job may be stored
+			metrics.DBSaveFailures.WithLabelValues("error").Inc()
+			slog.Warn("DBWriter: job not stored yet", "writer", id, "job_id", decision.JobID, "status", decision.Status)
+			continue
+		case illegal != nil:
+			// Never valid, acknowledged so it is not redelivered
+			metrics.DBSaveFailures.WithLabelValues("illegal_transition").Inc()
+			slog.Warn("DBWriter: dropping decision", "writer", id, "job_id", decision.JobID, "status", decision.Status, "err", err)
+		case err != nil && ob != nil:
+			hold = append(hold, i)
+			continue
+		case err != nil:
+			// Left unacknowledged, a durable queue redelivers it later
+			metrics.DBSaveFailures.WithLabelValues("error").Inc()
+			slog.Error("DBWriter: saving decision failed", "writer", id, "job_id", decision.JobID, "status", decision.Status, "err", err)
+			continue
+		}
+
+		ack(ctx, id, msgs[i])
+	}
+
+	outboxDecisions(ctx, id, ob, msgs, hold, 0)
+	outboxDecisions(ctx, id, ob, msgs, early, unknownJobDelay)
+}
+
+// outboxDecisions holds the decisions of msgs at idx in the outbox, due after delay, and acknowledges them
+func outboxDecisions(ctx context.Context, id int, ob *outbox.Outbox, msgs []queue.Message[*spec.JobDecision], idx []int, delay time.Duration) {
+	if len(idx) == 0 {
+		return
+	}
+
+	slices.Sort(idx)
+	outboxed := make([]*spec.JobDecision, len(idx))
+	for j, i := range idx {
+		outboxed[j] = msgs[i].Value
+	}
+
+	if err := ob.Add(ctx, outboxed, delay); err != nil {
+		// Left unacknowledged, a durable queue redelivers them later
+		metrics.DBSaveFailures.WithLabelValues("error").Add(float64(len(idx)))
+		slog.Error("DBWriter: holding decisions in the outbox failed", "writer", id, "count", len(idx), "err", err)
+		return
+	}
+
+	metrics.DBOutboxed.Add(float64(len(idx)))
+	for _, i := range idx {
+		ack(ctx, id, msgs[i])
+	}
+}
+
+func ack(ctx context.Context, id int, msg queue.Message[*spec.JobDecision]) {
+	if err := queue.ResultQueue.Ack(ctx, msg); err != nil {
+		slog.Error("DBWriter: ack failed", "writer", id, "job_id", msg.Value.JobID, "err", err)
+	}
+}
+
+// saveJobs saves the decisions in a span linked to the requests that produced them
+func saveJobs(ctx context.Context, decisions []*spec.JobDecision) ([]error, error) {
+	defer metrics.Since(metrics.DBSaveDuration, time.Now())
+	metrics.DBFlushSize.Observe(float64(len(decisions)))
+
+	ctx, span := tracing.StartLinked(ctx, "DBWriter.save", decisions,
+		attribute.Int("janus.decisions", len(decisions)),
+	)
+
+	errs, err := db.SaveJobs(ctx, decisions)
+	tracing.End(span, err)
+	return errs, err
+}
+
+// saveJob saves a single decision
+func saveJob(ctx context.Context, decision *spec.JobDecision) error {
+	errs, err := saveJobs(ctx, []*spec.JobDecision{decision})
+	if err != nil {
+		return err
 	}
+	return errs[0]
 }